@@ -0,0 +1,62 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// admission.go decides whether loaded values are worth caching, by
+// comparing how often their key was requested with how often the key
+// they would evict was, as in TinyLFU.
+
+package groupcache
+
+import "github.com/golang/groupcache/lru"
+
+// admit reports whether key's value, of size bytes, should be added to
+// the group's caches: always if they have room for it, and otherwise
+// only if key was requested more often than the entry that would be
+// evicted first.
+func (g *Group) admit(key string, size, cacheBytes int64) bool {
+	mainBytes, hotBytes := g.mainCache.bytes(), g.hotCache.bytes()
+	if mainBytes+hotBytes+size <= cacheBytes {
+		return true
+	}
+	// 与evictTo选择同一个被淘汰的缓存。
+	victim := &g.mainCache
+	if hotBytes > mainBytes/8 {
+		victim = &g.hotCache
+	}
+	vk, ok := victim.oldest()
+	if !ok || vk == key {
+		return true
+	}
+	return g.admission.Estimate(key) > g.admission.Estimate(vk)
+}
+
+// oldest returns the key the cache would evict next, if its policy
+// can tell without evicting it.
+func (c *cache) oldest() (key string, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, _ := c.lru.(interface {
+		PeekOldest() (lru.Key, interface{}, bool)
+	})
+	if p == nil {
+		return "", false
+	}
+	k, _, ok := p.PeekOldest()
+	if !ok {
+		return "", false
+	}
+	return k.(string), true
+}
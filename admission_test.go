@@ -0,0 +1,56 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestAdmission(t *testing.T) {
+	loads := make(map[string]int)
+	// Room for about 10 entries.
+	g := NewGroupOpts("admission", 10*10, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads[key]++
+		return dest.SetString("12345")
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, AdmissionCounters: 1024})
+
+	get := func(key string) {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 8; k++ {
+			get(fmt.Sprint("hot", k))
+		}
+	}
+	for k := 0; k < 100; k++ {
+		get(fmt.Sprint("scan", k))
+	}
+	for k := 0; k < 8; k++ {
+		get(fmt.Sprint("hot", k))
+		if n := loads[fmt.Sprint("hot", k)]; n != 1 {
+			t.Errorf("hot%d loaded %d times; want 1", k, n)
+		}
+	}
+	if g.Stats.NotAdmitted.Get() == 0 {
+		t.Error("no scanned values were refused")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import "github.com/golang/groupcache/lru"
+
+const (
+	// arenaChunkSize is the size of the allocations an arena packs
+	// values into.
+	arenaChunkSize = 1 << 20
+
+	// arenaMaxValue is the largest value copied into an arena. Larger
+	// values keep their own allocation, since they gain little from
+	// packing and would waste the tail of chunks.
+	arenaMaxValue = arenaChunkSize / 8
+)
+
+// arenaChunk is one allocation of an arena.
+type arenaChunk struct {
+	buf  []byte
+	live int // number of cached values in buf
+	used int // bytes of those values
+}
+
+// An arena packs cached values into large chunks, so that a cache of
+// many small values is a few big pointer-free objects to the garbage
+// collector rather than millions of small ones.
+//
+// Chunks are never reused: ByteViews handed out to callers may still
+// point into a chunk after its values are evicted. A chunk is released
+// to the garbage collector once it holds no cached value and no
+// outstanding view references it. Since one long-lived value would
+// keep its whole chunk, the cache compacts its arena when the chunks
+// grow to more than twice its values.
+//
+// An arena is not safe for concurrent use; cache.mu guards it.
+type arena struct {
+	cur *arenaChunk
+	off int // next free byte of cur
+
+	chunks int   // chunks holding cached values
+	bytes  int64 // size of those chunks
+	live   int64 // bytes of the cached values in them
+}
+
+// alloc copies v into the arena and returns the copy together with its
+// chunk, which must be passed to free once the copy leaves the cache.
+// Values not worth packing are returned unchanged with a nil chunk.
+func (a *arena) alloc(v ByteView) (ByteView, *arenaChunk) {
+	n := v.Len()
+	if n == 0 || n > arenaMaxValue {
+		return v, nil
+	}
+	if a.cur == nil || a.off+n > len(a.cur.buf) {
+		if a.cur != nil && a.cur.live == 0 {
+			a.release()
+		}
+		a.cur = &arenaChunk{buf: make([]byte, arenaChunkSize)}
+		a.off = 0
+		a.chunks++
+		a.bytes += arenaChunkSize
+	}
+	b := a.cur.buf[a.off : a.off+n : a.off+n]
+	v.Copy(b)
+	a.off += n
+	a.cur.live++
+	a.cur.used += n
+	a.live += int64(n)
+	return ByteView{b: b, e: v.e}, a.cur
+}
+
+// free records that a value of n bytes allocated from c left the
+// cache.
+func (a *arena) free(c *arenaChunk, n int) {
+	if c == nil {
+		return
+	}
+	c.live--
+	c.used -= n
+	a.live -= int64(n)
+	// The current chunk stays accounted for until it fills up.
+	if c.live == 0 && c != a.cur {
+		a.release()
+	}
+}
+
+func (a *arena) release() {
+	a.chunks--
+	a.bytes -= arenaChunkSize
+}
+
+// fragmented reports whether the chunks hold much more memory than
+// the values in them.
+func (a *arena) fragmented() bool {
+	return a.bytes > 2*(a.live+arenaChunkSize)
+}
+
+// compactLocked copies the values of chunks less than half full into
+// the current chunk, which releases those chunks. Afterwards every
+// chunk but the current one is at least half full. c.mu must be held.
+func (c *cache) compactLocked() {
+	a := c.arena
+	c.lru.Range(func(_ lru.Key, v interface{}) bool {
+		e := v.(*cacheEntry)
+		if old := e.chunk; old != nil && old != a.cur && old.used < arenaChunkSize/2 {
+			e.value, e.chunk = a.alloc(e.value)
+			a.free(old, e.value.Len())
+		}
+		return true
+	})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestArenaAlloc(t *testing.T) {
+	var a arena
+	v, c := a.alloc(of("hello"))
+	if c == nil || v.String() != "hello" || a.chunks != 1 {
+		t.Fatalf("alloc = %q, chunk %v, %d chunks; want packed copy in 1 chunk", v.String(), c, a.chunks)
+	}
+	if big, c := a.alloc(of(strings.Repeat("x", arenaMaxValue+1))); c != nil || big.Len() != arenaMaxValue+1 {
+		t.Errorf("large value was packed into the arena")
+	}
+
+	// Fill the first chunk, so that it is no longer current.
+	var chunks []*arenaChunk
+	val := of(strings.Repeat("y", arenaMaxValue))
+	for i := 0; i < arenaChunkSize/arenaMaxValue; i++ {
+		_, c := a.alloc(val)
+		chunks = append(chunks, c)
+	}
+	if a.chunks != 2 {
+		t.Fatalf("%d chunks; want 2", a.chunks)
+	}
+	a.free(c, 5)
+	for _, ch := range chunks {
+		if ch != a.cur {
+			a.free(ch, arenaMaxValue)
+		}
+	}
+	if a.chunks != 1 || a.bytes != arenaChunkSize {
+		t.Errorf("after freeing the first chunk: %d chunks, %d bytes; want 1, %d", a.chunks, a.bytes, arenaChunkSize)
+	}
+	// Views into released chunks stay valid.
+	if v.String() != "hello" {
+		t.Errorf("view into released chunk = %q", v.String())
+	}
+}
+
+func TestGroupArena(t *testing.T) {
+	const n = 1000
+	g := NewGroupOpts("arena", 64<<10, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value-of-" + key)
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, UseArena: true})
+
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint(i)
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if s != "value-of-"+key {
+			t.Fatalf("Get(%q) = %q", key, s)
+		}
+	}
+	var s string
+	if err := g.Get(dummyCtx, fmt.Sprint(n-1), StringSink(&s)); err != nil || s != fmt.Sprintf("value-of-%d", n-1) {
+		t.Errorf("cached Get = %q, %v", s, err)
+	}
+	st := g.CacheStats(MainCache)
+	if st.ArenaBytes < arenaChunkSize || st.Hits != 1 {
+		t.Errorf("stats = %+v; want an arena in use and 1 hit", st)
+	}
+}
+
+func TestArenaCompaction(t *testing.T) {
+	const cacheBytes = 512 << 10
+	value := strings.Repeat("v", 1000)
+	g := NewGroupOpts("arena-compaction", cacheBytes, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key + value)
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, UseArena: true})
+
+	// Churn through many keys while keeping a few of them, added far
+	// apart, hot: without compaction each would hold its own chunk.
+	var hot []string
+	for i := 0; i < 20000; i++ {
+		key := fmt.Sprint(i)
+		if i%1000 == 0 {
+			hot = append(hot, key)
+		}
+		for _, k := range []string{key, hot[i%len(hot)]} {
+			var s string
+			if err := g.Get(dummyCtx, k, StringSink(&s)); err != nil || s != k+value {
+				t.Fatalf("Get(%q) = %.10q..., %v", k, s, err)
+			}
+		}
+	}
+	st := g.CacheStats(MainCache)
+	if max := int64(2 * (cacheBytes + arenaChunkSize)); st.ArenaBytes > max {
+		t.Errorf("arena holds %d bytes for %d bytes of values; want at most %d", st.ArenaBytes, st.Bytes, max)
+	}
+	for _, k := range hot {
+		var s string
+		if err := g.Get(dummyCtx, k, StringSink(&s)); err != nil || s != k+value {
+			t.Errorf("Get(%q) after compaction = %.10q..., %v", k, s, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import "context"
+
+// A BackingStore is a shared second-level cache, such as a memcached
+// cluster, holding values that groups evicted or have not loaded yet.
+// It spares the origin the loads of processes that restart with empty
+// caches. See the memcachestore package. Implementations must be safe
+// for concurrent use.
+type BackingStore interface {
+	// Get returns the value stored under key; ok reports whether
+	// there is one.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Set stores value under key.
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// WithBackingStore returns a middleware loading values from store
+// before falling back to the Getter it wraps, and storing the values
+// that Getter loads. A failing store is treated as empty: its errors
+// are passed to onError, if not nil, and never fail the load.
+func WithBackingStore(store BackingStore, onError func(error)) GetterMiddleware {
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	return func(next Getter) Getter {
+		return GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			b, ok, err := store.Get(ctx, key)
+			report(err)
+			if ok {
+				return dest.SetBytes(b)
+			}
+			if err := next.Get(ctx, key, dest); err != nil {
+				return err
+			}
+			// 源数据加载成功，顺便写回二级缓存。
+			v, err := dest.view()
+			if err != nil {
+				return err
+			}
+			report(store.Set(ctx, key, v.ByteSlice()))
+			return nil
+		})
+	}
+}
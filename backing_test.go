@@ -0,0 +1,85 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type mapStore struct {
+	mu   sync.Mutex
+	m    map[string][]byte
+	down bool
+}
+
+func (s *mapStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, false, errors.New("store down")
+	}
+	b, ok := s.m[key]
+	return b, ok, nil
+}
+
+func (s *mapStore) Set(_ context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("store down")
+	}
+	s.m[key] = value
+	return nil
+}
+
+func TestWithBackingStore(t *testing.T) {
+	store := &mapStore{m: map[string][]byte{"stored": []byte("from store")}}
+	var loads int
+	var errs []error
+	getter := Chain(GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString("origin:" + key)
+	}), WithBackingStore(store, func(err error) { errs = append(errs, err) }))
+
+	get := func(key string) string {
+		var s string
+		if err := getter.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if got := get("stored"); got != "from store" || loads != 0 {
+		t.Errorf("Get(stored) = %q after %d loads; want the stored value", got, loads)
+	}
+	if got := get("new"); got != "origin:new" || loads != 1 {
+		t.Errorf("Get(new) = %q after %d loads; want a load", got, loads)
+	}
+	if string(store.m["new"]) != "origin:new" {
+		t.Errorf("loaded value not written back to the store")
+	}
+
+	store.down = true
+	if got := get("stored"); got != "origin:stored" || loads != 2 {
+		t.Errorf("Get with the store down = %q after %d loads; want a load", got, loads)
+	}
+	if len(errs) != 2 {
+		t.Errorf("reported %d store errors; want 2", len(errs))
+	}
+}
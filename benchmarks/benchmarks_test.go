@@ -0,0 +1,245 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/golang/groupcache/consistenthash"
+	"github.com/golang/groupcache/lru"
+	"github.com/golang/groupcache/singleflight"
+)
+
+const keyspace = 10000
+
+func TestZipfSkew(t *testing.T) {
+	g := NewZipf(keyspace, 1.1, 1)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[g.Next()]++
+	}
+	if hot, cold := counts[Key(0)], counts[Key(keyspace/2)]; hot <= cold*10 {
+		t.Errorf("key 0 drawn %d times, key %d drawn %d times; want a skewed distribution", hot, keyspace/2, cold)
+	}
+	if len(counts) < 100 {
+		t.Errorf("only %d distinct keys drawn", len(counts))
+	}
+}
+
+func TestUniformSpread(t *testing.T) {
+	g := NewUniform(10, 1)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[g.Next()]++
+	}
+	if len(counts) != 10 {
+		t.Fatalf("drew %d distinct keys; want 10", len(counts))
+	}
+	for k, n := range counts {
+		if n < 800 || n > 1200 {
+			t.Errorf("key %s drawn %d times; want about 1000", k, n)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	r := Run(Workload{
+		Workers: 4,
+		Ops:     100,
+		Keys:    func(int) KeyGenerator { return NewSequential(10) },
+		Op: func(key string) error {
+			if key == Key(0) {
+				return fmt.Errorf("fail")
+			}
+			return nil
+		},
+	})
+	if r.Ops != 400 {
+		t.Errorf("Ops = %d; want 400", r.Ops)
+	}
+	if r.Errors != 40 {
+		t.Errorf("Errors = %d; want 40", r.Errors)
+	}
+	if r.P50 > r.P99 || r.P99 > r.Max {
+		t.Errorf("percentiles out of order: %v", r)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i))
+	}
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{{0, 1}, {50, 50}, {99, 99}, {100, 100}} {
+		if got := Percentile(d, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v; want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+// keys pre-generates n keys so that generation cost is excluded from
+// the benchmarks below.
+func keys(g KeyGenerator, n int) []string {
+	ks := make([]string, n)
+	for i := range ks {
+		ks[i] = g.Next()
+	}
+	return ks
+}
+
+func BenchmarkLRUZipf(b *testing.B) {
+	ks := keys(NewZipf(keyspace, 1.1, 1), 1<<16)
+	c := lru.New(keyspace / 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := ks[i&(len(ks)-1)]
+		if _, ok := c.Get(k); !ok {
+			c.Add(k, k)
+		}
+	}
+}
+
+func BenchmarkLRUUniform(b *testing.B) {
+	ks := keys(NewUniform(keyspace, 1), 1<<16)
+	c := lru.New(keyspace / 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := ks[i&(len(ks)-1)]
+		if _, ok := c.Get(k); !ok {
+			c.Add(k, k)
+		}
+	}
+}
+
+func BenchmarkSingleflight(b *testing.B) {
+	var g singleflight.Group
+	var seed int64
+	b.RunParallel(func(pb *testing.PB) {
+		ks := keys(NewZipf(keyspace, 1.1, atomic.AddInt64(&seed, 1)), 1<<12)
+		i := 0
+		for pb.Next() {
+			g.Do(ks[i&(len(ks)-1)], func() (interface{}, error) { return nil, nil })
+			i++
+		}
+	})
+}
+
+func BenchmarkConsistentHash(b *testing.B) {
+	for _, peers := range []int{8, 64, 512} {
+		b.Run(fmt.Sprintf("peers=%d", peers), func(b *testing.B) {
+			m := consistenthash.New(50, nil)
+			for i := 0; i < peers; i++ {
+				m.Add(fmt.Sprintf("peer-%d", i))
+			}
+			ks := keys(NewUniform(keyspace, 1), 1<<12)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.Get(ks[i&(len(ks)-1)])
+			}
+		})
+	}
+}
+
+func newBenchGroup(cacheBytes int64) *groupcache.Group {
+	return newBenchGroupOpts(cacheBytes, &groupcache.GroupOptions{})
+}
+
+func newBenchGroupOpts(cacheBytes int64, o *groupcache.GroupOptions) *groupcache.Group {
+	getter := groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetString("value-of-" + key)
+	})
+	o.Peers = groupcache.NoPeers{}
+	o.Unregistered = true
+	return groupcache.NewGroupOpts("bench", cacheBytes, getter, o)
+}
+
+func BenchmarkGroupGet(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		gen  func(seed int64) KeyGenerator
+	}{
+		{"zipf", func(seed int64) KeyGenerator { return NewZipf(keyspace, 1.1, seed) }},
+		{"uniform", func(seed int64) KeyGenerator { return NewUniform(keyspace, seed) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			g := newBenchGroup(64 << 10)
+			var seed int64
+			b.RunParallel(func(pb *testing.PB) {
+				ks := keys(bc.gen(atomic.AddInt64(&seed, 1)), 1<<12)
+				ctx := context.Background()
+				var s string
+				i := 0
+				for pb.Next() {
+					if err := g.Get(ctx, ks[i&(len(ks)-1)], groupcache.StringSink(&s)); err != nil {
+						b.Fatal(err)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkGroupGetLatency reports end-to-end latency percentiles of
+// Group.Get under concurrent Zipfian load.
+func BenchmarkGroupGetLatency(b *testing.B) {
+	g := newBenchGroup(64 << 10)
+	ctx := context.Background()
+	const workers = 8
+	r := Run(Workload{
+		Workers: workers,
+		Ops:     b.N/workers + 1,
+		Keys:    func(w int) KeyGenerator { return NewZipf(keyspace, 1.1, int64(w)) },
+		Op: func(key string) error {
+			var s string
+			return g.Get(ctx, key, groupcache.StringSink(&s))
+		},
+	})
+	b.ReportMetric(float64(r.P50.Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(r.P99.Nanoseconds()), "p99-ns")
+	b.ReportMetric(r.Throughput(), "ops/s")
+}
+
+// BenchmarkGC measures a full garbage collection with a group holding
+// a million small values, with and without the arena.
+func BenchmarkGC(b *testing.B) {
+	for _, arena := range []bool{false, true} {
+		b.Run(fmt.Sprintf("arena=%v", arena), func(b *testing.B) {
+			g := newBenchGroupOpts(1<<30, &groupcache.GroupOptions{UseArena: arena})
+			ctx := context.Background()
+			var s string
+			for i := uint64(0); i < 1e6; i++ {
+				g.Get(ctx, Key(i), groupcache.StringSink(&s))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+			}
+			b.StopTimer()
+			runtime.KeepAlive(g)
+		})
+	}
+}
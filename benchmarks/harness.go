@@ -0,0 +1,121 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Workload describes an end-to-end measurement: Workers goroutines
+// each perform Ops operations on keys from their own generator.
+type Workload struct {
+	Workers int
+	Ops     int
+
+	// Keys returns the key generator of the given worker.
+	Keys func(worker int) KeyGenerator
+
+	// Op performs one operation on key.
+	Op func(key string) error
+}
+
+// A Result holds the measurements of a Workload run.
+type Result struct {
+	Ops      int
+	Errors   int
+	Duration time.Duration
+
+	// Latency percentiles of individual operations.
+	P50, P90, P99, Max time.Duration
+}
+
+// Throughput returns the operations per second.
+func (r Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Duration.Seconds()
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d ops (%d errors) in %v: %.0f ops/s, p50 %v, p90 %v, p99 %v, max %v",
+		r.Ops, r.Errors, r.Duration, r.Throughput(), r.P50, r.P90, r.P99, r.Max)
+}
+
+// Run runs w and measures it.
+func Run(w Workload) Result {
+	if w.Workers <= 0 {
+		w.Workers = 1
+	}
+	latencies := make([][]time.Duration, w.Workers)
+	errs := make([]int, w.Workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < w.Workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys := w.Keys(i)
+			lat := make([]time.Duration, w.Ops)
+			for j := range lat {
+				key := keys.Next()
+				t0 := time.Now()
+				if err := w.Op(key); err != nil {
+					errs[i]++
+				}
+				lat[j] = time.Since(t0)
+			}
+			latencies[i] = lat
+		}(i)
+	}
+	wg.Wait()
+	r := Result{Duration: time.Since(start)}
+
+	var all []time.Duration
+	for i, lat := range latencies {
+		all = append(all, lat...)
+		r.Errors += errs[i]
+	}
+	r.Ops = len(all)
+	if len(all) == 0 {
+		return r
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	r.P50 = Percentile(all, 50)
+	r.P90 = Percentile(all, 90)
+	r.P99 = Percentile(all, 99)
+	r.Max = all[len(all)-1]
+	return r
+}
+
+// Percentile returns the p'th percentile of sorted durations.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package benchmarks provides workload generators and a measurement
+// harness for benchmarking groupcache and its subpackages, so that
+// performance regressions in refactors are caught.
+package benchmarks
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// A KeyGenerator produces the keys of a workload. KeyGenerators are
+// not safe for concurrent use; give each worker its own.
+type KeyGenerator interface {
+	// Next returns the next key.
+	Next() string
+}
+
+// Key returns the name of the i'th key of a keyspace.
+func Key(i uint64) string {
+	return "key-" + strconv.FormatUint(i, 10)
+}
+
+type uniform struct {
+	rnd *rand.Rand
+	n   int64
+}
+
+// NewUniform returns a generator picking uniformly among n keys.
+func NewUniform(n int, seed int64) KeyGenerator {
+	return &uniform{rnd: rand.New(rand.NewSource(seed)), n: int64(n)}
+}
+
+func (u *uniform) Next() string {
+	return Key(uint64(u.rnd.Int63n(u.n)))
+}
+
+type zipf struct {
+	z *rand.Zipf
+}
+
+// NewZipf returns a generator picking among n keys with a Zipfian
+// distribution of exponent s, which must be greater than 1. Key 0 is
+// the most popular. Values of s close to 1 model typical cache
+// workloads; larger values are more skewed.
+func NewZipf(n int, s float64, seed int64) KeyGenerator {
+	r := rand.New(rand.NewSource(seed))
+	return &zipf{z: rand.NewZipf(r, s, 1, uint64(n-1))}
+}
+
+func (z *zipf) Next() string {
+	return Key(z.z.Uint64())
+}
+
+type sequential struct {
+	i, n uint64
+}
+
+// NewSequential returns a generator cycling through n keys in order,
+// modeling scans.
+func NewSequential(n int) KeyGenerator {
+	return &sequential{n: uint64(n)}
+}
+
+func (s *sequential) Next() string {
+	k := Key(s.i)
+	s.i = (s.i + 1) % s.n
+	return k
+}
@@ -18,9 +18,11 @@ package groupcache
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"errors"
 	"io"
 	"strings"
+	"time"
 )
 
 // A ByteView holds an immutable view of bytes.
@@ -35,6 +37,18 @@ type ByteView struct {
 	// If b is non-nil, b is used, else s is used.
 	b []byte	// 优先级更高，不是nil就用b
 	s string	// b是nil，就用s
+	e time.Time	// 过期时间，零值表示永不过期
+}
+
+// Expire returns the time the view's value expires from caches, or
+// the zero time if it never does.
+func (v ByteView) Expire() time.Time {
+	return v.e
+}
+
+// expired reports whether the view's value has expired at now.
+func (v ByteView) expired(now time.Time) bool {
+	return !v.e.IsZero() && !now.Before(v.e)
 }
 
 // Len returns the view's length.
@@ -72,6 +86,16 @@ func (v ByteView) At(i int) byte {
 	return v.s[i]
 }
 
+// ByteAt returns the byte at index i, or an error if i is out of
+// range.
+// 带边界检查的At，越界时返回错误而不是panic。
+func (v ByteView) ByteAt(i int) (byte, error) {
+	if i < 0 || i >= v.Len() {
+		return 0, errors.New("view: index out of range")
+	}
+	return v.At(i), nil
+}
+
 // Slice slices the view between the provided from and to indices.
 // 返回一个索引从from到to的ByteView视图。
 func (v ByteView) Slice(from, to int) ByteView {
@@ -90,6 +114,15 @@ func (v ByteView) SliceFrom(from int) ByteView {
 	return ByteView{s: v.s[from:]}
 }
 
+// SliceTo slices the view from the start until the provided index.
+// 返回一个索引从开头到to的ByteView视图。
+func (v ByteView) SliceTo(to int) ByteView {
+	if v.b != nil {
+		return ByteView{b: v.b[:to]}
+	}
+	return ByteView{s: v.s[:to]}
+}
+
 // Copy copies b into dest and returns the number of bytes copied.
 // 将视图中的数据copy到dest中，返回长度。
 func (v ByteView) Copy(dest []byte) int {
@@ -109,6 +142,22 @@ func (v ByteView) Equal(b2 ByteView) bool {
 	return v.EqualBytes(b2.b)
 }
 
+// ConstantTimeEqual is like Equal, but takes a time independent of
+// the contents of the views, so it can compare secrets such as tokens.
+// The time still depends on the views' lengths.
+// 比较耗时与内容无关的Equal，避免时序攻击。
+func (v ByteView) ConstantTimeEqual(b2 ByteView) bool {
+	l := v.Len()
+	if b2.Len() != l {
+		return false
+	}
+	var x byte
+	for i := 0; i < l; i++ {
+		x |= v.At(i) ^ b2.At(i)
+	}
+	return subtle.ConstantTimeByteEq(x, 0) == 1
+}
+
 // EqualString returns whether the bytes in b are the same as the bytes
 // in s.
 // 比较输入的字符串s是否与v中的字符串或字节数组相等。
@@ -148,6 +197,8 @@ func (v ByteView) EqualBytes(b2 []byte) bool {
 }
 
 // Reader returns an io.ReadSeeker for the bytes in v.
+// The reader also implements io.WriterTo and io.ReaderAt, so v can be
+// streamed, for example with http.ServeContent, without copying it.
 // io.ReadSeeker支持任意位置读取的IO接口
 func (v ByteView) Reader() io.ReadSeeker {
 	if v.b != nil {
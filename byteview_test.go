@@ -97,6 +97,9 @@ func TestByteViewEqual(t *testing.T) {
 		if got := va.Equal(of(tt.b)); got != tt.want {
 			t.Errorf("%d. Equal = %v; want %v", i, got, tt.want)
 		}
+		if got := va.ConstantTimeEqual(of(tt.b)); got != tt.want {
+			t.Errorf("%d. ConstantTimeEqual = %v; want %v", i, got, tt.want)
+		}
 	}
 }
 
@@ -105,6 +108,7 @@ func TestByteViewSlice(t *testing.T) {
 		in   string
 		from int
 		to   interface{} // nil to mean the end (SliceFrom); else int
+		head bool        // slice from the start (SliceTo)
 		want string
 	}{
 		{
@@ -123,11 +127,19 @@ func TestByteViewSlice(t *testing.T) {
 			to:   2,
 			want: "ab",
 		},
+		{
+			in:   "abc",
+			to:   1,
+			head: true,
+			want: "a",
+		},
 	}
 	for i, tt := range tests {
 		for _, v := range []ByteView{of([]byte(tt.in)), of(tt.in)} {
 			name := fmt.Sprintf("test %d, view %+v", i, v)
-			if tt.to != nil {
+			if tt.head {
+				v = v.SliceTo(tt.to.(int))
+			} else if tt.to != nil {
 				v = v.Slice(tt.from, tt.to.(int))
 			} else {
 				v = v.SliceFrom(tt.from)
@@ -139,6 +151,35 @@ func TestByteViewSlice(t *testing.T) {
 	}
 }
 
+func TestByteViewByteAt(t *testing.T) {
+	for _, v := range []ByteView{of([]byte("ab")), of("ab")} {
+		if c, err := v.ByteAt(1); c != 'b' || err != nil {
+			t.Errorf("view %+v: ByteAt(1) = %q, %v; want 'b', nil", v, c, err)
+		}
+		for _, i := range []int{-1, 2} {
+			if _, err := v.ByteAt(i); err == nil {
+				t.Errorf("view %+v: ByteAt(%d) succeeded; want an error", v, i)
+			}
+		}
+	}
+}
+
+func TestByteViewStream(t *testing.T) {
+	for _, v := range []ByteView{of([]byte("streamed")), of("streamed")} {
+		var buf bytes.Buffer
+		r := v.Reader()
+		if _, ok := r.(io.WriterTo); !ok {
+			t.Fatalf("view %+v: Reader does not implement io.WriterTo", v)
+		}
+		if _, err := io.Copy(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "streamed" {
+			t.Errorf("view %+v: streamed %q", v, buf.String())
+		}
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
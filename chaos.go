@@ -0,0 +1,125 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by peer requests dropped by fault
+// injection.
+var ErrInjectedFault = errors.New("groupcache: injected fault")
+
+// FaultOptions configure fault injection in an HTTPPool's peer layer,
+// for exercising resilience behavior in tests. Rates are fractions
+// between 0 and 1 of the requests affected.
+//
+// Faults are only injected by binaries built with the groupcache_chaos
+// build tag. Elsewhere, creating a pool with FaultOptions panics, so
+// that fault injection can never be switched on in production by
+// configuration alone.
+type FaultOptions struct {
+	// DropRate is the fraction of outgoing peer requests failed with
+	// ErrInjectedFault without being sent.
+	DropRate float64
+
+	// DelayRate is the fraction of outgoing peer requests held back
+	// by Delay before being sent.
+	DelayRate float64
+	Delay     time.Duration
+
+	// CorruptRate is the fraction of peer responses that have a byte
+	// of their body flipped before being decoded.
+	CorruptRate float64
+
+	// AbortRate is the fraction of served peer requests for which the
+	// server writes part of the response and then kills the
+	// connection, as if the peer died mid-response.
+	AbortRate float64
+
+	// Seed seeds the random choices, so runs can be reproduced.
+	Seed int64
+}
+
+// faultInjector applies FaultOptions. A nil *faultInjector injects
+// nothing.
+type faultInjector struct {
+	opts FaultOptions
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newFaultInjector(o *FaultOptions) *faultInjector {
+	if o == nil {
+		return nil
+	}
+	if !chaosEnabled {
+		panic("groupcache: HTTPPoolOptions.Faults requires building with -tags groupcache_chaos")
+	}
+	return &faultInjector{opts: *o, rnd: rand.New(rand.NewSource(o.Seed))}
+}
+
+// hit reports whether an event with the given probability happens.
+func (f *faultInjector) hit(rate float64) bool {
+	if f == nil || rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Float64() < rate
+}
+
+// beforeRequest is called before a peer request is sent.
+func (f *faultInjector) beforeRequest(ctx context.Context) error {
+	if f == nil {
+		return nil
+	}
+	if f.hit(f.opts.DelayRate) {
+		t := time.NewTimer(f.opts.Delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	if f.hit(f.opts.DropRate) {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// corrupt possibly flips a byte of a peer response body in place.
+func (f *faultInjector) corrupt(b []byte) {
+	if f == nil || len(b) == 0 || !f.hit(f.opts.CorruptRate) {
+		return
+	}
+	f.mu.Lock()
+	i := f.rnd.Intn(len(b))
+	f.mu.Unlock()
+	b[i] ^= 0xff
+}
+
+// abort reports whether a served response should be cut short.
+func (f *faultInjector) abort() bool {
+	return f != nil && f.hit(f.opts.AbortRate)
+}
@@ -0,0 +1,92 @@
+//go:build groupcache_chaos
+// +build groupcache_chaos
+
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/golang/groupcache/groupcachepb"
+)
+
+// chaosServer serves a peer whose group echoes keys.
+func chaosServer(t *testing.T, name string, o *FaultOptions) (*httptest.Server, *HTTPPool) {
+	NewGroupOpts(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("echo:" + key)
+	}), nil)
+	server := newHTTPPool("http://server", &HTTPPoolOptions{Faults: o})
+	ts := httptest.NewServer(server)
+	client := newHTTPPool("http://client", &HTTPPoolOptions{Faults: o})
+	client.Set(ts.URL)
+	return ts, client
+}
+
+func chaosGet(p *HTTPPool, group, key string) error {
+	peer, ok := p.PickPeer(key)
+	if !ok {
+		return nil
+	}
+	req := &pb.GetRequest{Group: &group, Key: &key}
+	return peer.Get(context.Background(), req, &pb.GetResponse{})
+}
+
+func TestFaultDrop(t *testing.T) {
+	ts, client := chaosServer(t, "TestFaultDrop", &FaultOptions{DropRate: 1})
+	defer ts.Close()
+	if err := chaosGet(client, "TestFaultDrop", "k"); err != ErrInjectedFault {
+		t.Errorf("Get = %v; want ErrInjectedFault", err)
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	ts, client := chaosServer(t, "TestFaultDelay", &FaultOptions{DelayRate: 1, Delay: 50 * time.Millisecond})
+	defer ts.Close()
+	start := time.Now()
+	if err := chaosGet(client, "TestFaultDelay", "k"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("Get took %v; want at least the injected 50ms", d)
+	}
+}
+
+func TestFaultAbort(t *testing.T) {
+	ts, client := chaosServer(t, "TestFaultAbort", &FaultOptions{AbortRate: 1})
+	defer ts.Close()
+	if err := chaosGet(client, "TestFaultAbort", "some-key"); err == nil {
+		t.Error("Get from a peer dying mid-response succeeded")
+	}
+}
+
+func TestFaultRates(t *testing.T) {
+	f := newFaultInjector(&FaultOptions{DropRate: 0.25, Seed: 1})
+	const n = 10000
+	drops := 0
+	for i := 0; i < n; i++ {
+		if f.beforeRequest(context.Background()) == ErrInjectedFault {
+			drops++
+		}
+	}
+	if drops < n/5 || drops > n*3/10 {
+		t.Errorf("dropped %d of %d requests; want about 25%%", drops, n)
+	}
+}
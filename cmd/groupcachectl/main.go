@@ -0,0 +1,142 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command groupcachectl operates the nodes of a groupcache cluster
+// through their admin handlers.
+//
+// Usage:
+//
+//	groupcachectl -admin http://10.0.0.3:8001 drain [-max-bytes n]
+//	groupcachectl -admin http://10.0.0.3:8001 status
+//
+// drain sends the node's cached values to the peers that own them once
+// it is gone, then removes it from the peers' rings; stop the node
+// afterwards. status tells whether the node is draining.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "groupcachectl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("groupcachectl", flag.ContinueOnError)
+	admin := fs.String("admin", "", "admin base URL of the node")
+	timeout := fs.Duration("timeout", 5*time.Minute, "timeout of the command")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *admin == "" || fs.NArg() == 0 {
+		return errors.New("usage: groupcachectl -admin URL drain [-max-bytes n] | status")
+	}
+	client := &http.Client{Timeout: *timeout}
+	base := strings.TrimSuffix(*admin, "/")
+	switch cmd := fs.Arg(0); cmd {
+	case "drain":
+		dfs := flag.NewFlagSet("drain", flag.ContinueOnError)
+		maxBytes := dfs.Int64("max-bytes", 0, "most bytes of values to send per group; 0 sends all")
+		if err := dfs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+		return drain(client, base, *maxBytes, out)
+	case "status":
+		var st struct{ Draining bool }
+		if err := call(client, "GET", base+"/drain", nil, &st); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "draining: %v\n", st.Draining)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// drain runs a drain of the node at base and prints its report.
+func drain(client *http.Client, base string, maxBytes int64, out io.Writer) error {
+	form := url.Values{}
+	if maxBytes > 0 {
+		form.Set("max_bytes", strconv.FormatInt(maxBytes, 10))
+	}
+	var report groupcache.DrainReport
+	if err := call(client, "POST", base+"/drain", form, &report); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(report.Groups))
+	for name := range report.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := report.Groups[name]
+		fmt.Fprintf(out, "%s: sent %d entries, %d bytes\n", name, s.Entries, s.Bytes)
+		peers := make([]string, 0, len(s.Peers))
+		for peer := range s.Peers {
+			peers = append(peers, peer)
+		}
+		sort.Strings(peers)
+		for _, peer := range peers {
+			fmt.Fprintf(out, "\t%s: %d entries\n", peer, s.Peers[peer])
+		}
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(out, "error: %s\n", e)
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("drain finished with %d errors", len(report.Errors))
+	}
+	return nil
+}
+
+// call makes a request to the admin handler and decodes its JSON
+// answer into v.
+func call(client *http.Client, method, u string, form url.Values, v interface{}) error {
+	req, err := http.NewRequest(method, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	if method == "POST" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s: %s", u, res.Status, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	var form string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/drain" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			w.Write([]byte(`{"Draining": true}`))
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm.Encode()
+		w.Write([]byte(`{"Groups": {"g": {"Entries": 3, "Bytes": 30, "Peers": {"http://b": 3}}}}`))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	if err := run([]string{"-admin", ts.URL, "drain", "-max-bytes", "1000"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if form != "max_bytes=1000" {
+		t.Errorf("posted %q; want max_bytes=1000", form)
+	}
+	if want := "g: sent 3 entries, 30 bytes\n\thttp://b: 3 entries\n"; out.String() != want {
+		t.Errorf("output %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := run([]string{"-admin", ts.URL, "status"}, &out); err != nil || out.String() != "draining: true\n" {
+		t.Errorf("status: %q, %v", out.String(), err)
+	}
+	if err := run([]string{"-admin", ts.URL, "bogus"}, &out); err == nil {
+		t.Error("unknown command succeeded")
+	}
+}
+
+func TestDrainErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Groups": {}, "Errors": ["g: 2 entries to http://b: refused"]}`))
+	}))
+	defer ts.Close()
+	var out bytes.Buffer
+	err := run([]string{"-admin", ts.URL, "drain"}, &out)
+	if err == nil || !strings.Contains(out.String(), "refused") {
+		t.Errorf("drain = %v, output %q; want the report's error", err, out.String())
+	}
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the groupcached configuration, usually read from a YAML
+// file and then overridden by command line flags.
+type Config struct {
+	// Self is this node's base URL as its peers know it,
+	// e.g. "http://10.0.0.1:8000".
+	Self string `yaml:"self"`
+
+	// SelfAliases are other base URLs under which peers may list
+	// this node.
+	SelfAliases []string `yaml:"self_aliases"`
+
+	// Listen is the address serving peer and client requests.
+	Listen string `yaml:"listen"`
+
+	// AdminListen is the address serving operator endpoints.
+	// If blank, no admin listener is started. If it equals Listen,
+	// the operator endpoints share that listener, below an
+	// AdminBasePath that must not overlap the other paths served.
+	AdminListen string `yaml:"admin_listen"`
+
+	// BasePath is the path below which peers talk to each other.
+	// All peers must agree on it. If blank, it defaults to
+	// "/_groupcache/".
+	BasePath string `yaml:"base_path"`
+
+	// AdminBasePath is the path below which the operator endpoints
+	// are served. If blank, it defaults to "/".
+	AdminBasePath string `yaml:"admin_base_path"`
+
+	// UpstreamCompat lets this node join a cluster of golang/groupcache
+	// peers, for migrating one node at a time.
+	UpstreamCompat bool `yaml:"upstream_compat"`
+
+	// Peers is the static list of peer base URLs, including Self.
+	Peers []string `yaml:"peers"`
+
+	// Discovery optionally finds peers dynamically.
+	Discovery DiscoveryConfig `yaml:"discovery"`
+
+	// TLS optionally enables HTTPS on Listen and for peer requests.
+	TLS TLSConfig `yaml:"tls"`
+
+	// Groups are the cache groups served by this node.
+	Groups []GroupConfig `yaml:"groups"`
+
+	// ReloadInterval is how often the configuration file is checked
+	// for changes, which are then applied as on SIGHUP. If blank,
+	// the file is only reloaded on SIGHUP.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+
+	// NegativeFilterSync is how often the negative filters of the
+	// groups setting negative_filter_bits are fetched from the
+	// peers. If blank, they are not shared.
+	NegativeFilterSync time.Duration `yaml:"negative_filter_sync"`
+
+	// TraceFile optionally names a file the Gets served by every
+	// group are appended to, in the format of the trace package, for
+	// replaying them with loadgen -replay.
+	TraceFile string `yaml:"trace_file"`
+}
+
+// DiscoveryConfig configures DNS based peer discovery. Every address
+// the name resolves to becomes a peer.
+type DiscoveryConfig struct {
+	// DNS is a "host:port" whose A/AAAA records list the peers.
+	DNS string `yaml:"dns"`
+
+	// Scheme is the URL scheme of discovered peers. If blank, it
+	// defaults to "https" when TLS is configured and "http" otherwise.
+	Scheme string `yaml:"scheme"`
+
+	// Interval is how often DNS is re-resolved. If blank, it
+	// defaults to 30s.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// TLSConfig names the certificate files used for HTTPS.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// CAFile optionally names the CA bundle used to verify peers.
+	// If blank, the system roots are used.
+	CAFile string `yaml:"ca_file"`
+}
+
+// Enabled reports whether TLS is configured.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// GroupConfig configures one cache group.
+type GroupConfig struct {
+	Name       string   `yaml:"name"`
+	CacheBytes ByteSize `yaml:"cache_bytes"`
+
+	// TTL is how long values stay cached, plus a random duration
+	// below TTLJitter. If blank, values never expire.
+	TTL       time.Duration `yaml:"ttl"`
+	TTLJitter time.Duration `yaml:"ttl_jitter"`
+
+	// MaxConcurrentLoads bounds the concurrent origin fetches of the
+	// group on this node. If blank, they are not bounded.
+	MaxConcurrentLoads int `yaml:"max_concurrent_loads"`
+
+	// Getter selects and configures the Getter plugin that loads
+	// values missing from the cache.
+	Getter GetterConfig `yaml:"getter"`
+
+	// Memcached optionally lists the host:port addresses of a
+	// memcached cluster shared by the nodes as a second-level cache,
+	// consulted before the origin and filled with what it returns.
+	Memcached []string `yaml:"memcached"`
+
+	// NegativeFilterBits optionally sizes a Bloom filter of the keys
+	// the origin doesn't have, remembered for between one and two
+	// NegativeFilterTTLs (default one minute). Nodes sharing filters
+	// must use the same size.
+	NegativeFilterBits int           `yaml:"negative_filter_bits"`
+	NegativeFilterTTL  time.Duration `yaml:"negative_filter_ttl"`
+}
+
+// GetterConfig configures a Getter plugin. Type selects the plugin;
+// the remaining fields are interpreted by it.
+type GetterConfig struct {
+	Type string `yaml:"type"`
+
+	// URL is the origin URL template for the "http" plugin. The
+	// string "{key}" is replaced by the path-escaped key.
+	URL string `yaml:"url"`
+
+	// Timeout bounds each origin fetch. If blank, it defaults to 10s.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Headers are added to every origin request.
+	Headers map[string]string `yaml:"headers"`
+
+	// MaxValueBytes rejects origin values larger than this.
+	// If blank, it defaults to 64MB.
+	MaxValueBytes ByteSize `yaml:"max_value_bytes"`
+}
+
+// overlap reports whether either path is below the other.
+func overlap(a, b string) bool {
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// A ByteSize is a number of bytes that may be written in YAML with a
+// KB, MB, GB or TB suffix (powers of 1024).
+type ByteSize int64
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (b *ByteSize) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	n, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = n
+	return nil
+}
+
+var byteSuffixes = []struct {
+	suffix string
+	mult   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses sizes such as "512", "64KB" or "1.5GB".
+func ParseByteSize(s string) (ByteSize, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, bs := range byteSuffixes {
+		if strings.HasSuffix(t, bs.suffix) {
+			t = strings.TrimSpace(strings.TrimSuffix(t, bs.suffix))
+			mult = bs.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return ByteSize(f * float64(mult)), nil
+}
+
+// LoadConfig reads a YAML configuration file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(b)
+}
+
+// ParseConfig parses a YAML configuration.
+func ParseConfig(b []byte) (*Config, error) {
+	c := new(Config)
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate fills in defaults and reports the first configuration
+// error found.
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return errors.New("listen address is required")
+	}
+	if c.Self == "" {
+		return errors.New("self URL is required")
+	}
+	if len(c.Groups) == 0 {
+		return errors.New("at least one group is required")
+	}
+	if c.TLS.Enabled() && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return errors.New("tls needs both cert_file and key_file")
+	}
+	if c.BasePath == "" {
+		c.BasePath = "/_groupcache/"
+	}
+	if c.AdminBasePath == "" {
+		c.AdminBasePath = "/"
+	}
+	for _, p := range []string{c.BasePath, c.AdminBasePath} {
+		if !strings.HasPrefix(p, "/") || !strings.HasSuffix(p, "/") {
+			return fmt.Errorf("path %q must start and end with a slash", p)
+		}
+	}
+	if overlap(c.BasePath, clientPath) {
+		return fmt.Errorf("base_path %q overlaps the client path %q", c.BasePath, clientPath)
+	}
+	if c.AdminListen == c.Listen {
+		for _, p := range []string{c.BasePath, clientPath} {
+			if overlap(c.AdminBasePath, p) {
+				return fmt.Errorf("admin_base_path %q overlaps %q on the shared listener", c.AdminBasePath, p)
+			}
+		}
+	}
+	if c.Discovery.DNS != "" {
+		if c.Discovery.Interval == 0 {
+			c.Discovery.Interval = 30 * time.Second
+		}
+		if c.Discovery.Scheme == "" {
+			c.Discovery.Scheme = "http"
+			if c.TLS.Enabled() {
+				c.Discovery.Scheme = "https"
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		if g.Name == "" {
+			return fmt.Errorf("group #%d has no name", i+1)
+		}
+		if seen[g.Name] {
+			return fmt.Errorf("duplicate group %q", g.Name)
+		}
+		seen[g.Name] = true
+		if _, ok := getterPlugins[g.Getter.Type]; !ok {
+			return fmt.Errorf("group %q: unknown getter type %q", g.Name, g.Getter.Type)
+		}
+		if g.Getter.Timeout == 0 {
+			g.Getter.Timeout = 10 * time.Second
+		}
+		if g.Getter.MaxValueBytes == 0 {
+			g.Getter.MaxValueBytes = 64 << 20
+		}
+		if g.TTL < 0 || g.TTLJitter < 0 || g.MaxConcurrentLoads < 0 {
+			return fmt.Errorf("group %q: ttl, ttl_jitter and max_concurrent_loads must not be negative", g.Name)
+		}
+		if g.NegativeFilterBits < 0 || g.NegativeFilterTTL < 0 {
+			return fmt.Errorf("group %q: negative_filter_bits and negative_filter_ttl must not be negative", g.Name)
+		}
+	}
+	return nil
+}
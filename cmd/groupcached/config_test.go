@@ -0,0 +1,165 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+const testConfig = `
+self: http://10.0.0.1:8000
+listen: :8000
+peers: [http://10.0.0.1:8000, http://10.0.0.2:8000]
+discovery:
+  dns: cache.internal:8000
+groups:
+  - name: thumbs
+    cache_bytes: 64MB
+    getter:
+      type: http
+      url: https://origin/thumbs/{key}
+      timeout: 5s
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Peers) != 2 {
+		t.Errorf("peers = %v; want 2 of them", c.Peers)
+	}
+	g := c.Groups[0]
+	if g.CacheBytes != 64<<20 {
+		t.Errorf("cache_bytes = %d; want %d", g.CacheBytes, 64<<20)
+	}
+	if g.Getter.Timeout != 5*time.Second {
+		t.Errorf("timeout = %v; want 5s", g.Getter.Timeout)
+	}
+	if g.Getter.MaxValueBytes != 64<<20 {
+		t.Errorf("max_value_bytes default = %d; want %d", g.Getter.MaxValueBytes, 64<<20)
+	}
+	if c.Discovery.Interval != 30*time.Second || c.Discovery.Scheme != "http" {
+		t.Errorf("discovery defaults = %v %q; want 30s \"http\"", c.Discovery.Interval, c.Discovery.Scheme)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want string
+	}{
+		{"self: x\ngroups: [{name: a, getter: {type: http}}]", "listen"},
+		{"listen: :1\ngroups: [{name: a, getter: {type: http}}]", "self"},
+		{"listen: :1\nself: x", "group"},
+		{"listen: :1\nself: x\ngroups: [{name: a, getter: {type: ftp}}]", "unknown getter"},
+		{"listen: :1\nself: x\ngroups: [{name: a, getter: {type: http}}, {name: a, getter: {type: http}}]", "duplicate"},
+		{"listen: :1\nself: x\nbase_path: /peers\ngroups: [{name: a, getter: {type: http}}]", "slash"},
+		{"listen: :1\nself: x\nbase_path: /cache/peers/\ngroups: [{name: a, getter: {type: http}}]", "client path"},
+		{"listen: :1\nadmin_listen: :1\nself: x\ngroups: [{name: a, getter: {type: http}}]", "admin_base_path"},
+	}
+	for _, tt := range tests {
+		c, err := ParseConfig([]byte(tt.yaml))
+		if err != nil {
+			t.Fatalf("ParseConfig(%q): %v", tt.yaml, err)
+		}
+		err = c.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%q) = %v; want error mentioning %q", tt.yaml, err, tt.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"512":   512,
+		"64KB":  64 << 10,
+		"1.5GB": 3 << 29,
+		"2 mb":  2 << 20,
+	}
+	for in, want := range tests {
+		got, err := ParseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseByteSize("lots"); err == nil {
+		t.Error("ParseByteSize(\"lots\") succeeded")
+	}
+}
+
+func TestHTTPOriginGetter(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("origin" + r.URL.Path))
+	}))
+	defer origin.Close()
+
+	g, err := newHTTPOriginGetter(GetterConfig{
+		URL:           origin.URL + "/{key}",
+		Timeout:       time.Second,
+		MaxValueBytes: 100,
+	}, origin.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := g.Get(context.Background(), "a b", groupcache.StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "origin/a b"; s != want {
+		t.Errorf("Get = %q; want %q", s, want)
+	}
+	if err := g.Get(context.Background(), "missing", groupcache.StringSink(&s)); err == nil {
+		t.Error("Get of a missing key succeeded")
+	}
+}
+
+func TestAdminBasePath(t *testing.T) {
+	c, err := ParseConfig([]byte("listen: :1\nadmin_listen: :1\nadmin_base_path: /admin/\nself: x\ngroups: [{name: a, getter: {type: http}}]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.BasePath != "/_groupcache/" {
+		t.Errorf("base_path default = %q; want /_groupcache/", c.BasePath)
+	}
+
+	h := adminHandler(groupcache.NewHTTPPoolOpts(c.Self, nil), c.AdminBasePath)
+	for path, want := range map[string]int{"/admin/healthz": http.StatusOK, "/healthz": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s = %d; want %d", path, rec.Code, want)
+		}
+	}
+}
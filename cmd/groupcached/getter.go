@@ -0,0 +1,82 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang/groupcache"
+)
+
+// A getterPlugin builds the Getter of a group from its configuration.
+type getterPlugin func(c GetterConfig, client *http.Client) (groupcache.Getter, error)
+
+// getterPlugins are the Getter implementations selectable with the
+// getter "type" configuration key.
+var getterPlugins = map[string]getterPlugin{
+	"http": newHTTPOriginGetter,
+}
+
+// newHTTPOriginGetter returns a Getter fetching each key from an
+// HTTP origin. Any status other than 200 is a load error.
+func newHTTPOriginGetter(c GetterConfig, client *http.Client) (groupcache.Getter, error) {
+	if !strings.Contains(c.URL, "{key}") {
+		return nil, fmt.Errorf("http getter url %q has no {key} placeholder", c.URL)
+	}
+	if _, err := url.Parse(strings.Replace(c.URL, "{key}", "k", -1)); err != nil {
+		return nil, fmt.Errorf("http getter url: %v", err)
+	}
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+		u := strings.Replace(c.URL, "{key}", url.PathEscape(key), -1)
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		for k, v := range c.Headers {
+			req.Header.Set(k, v)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: origin returned: %v", groupcache.ErrNotFound, res.Status)
+		}
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("origin returned: %v", res.Status)
+		}
+		// Read one byte past the limit to detect oversized values.
+		b, err := ioutil.ReadAll(io.LimitReader(res.Body, int64(c.MaxValueBytes)+1))
+		if err != nil {
+			return fmt.Errorf("reading origin response: %v", err)
+		}
+		if int64(len(b)) > int64(c.MaxValueBytes) {
+			return fmt.Errorf("%w: origin value exceeds max_value_bytes", groupcache.ErrValueTooLarge)
+		}
+		return dest.SetBytes(b)
+	}), nil
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command groupcached runs groupcache as a standalone cache tier.
+//
+// Groups, cache sizes, peers and origins are described by a YAML file:
+//
+//	self: http://10.0.0.1:8000
+//	listen: :8000
+//	admin_listen: 127.0.0.1:8001
+//	peers: [http://10.0.0.1:8000, http://10.0.0.2:8000]
+//	groups:
+//	  - name: thumbnails
+//	    cache_bytes: 512MB
+//	    getter:
+//	      type: http
+//	      url: https://origin.example.com/thumbs/{key}
+//	      timeout: 5s
+//
+// Clients fetch values with GET /cache/<group>/<key> on the listen
+// address, adding ?refresh=1 to reload a value changed at its origin.
+// Peers talk to each other under base_path (by default
+// /_groupcache/) on the same address. The admin address, which may be
+// the listen address if admin_base_path sets the endpoints apart,
+// serves below admin_base_path /healthz, /stats for this node,
+// /stats/cluster for the sum over all peers, /inflight listing the
+// origin and peer loads in progress, /peers with the connection
+// statistics of each peer, /ring and /hotkeys with the key space owned
+// by each peer and the hot keys of each group, and /dashboard, a page
+// showing them all. Below /debug/ it serves dumps of cache internals.
+//
+// On SIGHUP, and when reload_interval is set and the file changes, the
+// configuration is read again. Peers, cache_bytes, ttl, ttl_jitter and
+// max_concurrent_loads changes are applied without dropping cached
+// values; a configuration changing anything else, or failing to
+// validate, is rejected as a whole and the node keeps running with the
+// old one.
+//
+// On SIGINT or SIGTERM the node tells its peers it is leaving, so they
+// stop routing keys to it, and then shuts down gracefully.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/golang/groupcache/memcachestore"
+	"github.com/golang/groupcache/trace"
+)
+
+var (
+	configFile  = flag.String("config", "", "path to the YAML configuration file")
+	listen      = flag.String("listen", "", "address serving peer and client requests; overrides the config")
+	adminListen = flag.String("admin", "", "address serving operator endpoints; overrides the config")
+	self        = flag.String("self", "", "this node's base URL as its peers know it; overrides the config")
+	peers       = flag.String("peers", "", "comma-separated peer base URLs; overrides the config")
+)
+
+const clientPath = "/cache/"
+
+func main() {
+	flag.Parse()
+	c := new(Config)
+	if *configFile != "" {
+		var err error
+		if c, err = LoadConfig(*configFile); err != nil {
+			log.Fatalf("groupcached: %v", err)
+		}
+	}
+	applyFlags(c)
+	if err := c.Validate(); err != nil {
+		log.Fatalf("groupcached: invalid configuration: %v", err)
+	}
+
+	client, err := peerClient(c.TLS)
+	if err != nil {
+		log.Fatalf("groupcached: %v", err)
+	}
+	pool := groupcache.NewHTTPPoolOpts(c.Self, &groupcache.HTTPPoolOptions{
+		BasePath:       c.BasePath,
+		SelfAliases:    c.SelfAliases,
+		UpstreamCompat: c.UpstreamCompat,
+	})
+	pool.Transport = func(context.Context) http.RoundTripper { return client.Transport }
+	pool.Set(c.Peers...)
+	var rec *trace.Recorder
+	if c.TraceFile != "" {
+		f, err := os.OpenFile(c.TraceFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Fatalf("groupcached: %v", err)
+		}
+		rec = trace.NewRecorder(f, nil)
+	}
+	groups, err := newGroups(c.Groups, client, rec)
+	if err != nil {
+		log.Fatalf("groupcached: %v", err)
+	}
+	r := newReloader(*configFile, c, pool, groups)
+	if c.Discovery.DNS != "" {
+		go discoverPeers(pool, r)
+	}
+	if c.NegativeFilterSync > 0 {
+		go pool.SyncNegativeFilters(context.Background(), c.NegativeFilterSync, func(err error) {
+			log.Printf("groupcached: %v", err)
+		})
+	}
+	if *configFile != "" {
+		go r.reloadOnSignal()
+		if c.ReloadInterval > 0 {
+			go r.watch(c.ReloadInterval)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(c.BasePath, pool.Handler())
+	mux.Handle(clientPath, clientHandler{})
+	switch admin := adminHandler(pool, c.AdminBasePath); c.AdminListen {
+	case "":
+	case c.Listen:
+		mux.Handle(c.AdminBasePath, admin)
+	default:
+		go func() {
+			log.Fatal(http.ListenAndServe(c.AdminListen, admin))
+		}()
+	}
+	srv := &http.Server{Addr: c.Listen, Handler: mux}
+	go leaveOnSignal(pool, srv)
+	log.Printf("groupcached: serving %d groups on %s as %s", len(groups), c.Listen, c.Self)
+	if c.TLS.Enabled() {
+		err = srv.ListenAndServeTLS(c.TLS.CertFile, c.TLS.KeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	if rec != nil {
+		if err := rec.Close(); err != nil {
+			log.Printf("groupcached: writing trace: %v", err)
+		}
+	}
+}
+
+// leaveOnSignal waits for SIGINT or SIGTERM, then tells the peers that
+// this node is leaving and shuts the server down gracefully.
+func leaveOnSignal(pool *groupcache.HTTPPool, srv *http.Server) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := pool.Leave(ctx); err != nil {
+		log.Printf("groupcached: %v", err)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("groupcached: shutdown: %v", err)
+	}
+}
+
+// applyFlags overrides configuration values with any flags given.
+func applyFlags(c *Config) {
+	if *listen != "" {
+		c.Listen = *listen
+	}
+	if *adminListen != "" {
+		c.AdminListen = *adminListen
+	}
+	if *self != "" {
+		c.Self = *self
+	}
+	if *peers != "" {
+		c.Peers = strings.Split(*peers, ",")
+	}
+}
+
+// peerClient returns the HTTP client used for peer and origin
+// requests, trusting the configured CA bundle if any.
+func peerClient(c TLSConfig) (*http.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + c.CAFile)
+		}
+		tr.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	return &http.Client{Transport: tr}, nil
+}
+
+func newGroups(configs []GroupConfig, client *http.Client, rec *trace.Recorder) ([]*groupcache.Group, error) {
+	var groups []*groupcache.Group
+	for _, gc := range configs {
+		getter, err := getterPlugins[gc.Getter.Type](gc.Getter, client)
+		if err != nil {
+			return nil, errors.New("group " + gc.Name + ": " + err.Error())
+		}
+		opts := &groupcache.GroupOptions{
+			TTL:                gc.TTL,
+			TTLJitter:          gc.TTLJitter,
+			MaxConcurrentLoads: gc.MaxConcurrentLoads,
+			NegativeFilterBits: gc.NegativeFilterBits,
+			NegativeFilterTTL:  gc.NegativeFilterTTL,
+		}
+		if rec != nil {
+			opts.Recorder = rec
+		}
+		if len(gc.Memcached) > 0 {
+			store := memcachestore.New(gc.Memcached, &memcachestore.Options{KeyPrefix: gc.Name + ":"})
+			name := gc.Name
+			opts.Middleware = append(opts.Middleware, groupcache.WithBackingStore(store, func(err error) {
+				log.Printf("groupcached: group %s: memcached: %v", name, err)
+			}))
+		}
+		groups = append(groups, groupcache.NewGroupOpts(gc.Name, int64(gc.CacheBytes), getter, opts))
+	}
+	return groups, nil
+}
+
+// discoverPeers periodically resolves the discovery name and replaces
+// the pool's peers whenever the answer changes. Static peers from the
+// current configuration are always kept.
+func discoverPeers(pool *groupcache.HTTPPool, r *reloader) {
+	host, port, err := net.SplitHostPort(r.config().Discovery.DNS)
+	if err != nil {
+		log.Fatalf("groupcached: discovery dns: %v", err)
+	}
+	var last string
+	for {
+		c := r.config()
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			log.Printf("groupcached: discovering peers: %v", err)
+		} else {
+			set := make(map[string]bool)
+			for _, p := range c.Peers {
+				set[p] = true
+			}
+			for _, a := range addrs {
+				set[c.Discovery.Scheme+"://"+net.JoinHostPort(a, port)] = true
+			}
+			list := make([]string, 0, len(set))
+			for p := range set {
+				list = append(list, p)
+			}
+			sort.Strings(list)
+			if s := strings.Join(list, ","); s != last {
+				log.Printf("groupcached: peers are now %s", s)
+				pool.Set(list...)
+				last = s
+			}
+		}
+		time.Sleep(c.Discovery.Interval)
+	}
+}
+
+// clientHandler serves GET /cache/<group>/<key> with the raw value,
+// reloading it first if the refresh parameter is 1.
+type clientHandler struct{}
+
+func (clientHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, clientPath), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		http.Error(w, "want "+clientPath+"<group>/<key>", http.StatusBadRequest)
+		return
+	}
+	g := groupcache.GetGroup(parts[0])
+	if g == nil {
+		http.Error(w, "no such group: "+parts[0], http.StatusNotFound)
+		return
+	}
+	var opts []groupcache.GetOption
+	if r.FormValue("refresh") == "1" {
+		opts = append(opts, groupcache.ForceRefresh())
+	}
+	var v groupcache.ByteView
+	if err := g.Get(r.Context(), parts[1], groupcache.ByteViewSink(&v), opts...); err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(err, groupcache.ErrNotFound) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	v.WriteTo(w)
+}
+
+// adminHandler returns the handler of the operator endpoints, served
+// below basePath.
+func adminHandler(pool *groupcache.HTTPPool, basePath string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	stats := pool.AdminHandler()
+	mux.Handle("/stats", stats)
+	mux.Handle("/stats/cluster", stats)
+	mux.Handle("/inflight", stats)
+	mux.Handle("/peers", stats)
+	mux.Handle("/ring", stats)
+	mux.Handle("/hotkeys", stats)
+	mux.Handle("/dashboard", stats)
+	mux.Handle("/debug/", http.StripPrefix("/debug", pool.DebugHandler()))
+	if basePath == "/" {
+		return mux
+	}
+	outer := http.NewServeMux()
+	outer.Handle(basePath, http.StripPrefix(strings.TrimSuffix(basePath, "/"), mux))
+	return outer
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// A peerSetter replaces the peers of a pool.
+type peerSetter interface {
+	Set(peers ...string)
+}
+
+// A reloader applies changes of the configuration file to the running
+// node.
+type reloader struct {
+	path   string
+	pool   peerSetter
+	groups map[string]*groupcache.Group
+
+	mu      sync.Mutex
+	current *Config
+	modTime time.Time
+}
+
+func newReloader(path string, c *Config, pool peerSetter, groups []*groupcache.Group) *reloader {
+	r := &reloader{path: path, pool: pool, current: c, groups: make(map[string]*groupcache.Group)}
+	for _, g := range groups {
+		r.groups[g.Name()] = g
+	}
+	if fi, err := os.Stat(path); err == nil {
+		r.modTime = fi.ModTime()
+	}
+	return r
+}
+
+// config returns the configuration in effect.
+func (r *reloader) config() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// reload reads the configuration file and applies it. Nothing is
+// applied if the file is invalid or changes settings that need a
+// restart.
+func (r *reloader) reload() error {
+	c, err := LoadConfig(r.path)
+	if err != nil {
+		return err
+	}
+	applyFlags(c)
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := restartNeeded(r.current, c); err != nil {
+		return err
+	}
+	for _, gc := range c.Groups {
+		g := r.groups[gc.Name]
+		g.SetCacheBytes(int64(gc.CacheBytes))
+		g.SetTTL(gc.TTL, gc.TTLJitter)
+		g.SetMaxConcurrentLoads(gc.MaxConcurrentLoads)
+	}
+	if c.Discovery.DNS == "" && !reflect.DeepEqual(c.Peers, r.current.Peers) {
+		// With discovery, its next lookup picks up the static peers.
+		r.pool.Set(c.Peers...)
+	}
+	r.current = c
+	return nil
+}
+
+// restartNeeded returns an error naming the first change from old to c
+// that cannot be applied to a running node.
+func restartNeeded(old, c *Config) error {
+	fixed := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"self", old.Self, c.Self},
+		{"self_aliases", old.SelfAliases, c.SelfAliases},
+		{"listen", old.Listen, c.Listen},
+		{"admin_listen", old.AdminListen, c.AdminListen},
+		{"base_path", old.BasePath, c.BasePath},
+		{"admin_base_path", old.AdminBasePath, c.AdminBasePath},
+		{"upstream_compat", old.UpstreamCompat, c.UpstreamCompat},
+		{"tls", old.TLS, c.TLS},
+		{"discovery", old.Discovery, c.Discovery},
+		{"reload_interval", old.ReloadInterval, c.ReloadInterval},
+		{"negative_filter_sync", old.NegativeFilterSync, c.NegativeFilterSync},
+		{"trace_file", old.TraceFile, c.TraceFile},
+	}
+	for _, f := range fixed {
+		if !reflect.DeepEqual(f.old, f.new) {
+			return fmt.Errorf("%s changed; restart to apply it", f.name)
+		}
+	}
+	if len(old.Groups) != len(c.Groups) {
+		return fmt.Errorf("groups added or removed; restart to apply it")
+	}
+	for i, gc := range c.Groups {
+		if gc.Name != old.Groups[i].Name {
+			return fmt.Errorf("groups added, removed or reordered; restart to apply it")
+		}
+		if !reflect.DeepEqual(gc.Getter, old.Groups[i].Getter) {
+			return fmt.Errorf("group %q: getter changed; restart to apply it", gc.Name)
+		}
+		if !reflect.DeepEqual(gc.Memcached, old.Groups[i].Memcached) {
+			return fmt.Errorf("group %q: memcached changed; restart to apply it", gc.Name)
+		}
+		if o := old.Groups[i]; gc.NegativeFilterBits != o.NegativeFilterBits || gc.NegativeFilterTTL != o.NegativeFilterTTL {
+			return fmt.Errorf("group %q: negative filter changed; restart to apply it", gc.Name)
+		}
+	}
+	return nil
+}
+
+// reloadOnSignal reloads the configuration on each SIGHUP.
+func (r *reloader) reloadOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		r.logReload()
+	}
+}
+
+// watch reloads the configuration whenever the file's modification
+// time changes, checking every interval.
+func (r *reloader) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		fi, err := os.Stat(r.path)
+		if err != nil {
+			log.Printf("groupcached: watching configuration: %v", err)
+			continue
+		}
+		r.mu.Lock()
+		changed := !fi.ModTime().Equal(r.modTime)
+		r.modTime = fi.ModTime()
+		r.mu.Unlock()
+		if changed {
+			r.logReload()
+		}
+	}
+}
+
+func (r *reloader) logReload() {
+	if err := r.reload(); err != nil {
+		log.Printf("groupcached: configuration not reloaded: %v", err)
+		return
+	}
+	log.Printf("groupcached: configuration reloaded from %s", r.path)
+}
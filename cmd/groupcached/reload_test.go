@@ -0,0 +1,130 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingPool struct{ peers []string }
+
+func (p *recordingPool) Set(peers ...string) { p.peers = peers }
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "groupcached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	write := func(peers, cacheBytes, extra string) {
+		conf := fmt.Sprintf(`
+self: http://a:8000
+listen: :8000
+peers: [%s]
+groups:
+  - name: TestReload-group
+    cache_bytes: %s
+    ttl: 1m
+    getter:
+      type: http
+      url: http://origin/{key}
+%s`, peers, cacheBytes, extra)
+		if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("http://a:8000", "1MB", "")
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := newGroups(c.Groups, http.DefaultClient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := new(recordingPool)
+	r := newReloader(path, c, pool, groups)
+
+	write("http://a:8000, http://b:8000", "2MB", "    max_concurrent_loads: 4")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := groups[0].CacheBytes(); got != 2<<20 {
+		t.Errorf("cache bytes = %d after reload; want %d", got, 2<<20)
+	}
+	if strings.Join(pool.peers, ",") != "http://a:8000,http://b:8000" {
+		t.Errorf("peers = %v after reload; want a and b", pool.peers)
+	}
+
+	write("http://a:8000", "3MB", "listen_typo: x")
+	if err := r.reload(); err == nil {
+		t.Errorf("reloading an invalid file succeeded")
+	}
+	write("http://a:8000", "3MB", "    ttl_jitter: -1s")
+	if err := r.reload(); err == nil {
+		t.Errorf("reloading an invalid group succeeded")
+	}
+	if got := groups[0].CacheBytes(); got != 2<<20 {
+		t.Errorf("cache bytes = %d after failed reloads; want %d unchanged", got, 2<<20)
+	}
+	if r.config().Groups[0].MaxConcurrentLoads != 4 {
+		t.Errorf("configuration replaced by a failed reload")
+	}
+}
+
+func TestRestartNeeded(t *testing.T) {
+	base := func() *Config {
+		c, err := ParseConfig([]byte(testConfig))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Validate()
+		return c
+	}
+	tests := []struct {
+		change func(c *Config)
+		want   string
+	}{
+		{func(c *Config) { c.Peers = nil; c.Groups[0].CacheBytes = 1; c.Groups[0].TTL = time.Hour }, ""},
+		{func(c *Config) { c.Listen = ":9000" }, "listen"},
+		{func(c *Config) { c.Groups[0].Getter.URL = "http://elsewhere/{key}" }, "getter"},
+		{func(c *Config) { c.Groups = append(c.Groups, GroupConfig{Name: "new"}) }, "groups"},
+	}
+	for i, tt := range tests {
+		c := base()
+		tt.change(c)
+		err := restartNeeded(base(), c)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%d: restartNeeded = %v; want nil", i, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%d: restartNeeded = %v; want an error mentioning %q", i, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command loadgen drives a running groupcached cluster with synthetic
+// traffic and reports latency percentiles and hit rates, for capacity
+// planning.
+//
+// Reads fetch /cache/<group>/<key> from the targets; writes, chosen
+// with probability -writes, add ?refresh=1 so the value is reloaded
+// from its origin, as after an update there:
+//
+//	loadgen -targets http://10.0.0.1:8000,http://10.0.0.2:8000 \
+//		-group thumbnails -keys 100000 -dist zipf -writes 0.01 \
+//		-admin http://10.0.0.1:8001
+//
+// With -origin, loadgen also serves a synthetic origin whose values
+// have sizes drawn from -value-size; point a group's http getter at
+// it, e.g. url: http://loadgen-host:9000/{key}. Hit rates are read
+// from the cluster statistics at -admin before and after the run.
+//
+// With -replay, loadgen sends the Gets of a trace recorded by the
+// trace package instead, at -speed times their recorded pace, to the
+// group named by each record unless -group is set. The origin then
+// serves the values of the recorded sizes:
+//
+//	loadgen -replay prod.trace -speed 2 -origin :9000 \
+//		-targets http://10.0.0.1:8000 -admin http://10.0.0.1:8001 -group thumbnails
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/golang/groupcache/benchmarks"
+	"github.com/golang/groupcache/trace"
+)
+
+var (
+	targets   = flag.String("targets", "", "comma-separated base URLs of the nodes to send requests to")
+	group     = flag.String("group", "", "name of the group to read")
+	admin     = flag.String("admin", "", "admin base URL of a node, for the cluster's hit rates")
+	origin    = flag.String("origin", "", "address to serve a synthetic origin on")
+	nkeys     = flag.Int("keys", 10000, "number of distinct keys")
+	dist      = flag.String("dist", "zipf", "key distribution: uniform, zipf or sequential")
+	zipfS     = flag.Float64("zipf-s", 1.1, "exponent of the zipf distribution; greater than 1")
+	valueSize = flag.String("value-size", "1024", "size of origin values in bytes, or a min-max range")
+	writes    = flag.Float64("writes", 0, "fraction of requests that refresh the value")
+	workers   = flag.Int("workers", 16, "number of concurrent workers")
+	ops       = flag.Int("ops", 10000, "requests per worker")
+	seed      = flag.Int64("seed", 1, "random seed")
+	timeout   = flag.Duration("timeout", 10*time.Second, "timeout of each request")
+	replay    = flag.String("replay", "", "trace file whose Gets to send instead of generated keys")
+	speed     = flag.Float64("speed", 1, "pace of -replay relative to the recording; 0 sends as fast as possible")
+)
+
+func main() {
+	flag.Parse()
+	var recs []trace.Record
+	if *replay != "" {
+		f, err := os.Open(*replay)
+		if err != nil {
+			log.Fatalf("loadgen: %v", err)
+		}
+		recs, err = trace.ReadAll(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("loadgen: reading %s: %v", *replay, err)
+		}
+	}
+	if *origin != "" {
+		h := originHandler{}
+		if recs != nil {
+			h.sizes = trace.Sizes(recs)
+		} else {
+			var err error
+			if h.min, h.max, err = parseSizeRange(*valueSize); err != nil {
+				log.Fatalf("loadgen: -value-size: %v", err)
+			}
+		}
+		go func() {
+			log.Fatal(http.ListenAndServe(*origin, h))
+		}()
+	}
+	if recs != nil && *targets != "" {
+		replayTrace(recs)
+		return
+	}
+	if *targets == "" || *group == "" {
+		if *origin != "" {
+			// Only serve the origin.
+			select {}
+		}
+		log.Fatal("loadgen: -targets and -group are required")
+	}
+	keys, err := keyGenerator(*dist, *nkeys, *zipfS)
+	if err != nil {
+		log.Fatalf("loadgen: %v", err)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	var before groupcache.GroupStats
+	if *admin != "" {
+		if before, err = groupStats(client, *admin, *group); err != nil {
+			log.Fatalf("loadgen: reading cluster stats: %v", err)
+		}
+	}
+	l := &loadgen{
+		client:  client,
+		targets: strings.Split(*targets, ","),
+		group:   *group,
+		writes:  *writes,
+	}
+	res := benchmarks.Run(benchmarks.Workload{
+		Workers: *workers,
+		Ops:     *ops,
+		Keys:    func(w int) benchmarks.KeyGenerator { return keys(*seed + int64(w)) },
+		Op:      l.op,
+	})
+	fmt.Println(res)
+	fmt.Printf("%d reads, %d writes, %d bytes read\n",
+		atomic.LoadInt64(&l.nread), atomic.LoadInt64(&l.nwrite), atomic.LoadInt64(&l.nbytes))
+	if *admin != "" {
+		after, err := groupStats(client, *admin, *group)
+		if err != nil {
+			log.Fatalf("loadgen: reading cluster stats: %v", err)
+		}
+		fmt.Println(hitRates(before, after))
+	}
+}
+
+// replayTrace sends the Gets of recs to the targets.
+func replayTrace(recs []trace.Record) {
+	client := &http.Client{Timeout: *timeout}
+	var before groupcache.GroupStats
+	var err error
+	if *admin != "" && *group != "" {
+		if before, err = groupStats(client, *admin, *group); err != nil {
+			log.Fatalf("loadgen: reading cluster stats: %v", err)
+		}
+	}
+	l := &loadgen{client: client, targets: strings.Split(*targets, ",")}
+	res := trace.Replay(context.Background(), recs, func(_ context.Context, rec trace.Record) error {
+		g := *group
+		if g == "" {
+			g = rec.Group
+		}
+		return l.get(g, rec.KeyHash, false)
+	}, &trace.ReplayOptions{Speed: *speed, Workers: *workers})
+	fmt.Println(res)
+	fmt.Printf("%d reads, %d bytes read\n", atomic.LoadInt64(&l.nread), atomic.LoadInt64(&l.nbytes))
+	if *admin != "" && *group != "" {
+		after, err := groupStats(client, *admin, *group)
+		if err != nil {
+			log.Fatalf("loadgen: reading cluster stats: %v", err)
+		}
+		fmt.Println(hitRates(before, after))
+	}
+}
+
+// keyGenerator returns a function making the generators of dist.
+func keyGenerator(dist string, n int, s float64) (func(seed int64) benchmarks.KeyGenerator, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("-keys must be positive, not %d", n)
+	}
+	switch dist {
+	case "uniform":
+		return func(seed int64) benchmarks.KeyGenerator { return benchmarks.NewUniform(n, seed) }, nil
+	case "zipf":
+		if s <= 1 {
+			return nil, fmt.Errorf("-zipf-s must be greater than 1, not %v", s)
+		}
+		return func(seed int64) benchmarks.KeyGenerator { return benchmarks.NewZipf(n, s, seed) }, nil
+	case "sequential":
+		return func(int64) benchmarks.KeyGenerator { return benchmarks.NewSequential(n) }, nil
+	}
+	return nil, fmt.Errorf("unknown key distribution %q", dist)
+}
+
+// loadgen sends the requests of the workload.
+type loadgen struct {
+	client  *http.Client
+	targets []string
+	group   string
+	writes  float64
+
+	next                  uint64 // round-robin index into targets
+	nread, nwrite, nbytes int64
+}
+
+func (l *loadgen) op(key string) error {
+	return l.get(l.group, key, l.writes > 0 && rand.Float64() < l.writes)
+}
+
+// get requests key of group from the next target, refreshing it if
+// write is set.
+func (l *loadgen) get(group, key string, write bool) error {
+	target := l.targets[atomic.AddUint64(&l.next, 1)%uint64(len(l.targets))]
+	u := strings.TrimSuffix(target, "/") + "/cache/" + url.PathEscape(group) + "/" + url.PathEscape(key)
+	if write {
+		u += "?refresh=1"
+		atomic.AddInt64(&l.nwrite, 1)
+	} else {
+		atomic.AddInt64(&l.nread, 1)
+	}
+	res, err := l.client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	n, err := io.Copy(ioutil.Discard, res.Body)
+	atomic.AddInt64(&l.nbytes, n)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, res.Status)
+	}
+	return nil
+}
+
+// groupStats returns the cluster-wide statistics of group, read from
+// the admin endpoint at base.
+func groupStats(client *http.Client, base, group string) (groupcache.GroupStats, error) {
+	res, err := client.Get(strings.TrimSuffix(base, "/") + "/stats/cluster")
+	if err != nil {
+		return groupcache.GroupStats{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return groupcache.GroupStats{}, fmt.Errorf("server returned: %v", res.Status)
+	}
+	var cs groupcache.ClusterStats
+	if err := json.NewDecoder(res.Body).Decode(&cs); err != nil {
+		return groupcache.GroupStats{}, err
+	}
+	for node, msg := range cs.Errors {
+		log.Printf("loadgen: no stats from %s: %s", node, msg)
+	}
+	return cs.Groups[group], nil
+}
+
+// hitRates describes the hit rates of the requests made between the
+// before and after statistics of a group.
+func hitRates(before, after groupcache.GroupStats) string {
+	gets := after.Gets - before.Gets
+	if gets <= 0 {
+		return "no gets recorded by the cluster"
+	}
+	hits := after.CacheHits - before.CacheHits
+	peer := after.PeerLoads - before.PeerLoads
+	local := after.LocalLoads - before.LocalLoads
+	return fmt.Sprintf("cluster: %d gets, hit rate %.1f%%, %d peer loads, %d origin loads (%.1f%% of gets)",
+		gets, 100*float64(hits)/float64(gets), peer, local, 100*float64(local)/float64(gets))
+}
+
+// parseSizeRange parses a size in bytes, "n", or a range, "min-max".
+func parseSizeRange(s string) (min, max int, err error) {
+	lo, hi := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	if min, err = strconv.Atoi(lo); err != nil {
+		return 0, 0, err
+	}
+	if max, err = strconv.Atoi(hi); err != nil {
+		return 0, 0, err
+	}
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("bad size range %q", s)
+	}
+	return min, max, nil
+}
+
+// originHandler serves synthetic values. Each key's value has a size
+// between min and max bytes that depends only on the key, so that
+// every node loading it gets the same value. If sizes is not nil, it
+// holds the size of each key instead, and other keys are not found.
+type originHandler struct {
+	min, max int
+	sizes    map[string]int
+}
+
+func (h originHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	f := fnv.New64a()
+	io.WriteString(f, key)
+	sum := f.Sum64()
+	n := h.min
+	if h.sizes != nil {
+		var ok bool
+		if n, ok = h.sizes[key]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	} else if h.max > h.min {
+		n += int(sum % uint64(h.max-h.min+1))
+	}
+	b := make([]byte, n)
+	rand.New(rand.NewSource(int64(sum))).Read(b)
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	w.Write(b)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSizeRange(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max int
+		ok       bool
+	}{
+		{"1024", 1024, 1024, true},
+		{"100-200", 100, 200, true},
+		{"200-100", 0, 0, false},
+		{"1KB", 0, 0, false},
+	}
+	for _, tt := range tests {
+		min, max, err := parseSizeRange(tt.in)
+		if (err == nil) != tt.ok || min != tt.min || max != tt.max {
+			t.Errorf("parseSizeRange(%q) = %d, %d, %v", tt.in, min, max, err)
+		}
+	}
+}
+
+func TestOriginHandler(t *testing.T) {
+	ts := httptest.NewServer(originHandler{min: 10, max: 20})
+	defer ts.Close()
+	get := func(key string) string {
+		res, err := http.Get(ts.URL + "/" + key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		return string(b)
+	}
+	a := get("key-1")
+	if len(a) < 10 || len(a) > 20 {
+		t.Errorf("value has %d bytes; want 10 to 20", len(a))
+	}
+	if get("key-1") != a {
+		t.Errorf("values of the same key differ")
+	}
+}
+
+func TestOriginHandlerSizes(t *testing.T) {
+	ts := httptest.NewServer(originHandler{sizes: map[string]int{"abc": 7}})
+	defer ts.Close()
+	for key, want := range map[string]int{"abc": http.StatusOK, "def": http.StatusNotFound} {
+		res, err := http.Get(ts.URL + "/" + key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != want || (want == http.StatusOK && len(b) != 7) {
+			t.Errorf("%s: %s with %d bytes; want %d", key, res.Status, len(b), want)
+		}
+	}
+}
+
+func TestLoadgenOp(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		w.Write([]byte("value"))
+	}))
+	defer ts.Close()
+	l := &loadgen{client: ts.Client(), targets: []string{ts.URL}, group: "g", writes: 1}
+	if err := l.op("k"); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || !strings.HasPrefix(paths[0], "/cache/g/k?refresh=1") {
+		t.Errorf("requested %v; want a refresh of /cache/g/k", paths)
+	}
+	if l.nwrite != 1 || l.nbytes != 5 {
+		t.Errorf("counted %d writes, %d bytes; want 1, 5", l.nwrite, l.nbytes)
+	}
+}
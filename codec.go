@@ -0,0 +1,71 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// codec.go lets callers work with typed values while the cache stores
+// bytes.
+
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// A Codec converts between typed values and the bytes a group caches.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is a Codec using encoding/json. It is the default Codec
+// of groups.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// Codec returns the group's Codec.
+func (g *Group) Codec() Codec {
+	if g.opts.Codec != nil {
+		return g.opts.Codec
+	}
+	return JSONCodec{}
+}
+
+// GetAs gets key like Get and decodes the value into v, which must be
+// a pointer, with the group's Codec.
+func (g *Group) GetAs(ctx context.Context, key string, v interface{}) error {
+	var view ByteView
+	if err := g.Get(ctx, key, ByteViewSink(&view)); err != nil {
+		return err
+	}
+	return g.Codec().Unmarshal(view.ByteSlice(), v)
+}
+
+// SetAs encodes v with codec and sets it as the value of dest. A nil
+// codec means JSONCodec. Getters of groups read with GetAs use it to
+// populate their Sink.
+func SetAs(dest Sink, codec Codec, v interface{}) error {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	b, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return dest.SetBytes(b)
+}
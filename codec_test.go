@@ -0,0 +1,89 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+// hexCodec stores ints in hexadecimal, to check that a group's Codec
+// is used on both sides.
+type hexCodec struct{}
+
+func (hexCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(v.(int)), 16)), nil
+}
+
+func (hexCodec) Unmarshal(data []byte, v interface{}) error {
+	n, err := strconv.ParseInt(string(data), 16, 64)
+	*v.(*int) = int(n)
+	return err
+}
+
+func TestGetAs(t *testing.T) {
+	loads := 0
+	g := NewGroupOpts("typed", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return SetAs(dest, nil, user{ID: loads, Name: key})
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true})
+
+	for i := 0; i < 2; i++ {
+		var u user
+		if err := g.GetAs(dummyCtx, "gopher", &u); err != nil {
+			t.Fatal(err)
+		}
+		if want := (user{ID: 1, Name: "gopher"}); u != want {
+			t.Errorf("GetAs = %+v; want %+v", u, want)
+		}
+	}
+
+	var n int
+	if err := g.GetAs(dummyCtx, "gopher", &n); err == nil {
+		t.Error("decoding into the wrong type succeeded")
+	}
+}
+
+func TestGetAsCustomCodec(t *testing.T) {
+	codec := hexCodec{}
+	g := NewGroupOpts("typed-hex", 0, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		n, _ := strconv.Atoi(key)
+		return SetAs(dest, codec, n)
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, Codec: codec})
+
+	var n int
+	if err := g.GetAs(dummyCtx, "255", &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 255 {
+		t.Errorf("GetAs = %d; want 255", n)
+	}
+	var s string
+	if err := g.Get(dummyCtx, "255", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if s != fmt.Sprintf("%x", 255) {
+		t.Errorf("stored value = %q; want hex", s)
+	}
+}
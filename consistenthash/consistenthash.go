@@ -89,3 +89,45 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx]]
 }
+
+// GetN returns up to n distinct items found walking the ring clockwise
+// from the provided key. The first item is the one Get would return;
+// the rest are the key's successors, usable as replica candidates.
+func (m *Map) GetN(key string, n int) []string {
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	// 沿着哈希环顺时针走，跳过同一个节点的其他虚拟节点。
+	var items []string
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(m.keys) && len(items) < n; i++ {
+		item := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[item] {
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Ownership returns the share of the hash space owned by each item, as
+// fractions summing to one. Items own the hashes up to each of their
+// replicas from the previous replica on the ring.
+func (m *Map) Ownership() map[string]float64 {
+	owned := make(map[string]float64)
+	if m.IsEmpty() {
+		return owned
+	}
+	const space = 1 << 32
+	// 第一个节点还拥有最后一个节点之后、绕回0的那一段。
+	prev := int64(m.keys[len(m.keys)-1]) - space
+	for _, k := range m.keys {
+		owned[m.hashMap[k]] += float64(int64(k)-prev) / space
+		prev = int64(k)
+	}
+	return owned
+}
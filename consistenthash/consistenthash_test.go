@@ -18,6 +18,7 @@ package consistenthash
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"testing"
 )
@@ -86,6 +87,70 @@ func TestConsistency(t *testing.T) {
 
 }
 
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+
+	// Replicas hash to 2, 4, 6, 12, 14, 16, 22, 24, 26.
+	hash.Add("6", "4", "2")
+
+	testCases := []struct {
+		key  string
+		n    int
+		want []string
+	}{
+		{"3", 1, []string{"4"}},
+		{"3", 2, []string{"4", "6"}},
+		{"3", 3, []string{"4", "6", "2"}},
+		{"3", 10, []string{"4", "6", "2"}},
+		{"27", 2, []string{"2", "4"}},
+		{"27", 0, nil},
+	}
+	for _, tc := range testCases {
+		got := hash.GetN(tc.key, tc.n)
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("GetN(%q, %d) = %v; want %v", tc.key, tc.n, got, tc.want)
+		}
+		if len(got) > 0 && got[0] != hash.Get(tc.key) {
+			t.Errorf("GetN(%q, %d)[0] = %q; want Get's answer %q", tc.key, tc.n, got[0], hash.Get(tc.key))
+		}
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+	if got := hash.Ownership(); len(got) != 0 {
+		t.Errorf("Ownership of an empty ring = %v; want none", got)
+	}
+
+	// Replicas hash to 2, 4, 6, 12, 14, 16, 22, 24, 26.
+	hash.Add("6", "4", "2")
+	got := hash.Ownership()
+	var sum float64
+	for _, share := range got {
+		sum += share
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("shares sum to %v; want 1", sum)
+	}
+	for _, item := range []string{"4", "6"} {
+		if hashes := got[item] * (1 << 32); math.Abs(hashes-6) > 1e-3 {
+			t.Errorf("%q owns %v hashes; want 6", item, hashes)
+		}
+	}
+}
+
 func BenchmarkGet8(b *testing.B)   { benchmarkGet(b, 8) }
 func BenchmarkGet32(b *testing.B)  { benchmarkGet(b, 32) }
 func BenchmarkGet128(b *testing.B) { benchmarkGet(b, 128) }
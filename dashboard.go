@@ -0,0 +1,177 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// dashboard.go serves a small HTML dashboard of the cluster's state
+// from the admin handler.
+
+package groupcache
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// RingOwnership returns the share of the key space owned by each peer
+// of the pool, as fractions summing to one.
+func (p *HTTPPool) RingOwnership() map[string]float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.peers.Ownership()
+}
+
+// hotKeys lists up to n hot-cache keys of each registered group.
+func hotKeys(n int) map[string][]ScanItem {
+	keys := make(map[string][]ScanItem)
+	for _, g := range registeredGroups() {
+		keys[g.name] = g.HotKeys(n)
+	}
+	return keys
+}
+
+func (p *HTTPPool) serveRing(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, p.RingOwnership())
+}
+
+func serveHotKeys(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.FormValue("n"))
+	if err != nil || n <= 0 {
+		n = 20
+	}
+	writeJSON(w, hotKeys(n))
+}
+
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
+
+// dashboardHTML polls the admin endpoints next to it, so it works
+// wherever the admin handler is mounted. Hit rates are kept in the
+// browser, for as long as the page is open.
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>groupcache</title>
+<style>
+body { font: 14px sans-serif; margin: 2em; color: #222; }
+h2 { font-size: 16px; margin-top: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 10px; text-align: right; border-bottom: 1px solid #ddd; }
+th:first-child, td:first-child { text-align: left; }
+.bar { display: inline-block; height: 10px; background: #4a7; }
+.err { color: #b33; }
+svg { vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>groupcache</h1>
+<div id="errors" class="err"></div>
+<h2>Groups</h2>
+<table id="groups"></table>
+<h2>Ring ownership</h2>
+<table id="ring"></table>
+<h2>Hot keys</h2>
+<div id="hot"></div>
+<script>
+"use strict";
+var rates = {};   // group -> hit rates of the last polls
+var last = {};    // group -> stats of the previous poll
+var maxPoints = 60;
+
+function esc(s) {
+  return String(s).replace(/[&<>"]/g, function(c) {
+    return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c];
+  });
+}
+
+function pct(x) { return (100 * x).toFixed(1) + "%"; }
+
+function sparkline(points) {
+  var w = 120, h = 20, d = "";
+  points.forEach(function(y, i) {
+    d += (i ? "L" : "M") + (i * w / (maxPoints - 1)).toFixed(1) + "," + (h - y * h).toFixed(1);
+  });
+  return '<svg width="' + w + '" height="' + h + '"><path d="' + d + '" fill="none" stroke="#4a7"/></svg>';
+}
+
+function get(path) {
+  return fetch(path).then(function(r) {
+    if (!r.ok) throw new Error(path + ": " + r.status);
+    return r.json();
+  });
+}
+
+function showGroups(cs) {
+  var rows = "<tr><th>group</th><th>gets</th><th>hit rate</th><th>recent</th><th>peer loads</th><th>loads</th><th>main bytes</th><th>hot bytes</th></tr>";
+  Object.keys(cs.Groups).sort().forEach(function(name) {
+    var g = cs.Groups[name], prev = last[name];
+    var h = rates[name] = rates[name] || [];
+    if (prev && g.Gets > prev.Gets) {
+      h.push((g.CacheHits - prev.CacheHits) / (g.Gets - prev.Gets));
+      if (h.length > maxPoints) h.shift();
+    }
+    last[name] = g;
+    rows += "<tr><td>" + esc(name) + "</td><td>" + g.Gets + "</td><td>" +
+      pct(g.Gets ? g.CacheHits / g.Gets : 0) + "</td><td>" + sparkline(h) + "</td><td>" +
+      g.PeerLoads + "</td><td>" + g.LocalLoads + "</td><td>" + g.MainCache.Bytes +
+      "</td><td>" + g.HotCache.Bytes + "</td></tr>";
+  });
+  document.getElementById("groups").innerHTML = rows;
+  var errs = cs.Errors || {};
+  document.getElementById("errors").innerHTML = Object.keys(errs).sort().map(function(peer) {
+    return esc(peer) + ": " + esc(errs[peer]);
+  }).join("<br>");
+}
+
+function showRing(ring) {
+  var rows = "<tr><th>peer</th><th>share</th><th></th></tr>";
+  Object.keys(ring).sort().forEach(function(peer) {
+    rows += "<tr><td>" + esc(peer) + "</td><td>" + pct(ring[peer]) +
+      '</td><td><span class="bar" style="width:' + (300 * ring[peer]).toFixed(0) + 'px"></span></td></tr>';
+  });
+  document.getElementById("ring").innerHTML = rows;
+}
+
+function showHot(hot) {
+  var html = "";
+  Object.keys(hot).sort().forEach(function(name) {
+    if (!hot[name].length) return;
+    html += "<h3>" + esc(name) + "</h3><table><tr><th>key</th><th>bytes</th></tr>";
+    hot[name].forEach(function(item) {
+      html += "<tr><td>" + esc(item.Key) + "</td><td>" + item.Bytes + "</td></tr>";
+    });
+    html += "</table>";
+  });
+  document.getElementById("hot").innerHTML = html || "none";
+}
+
+function poll() {
+  Promise.all([get("stats/cluster"), get("ring"), get("hotkeys")]).then(function(r) {
+    showGroups(r[0]);
+    showRing(r[1]);
+    showHot(r[2]);
+  }).catch(function(err) {
+    document.getElementById("errors").textContent = String(err);
+  });
+}
+
+poll();
+setInterval(poll, 5000);
+</script>
+</body>
+</html>
+`
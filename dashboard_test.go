@@ -0,0 +1,66 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	const name = "TestDashboard-group"
+	g := newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), NoPeers{})
+	g.hotCache.add("a", ByteView{s: "1"})
+	g.hotCache.add("b", ByteView{s: "22"})
+
+	p := newHTTPPool("http://self", nil)
+	p.Set("http://self", "http://other")
+	admin := p.AdminHandler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET %s = %d", path, rec.Code)
+		}
+		return rec
+	}
+
+	var ring map[string]float64
+	if err := json.Unmarshal(get("/ring").Body.Bytes(), &ring); err != nil {
+		t.Fatal(err)
+	}
+	if len(ring) != 2 || ring["http://self"]+ring["http://other"] < 0.999 {
+		t.Errorf("/ring = %v; want both peers sharing the key space", ring)
+	}
+
+	var hot map[string][]ScanItem
+	if err := json.Unmarshal(get("/hotkeys?n=1").Body.Bytes(), &hot); err != nil {
+		t.Fatal(err)
+	}
+	if got := hot[name]; len(got) != 1 || got[0] != (ScanItem{Key: "b", Bytes: 2}) {
+		t.Errorf("/hotkeys?n=1 lists %+v; want the most recent key b", got)
+	}
+
+	if body := get("/dashboard").Body.String(); !strings.Contains(body, "stats/cluster") {
+		t.Errorf("/dashboard does not poll the cluster stats")
+	}
+}
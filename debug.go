@@ -0,0 +1,187 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// debug.go serves dumps of cache internals for debugging production
+// processes, in the manner of net/http/pprof.
+
+package groupcache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// CacheDebug describes the internal state of one of a group's caches.
+type CacheDebug struct {
+	Items, Bytes int64
+
+	// Probation and Protected count the entries in each segment of
+	// a cache with a segmented policy.
+	Probation, Protected int `json:",omitempty"`
+}
+
+// debug returns the state of c.
+func (c *cache) debug() CacheDebug {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d := CacheDebug{Items: c.itemsLocked(), Bytes: c.nbytes}
+	if s, ok := c.lru.(interface{ Segments() (int, int) }); ok {
+		d.Probation, d.Protected = s.Segments()
+	}
+	return d
+}
+
+// A HotEntry describes an entry of a group's hot cache. Keys are
+// hashed, since they may be sensitive.
+type HotEntry struct {
+	KeyHash string
+	Bytes   int64
+	Age     string // time since the entry was added
+	Expired bool   `json:",omitempty"`
+}
+
+// hotEntries lists up to n entries of the group's hot cache, most
+// recently used first.
+func (g *Group) hotEntries(n int) []HotEntry {
+	now := time.Now()
+	entries := []HotEntry{}
+	c := &g.hotCache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.lru == nil || n <= 0 {
+		return entries
+	}
+	c.lru.Range(func(k lru.Key, v interface{}) bool {
+		e := v.(*cacheEntry)
+		entries = append(entries, HotEntry{
+			KeyHash: keyHash(k.(string)),
+			Bytes:   int64(e.len()),
+			Age:     now.Sub(e.added).Round(time.Millisecond).String(),
+			Expired: e.value.expired(now),
+		})
+		return len(entries) < n
+	})
+	return entries
+}
+
+// keyHash returns a short hash identifying key in debug output.
+func keyHash(key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// A RingAssignment describes where a group's key is cached.
+type RingAssignment struct {
+	Group    string
+	Key      string
+	CacheKey string   // the key hashed when longer than MaxKeyBytes
+	KeyHash  string   // keyHash of CacheKey
+	Owner    string   // the peer owning the key, or empty if this process
+	Ring     []string // the owner and its successors on the ring
+}
+
+// ringAssignment describes where key of group is cached.
+func (p *HTTPPool) ringAssignment(group, key string, n int) RingAssignment {
+	ck := key
+	if g := GetGroup(group); g != nil {
+		ck = g.cacheKey(key)
+	}
+	a := RingAssignment{Group: group, Key: key, CacheKey: ck, KeyHash: keyHash(ck)}
+	p.mu.Lock()
+	a.Ring = p.peers.GetN(ck, n)
+	p.mu.Unlock()
+	if peer, ok := p.PickPeer(ck); ok {
+		a.Owner = peer.(*httpGetter).baseURL
+	}
+	return a
+}
+
+// DebugHandler returns a handler dumping cache internals for deep
+// debugging of production processes, serving:
+//
+//	/             an index of the endpoints
+//	/caches       each group's main and hot cache sizes and segments
+//	/hotcache     a group's hot-cache entries; ?group= names it, ?n= sets
+//	              how many
+//	/ring         where a key is cached; ?group= and ?key= name it
+//	/inflight     the singleflight loads in progress, oldest first
+//
+// Mount it under a prefix, such as /debug/groupcache/, with
+// http.StripPrefix. The handler is wrapped with the AdminMiddleware
+// option; it should not be reachable by clients.
+func (p *HTTPPool) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, debugIndex)
+	})
+	mux.HandleFunc("/caches", func(w http.ResponseWriter, r *http.Request) {
+		caches := make(map[string]map[string]CacheDebug)
+		for _, g := range registeredGroups() {
+			caches[g.name] = map[string]CacheDebug{
+				"main": g.mainCache.debug(),
+				"hot":  g.hotCache.debug(),
+			}
+		}
+		writeJSON(w, caches)
+	})
+	mux.HandleFunc("/hotcache", func(w http.ResponseWriter, r *http.Request) {
+		g := GetGroup(r.FormValue("group"))
+		if g == nil {
+			http.Error(w, "no such group: "+r.FormValue("group"), http.StatusNotFound)
+			return
+		}
+		n, err := strconv.Atoi(r.FormValue("n"))
+		if err != nil || n <= 0 {
+			n = 100
+		}
+		writeJSON(w, g.hotEntries(n))
+	})
+	mux.HandleFunc("/ring", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("key") == "" {
+			http.Error(w, "want ?group=&key=", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, p.ringAssignment(r.FormValue("group"), r.FormValue("key"), 3))
+	})
+	mux.HandleFunc("/inflight", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, inFlightLoads())
+	})
+	return chainHandler(mux, p.opts.AdminMiddleware)
+}
+
+const debugIndex = `<!DOCTYPE html>
+<html><head><title>groupcache debug</title></head>
+<body>
+<h1>groupcache debug</h1>
+<ul>
+<li><a href="caches">caches</a>: cache sizes and segments of each group
+<li>hotcache?group=&amp;n=: hot-cache entries of a group
+<li>ring?group=&amp;key=: the peers a key is assigned to
+<li><a href="inflight">inflight</a>: loads in progress
+</ul>
+</body></html>
+`
@@ -0,0 +1,71 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	const name = "TestDebugHandler-group"
+	g := NewGroupOpts(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: NoPeers{}, MainCachePolicy: SegmentedLRUPolicy(0)})
+	var s string
+	for _, key := range []string{"a", "b", "b"} {
+		g.Get(dummyCtx, key, StringSink(&s))
+	}
+	g.hotCache.add("hot", ByteView{s: "xyz"})
+
+	p := newHTTPPool("http://self", nil)
+	p.Set("http://self", "http://other")
+	debug := p.DebugHandler()
+	get := func(path string, v interface{}) {
+		rec := httptest.NewRecorder()
+		debug.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET %s = %d: %s", path, rec.Code, rec.Body)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %s: %v", path, err)
+		}
+	}
+
+	var caches map[string]map[string]CacheDebug
+	get("/caches", &caches)
+	if got := caches[name]["main"]; got.Items != 2 || got.Probation != 1 || got.Protected != 1 {
+		t.Errorf("main cache = %+v; want 2 items, one in each segment", got)
+	}
+
+	var hot []HotEntry
+	get("/hotcache?group="+name, &hot)
+	if len(hot) != 1 || hot[0].KeyHash != keyHash("hot") || hot[0].Bytes != 3 {
+		t.Errorf("hot cache = %+v; want the hashed key hot of 3 bytes", hot)
+	}
+
+	var a RingAssignment
+	get("/ring?group="+name+"&key=k", &a)
+	if len(a.Ring) != 2 || (a.Owner != "" && a.Owner != "http://other/_groupcache/") {
+		t.Errorf("ring assignment = %+v", a)
+	}
+	if (a.Owner == "") != (a.Ring[0] == "http://self") {
+		t.Errorf("owner %q disagrees with the ring %v", a.Owner, a.Ring)
+	}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// drain.go removes a process from its pool without a miss storm: the
+// values it owns are first sent to the peers that own them once it is
+// gone.
+
+package groupcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/consistenthash"
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/groupcache/lru"
+	"github.com/golang/protobuf/proto"
+)
+
+// prewarmPath is the path, below the pool's BasePath, where a draining
+// peer sends values to their next owners. Requests name the group in
+// their query and carry a stream of length-prefixed GetMultiResponses.
+const prewarmPath = "_prewarm"
+
+// errDraining is returned by Drain while another drain runs.
+var errDraining = errors.New("groupcache: pool is already draining")
+
+// DrainOptions configure HTTPPool.Drain.
+type DrainOptions struct {
+	// MaxBytes bounds the size of the values sent per group, most
+	// recently used first. Zero sends the whole main cache.
+	MaxBytes int64
+
+	// BatchBytes bounds the size of each request to a peer, except
+	// for requests of a single value. If zero, it is 1MB; it is at
+	// most 8MB, the largest request peers accept.
+	BatchBytes int
+}
+
+// DrainStats count the values of a group sent by Drain.
+type DrainStats struct {
+	Entries, Bytes int64            // Bytes of the values
+	Peers          map[string]int64 // entries sent to each peer
+}
+
+// A DrainReport describes the work of Drain.
+type DrainReport struct {
+	Groups map[string]DrainStats
+	Errors []string `json:",omitempty"` // batches and peers that failed
+}
+
+// Draining reports whether the pool is running Drain.
+func (p *HTTPPool) Draining() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.draining
+}
+
+// Drain prepares this process for removal from the pool. It marks the
+// pool as draining, sends the values of the registered groups' main
+// caches to the peers owning them in the ring without this process,
+// and then tells the peers to drop it, as Leave does. Stop the process
+// once Drain returns.
+//
+// Values that could not be sent are listed in the report's Errors;
+// they are only loaded again by their new owners. The returned error
+// is that of Leave.
+func (p *HTTPPool) Drain(ctx context.Context, o *DrainOptions) (DrainReport, error) {
+	var opts DrainOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = 1 << 20
+	}
+	opts.BatchBytes = min(opts.BatchBytes, maxRequestBytes)
+	p.mu.Lock()
+	if p.draining {
+		p.mu.Unlock()
+		return DrainReport{}, errDraining
+	}
+	p.draining = true
+	// 去掉自己之后的环决定每个key的新owner。
+	ring := consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	ring.Add(removeSelves(p.peerList, p.selves)...)
+	getters := make(map[string]*httpGetter, len(p.httpGetters))
+	for peer, g := range p.httpGetters {
+		getters[peer] = g
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.draining = false
+		p.mu.Unlock()
+	}()
+
+	report := DrainReport{Groups: make(map[string]DrainStats)}
+	if !ring.IsEmpty() {
+		for _, g := range registeredGroups() {
+			report.Groups[g.name] = p.drainGroup(ctx, g, ring, getters, opts, &report.Errors)
+		}
+	}
+	sort.Strings(report.Errors)
+	return report, p.Leave(ctx)
+}
+
+// drainGroup sends g's main cache values to their owners in ring.
+func (p *HTTPPool) drainGroup(ctx context.Context, g *Group, ring *consistenthash.Map, getters map[string]*httpGetter, o DrainOptions, errs *[]string) DrainStats {
+	stats := DrainStats{Peers: make(map[string]int64)}
+	batches := make(map[string][]*pb.GetMultiResponse)
+	for _, e := range g.mainCache.entries(o.MaxBytes) {
+		peer := ring.Get(e.GetKey())
+		if getters[peer] == nil || proto.Size(e) > maxRequestBytes {
+			// 太大的值peer不会接受，由新owner重新加载。
+			continue
+		}
+		batches[peer] = append(batches[peer], e)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for peer, entries := range batches {
+		wg.Add(1)
+		go func(peer string, entries []*pb.GetMultiResponse) {
+			defer wg.Done()
+			for len(entries) > 0 {
+				n, size, values := 0, 0, 0
+				for n < len(entries) {
+					s := binary.MaxVarintLen64 + proto.Size(entries[n])
+					if n > 0 && size+s > o.BatchBytes {
+						break
+					}
+					size += s
+					values += len(entries[n].Value)
+					n++
+				}
+				err := getters[peer].prewarm(ctx, g.name, entries[:n])
+				mu.Lock()
+				if err != nil {
+					*errs = append(*errs, fmt.Sprintf("%s: %d entries to %s: %v", g.name, n, peer, err))
+				} else {
+					stats.Entries += int64(n)
+					stats.Bytes += int64(values)
+					stats.Peers[peer] += int64(n)
+				}
+				mu.Unlock()
+				entries = entries[n:]
+			}
+		}(peer, entries)
+	}
+	wg.Wait()
+	return stats
+}
+
+// entries returns the cache's unexpired values, most recently used
+// first, up to maxBytes of them if it is positive.
+func (c *cache) entries(maxBytes int64) []*pb.GetMultiResponse {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var out []*pb.GetMultiResponse
+	if c.lru == nil {
+		return out
+	}
+	now := time.Now()
+	var total int64
+	c.lru.Range(func(k lru.Key, v interface{}) bool {
+		e := v.(*cacheEntry)
+		if e.value.expired(now) {
+			return true
+		}
+		b := e.value.ByteSlice()
+		if c.store != nil {
+			var ok bool
+			if b, ok = c.store.Get(k.(string)); !ok {
+				return true
+			}
+		}
+		if maxBytes > 0 && total+int64(len(b)) > maxBytes {
+			return false
+		}
+		total += int64(len(b))
+		res := &pb.GetMultiResponse{Key: proto.String(k.(string)), Value: b}
+		if !e.value.e.IsZero() {
+			res.Expire = proto.Int64(e.value.e.UnixNano())
+		}
+		out = append(out, res)
+		return true
+	})
+	return out
+}
+
+// prewarm sends entries of group to the peer.
+func (h *httpGetter) prewarm(ctx context.Context, group string, entries []*pb.GetMultiResponse) error {
+	var body bytes.Buffer
+	var n [binary.MaxVarintLen64]byte
+	for _, e := range entries {
+		b, err := proto.Marshal(e)
+		if err != nil {
+			return err
+		}
+		body.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+		body.Write(b)
+	}
+	u := h.baseURL + prewarmPath + "?" + url.Values{"group": {group}}.Encode()
+	req, err := http.NewRequest("POST", u, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	res, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+// servePrewarm adds the values sent by a draining peer to the group's
+// main cache. Keys are cache keys, already shortened by MaxKeyBytes.
+func (p *HTTPPool) servePrewarm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	g := GetGroup(r.URL.Query().Get("group"))
+	if g == nil {
+		http.Error(w, "no such group: "+r.URL.Query().Get("group"), http.StatusNotFound)
+		return
+	}
+	// Drain's batches are a single frame or at most maxRequestBytes.
+	br := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxRequestBytes+binary.MaxVarintLen64))
+	for {
+		b, err := readFrame(br)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var e pb.GetMultiResponse
+		if err := proto.Unmarshal(b, &e); err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := e.GetKey()
+		if _, ok := g.mainCache.get(key); ok {
+			continue
+		}
+		value := ByteView{b: e.Value}
+		if e.Expire != nil {
+			value.e = time.Unix(0, e.GetExpire())
+		}
+		g.mainCache.remove(key) // an expired copy
+		g.populateCache(key, value, &g.mainCache)
+	}
+}
+
+// serveDrain runs Drain for an operator's POST, with the max_bytes
+// form value as its MaxBytes option, and answers with its report.
+func (p *HTTPPool) serveDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, map[string]bool{"Draining": p.Draining()})
+		return
+	}
+	var o DrainOptions
+	if s := r.FormValue("max_bytes"); s != "" {
+		if _, err := fmt.Sscan(s, &o.MaxBytes); err != nil {
+			http.Error(w, "bad max_bytes: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	report, err := p.Drain(r.Context(), &o)
+	if err == errDraining {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, strings.TrimPrefix(err.Error(), "groupcache: "))
+	}
+	writeJSON(w, report)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestPrewarm(t *testing.T) {
+	const name = "TestPrewarm-group"
+	g := newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("loaded")
+	}), NoPeers{})
+	_, ts := startPool(t, nil)
+	h := &httpGetter{baseURL: ts.URL + defaultBasePath}
+	expire := time.Now().Add(time.Hour)
+	err := h.prewarm(dummyCtx, name, []*pb.GetMultiResponse{
+		{Key: proto.String("a"), Value: []byte("sent a")},
+		{Key: proto.String("b"), Value: []byte("sent b"), Expire: proto.Int64(expire.UnixNano())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v ByteView
+	if err := g.Get(dummyCtx, "b", ByteViewSink(&v)); err != nil || v.String() != "sent b" || !v.Expire().Equal(time.Unix(0, expire.UnixNano())) {
+		t.Errorf("Get(b) = %q expiring %v, %v; want the prewarmed value", v, v.Expire(), err)
+	}
+	if g.Stats.LocalLoads.Get() != 0 {
+		t.Errorf("prewarmed key was loaded")
+	}
+	if err := h.prewarm(dummyCtx, "no-such-group", nil); err == nil {
+		t.Error("prewarm of an unknown group succeeded")
+	}
+}
+
+func TestPrewarmBadFrames(t *testing.T) {
+	const name = "TestPrewarmBadFrames-group"
+	newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("loaded")
+	}), NoPeers{})
+	_, ts := startPool(t, nil)
+	var n [binary.MaxVarintLen64]byte
+	for _, body := range []string{
+		string(n[:binary.PutUvarint(n[:], 10)]) + "abc",
+		string(n[:binary.PutUvarint(n[:], 1<<40)]),
+	} {
+		res, err := http.Post(ts.URL+defaultBasePath+prewarmPath+"?group="+name, "application/x-protobuf", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("prewarm of frame %q: status %d; want %d", body, res.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	const name = "TestDrain-group"
+	g := newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value of " + key)
+	}), NoPeers{})
+	for i := 0; i < 20; i++ {
+		var s string
+		g.Get(dummyCtx, fmt.Sprint("key", i), StringSink(&s))
+	}
+
+	// Both pools serve the process-wide group; b records the requests
+	// a sends it.
+	var paths []string
+	var b *HTTPPool
+	tsb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, strings.TrimPrefix(r.URL.Path, defaultBasePath))
+		b.ServeHTTP(w, r)
+	}))
+	defer tsb.Close()
+	b = newHTTPPool(tsb.URL, nil)
+	a, tsa := startPool(t, nil)
+	a.Set(tsa.URL, tsb.URL)
+	b.Set(tsa.URL, tsb.URL)
+
+	rec := httptest.NewRecorder()
+	a.AdminHandler().ServeHTTP(rec, httptest.NewRequest("POST", "/drain?max_bytes=100", nil))
+	var report DrainReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding report %q: %v", rec.Body, err)
+	}
+	s := report.Groups[name]
+	// Every key moves to b, the only peer left. The newest values,
+	// of key19 down to key13, are 14 bytes, so 100 bytes hold 7.
+	if s.Entries != 7 || s.Bytes != 98 || s.Peers[tsb.URL] != 7 || len(report.Errors) != 0 {
+		t.Errorf("report for %s = %+v, errors %v; want 7 entries of 98 bytes sent to b", name, s, report.Errors)
+	}
+	// Other tests' groups are drained too.
+	if len(paths) < 2 || paths[0] != prewarmPath || paths[len(paths)-1] != leavePath {
+		t.Errorf("b got requests %v; want prewarms then a leave", paths)
+	}
+	if fmt.Sprint(b.peerList) != fmt.Sprint([]string{tsb.URL}) {
+		t.Errorf("b's peers = %v after the drain; want only itself", b.peerList)
+	}
+	if a.Draining() {
+		t.Error("a is still draining")
+	}
+}
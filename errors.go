@@ -0,0 +1,47 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import "errors"
+
+// Errors returned by groups and their peers. They may be wrapped with
+// more detail; test for them with errors.Is.
+var (
+	// ErrNotFound is returned by Getters for keys that have no value.
+	// The key's owner reports it to the peers that asked for the key,
+	// which then don't load the key themselves.
+	ErrNotFound = errors.New("groupcache: not found")
+
+	// ErrPeerUnavailable is returned by peers that could not be
+	// reached or failed to answer.
+	ErrPeerUnavailable = errors.New("groupcache: peer unavailable")
+
+	// ErrValueTooLarge is returned by Getters for values larger than
+	// they are willing to load.
+	ErrValueTooLarge = errors.New("groupcache: value too large")
+
+	// ErrOverloaded is returned for loads beyond a group's
+	// MaxConcurrentLoads when its RejectOverload option is set.
+	ErrOverloaded = errors.New("groupcache: too many concurrent loads")
+
+	// ErrGroupClosed is returned by the operations of a closed group.
+	ErrGroupClosed = errors.New("groupcache: group closed")
+
+	// ErrNotCached is returned by a PeekOnly Get of a key that is not
+	// cached.
+	ErrNotCached = errors.New("groupcache: not cached")
+)
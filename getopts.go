@@ -0,0 +1,55 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+// A GetOption changes how a single Get finds its value.
+type GetOption func(*getOptions)
+
+type getOptions struct {
+	skipHotCache bool
+	forceRefresh bool
+	peekOnly     bool
+}
+
+func newGetOptions(opts []GetOption) getOptions {
+	var o getOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SkipHotCache makes Get neither read nor fill the hot cache, which
+// holds copies of values owned by peers. Values come from their owner.
+func SkipHotCache() GetOption {
+	return func(o *getOptions) { o.skipHotCache = true }
+}
+
+// ForceRefresh makes Get ignore cached values and load the key again,
+// replacing the cached copies. When the key is owned by a peer, the
+// peer is asked to reload it as well. A Get joins a load of the key
+// already in progress rather than starting another.
+func ForceRefresh() GetOption {
+	return func(o *getOptions) { o.forceRefresh = true }
+}
+
+// PeekOnly makes Get answer from this process's caches alone; it
+// never loads the key, locally or from a peer, and returns
+// ErrNotCached when the key is not cached. It overrides ForceRefresh.
+func PeekOnly() GetOption {
+	return func(o *getOptions) { o.peekOnly = true }
+}
@@ -26,11 +26,16 @@ package groupcache
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"math/rand"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	pb "github.com/golang/groupcache/groupcachepb"
 	"github.com/golang/groupcache/lru"
@@ -76,6 +81,19 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+// registeredGroups returns the groups registered with NewGroup,
+// sorted by name.
+func registeredGroups() []*Group {
+	mu.RLock()
+	list := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, g)
+	}
+	mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
 // NewGroup creates a coordinated group-aware Getter from a Getter.
 //
 // The returned Getter tries (but does not guarantee) to run only one
@@ -86,30 +104,194 @@ func GetGroup(name string) *Group {
 //
 // The group name must be unique for each getter.
 func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
-	return newGroup(name, cacheBytes, getter, nil)
+	return NewGroupOpts(name, cacheBytes, getter, nil)
+}
+
+// GroupOptions are the configurations of a Group.
+type GroupOptions struct {
+	// Peers specifies the PeerPicker used to find the owner of a key.
+	// If nil, the PeerPicker registered with RegisterPeerPicker or
+	// RegisterPerGroupPeerPicker is used.
+	Peers PeerPicker
+
+	// Unregistered keeps the group out of the process-wide registry
+	// consulted by GetGroup and by peer servers such as HTTPPool.
+	// Unregistered groups may share a name, which lets a single
+	// process host several simulated nodes.
+	Unregistered bool
+
+	// MaxConcurrentLoads bounds the number of concurrent calls to the
+	// group's Getter, protecting origins with hard connection limits.
+	// Zero means no limit.
+	MaxConcurrentLoads int
+
+	// RejectOverload makes loads beyond MaxConcurrentLoads fail
+	// immediately with ErrOverloaded. Otherwise they wait for a free
+	// slot until their context is done.
+	RejectOverload bool
+
+	// Middleware wraps the group's Getter; see Chain.
+	Middleware []GetterMiddleware
+
+	// Codec encodes the values read with GetAs.
+	// If nil, JSONCodec is used.
+	Codec Codec
+
+	// UseArena packs the values of the group's caches into large
+	// shared allocations, which reduces garbage collection work for
+	// caches of many small values. Evicted values only release their
+	// memory once every value sharing their allocation is evicted too,
+	// so a group may hold somewhat more memory than its cacheBytes.
+	UseArena bool
+
+	// MainStore, if non-nil, holds the values of the group's main
+	// cache in place of the Go heap; only the index of keys stays in
+	// memory. See the mmapstore package. cacheBytes still bounds the
+	// size of the cached keys and values, so it may be set well above
+	// the process's memory when MainStore is used.
+	MainStore ValueStore
+
+	// MainCachePolicy and HotCachePolicy choose the eviction policy
+	// of the group's caches. If nil, LRUPolicy is used.
+	MainCachePolicy CachePolicy
+	HotCachePolicy  CachePolicy
+
+	// TTL is how long values loaded by this process stay cached.
+	// Values fetched from a peer expire when the peer's copy does.
+	// Zero means values never expire.
+	TTL time.Duration
+
+	// TTLJitter adds a random duration in [0, TTLJitter) to each
+	// value's TTL, so that values loaded together don't all expire,
+	// and get reloaded from the origin, at the same time.
+	TTLJitter time.Duration
+
+	// SoftCacheBytes, if positive and below the group's cacheBytes,
+	// is the size the caches are trimmed back to in the background
+	// once they grow past it. cacheBytes stays a hard limit enforced
+	// by the Get that crosses it, so Gets only pay for evictions when
+	// the background trimming falls behind.
+	SoftCacheBytes int64
+
+	// MaxKeyBytes, if positive, bounds the length of the keys the
+	// group caches and routes to peers. Longer keys are replaced by a
+	// prefix of them followed by a hash of the whole key. The Getter
+	// still sees the full key, and peer requests carry it in their
+	// body rather than in the URL. All peers must agree on the value.
+	// It is at least 33, the length of the hash and its separator;
+	// smaller positive values are raised to that.
+	MaxKeyBytes int
+
+	// NegativeFilterBits, if positive, is the size of a Bloom filter
+	// of the keys the group's Getter, or a peer's, found missing.
+	// Gets of those keys then fail with ErrNotFound without a load,
+	// except with ForceRefresh. An HTTPPool's SyncNegativeFilters
+	// shares the filters between peers, which must then agree on the
+	// size. About 10 bits per missing key keep false positives, which
+	// hide existing keys, near 1%.
+	NegativeFilterBits int
+
+	// NegativeFilterTTL is how long missing keys are remembered: for
+	// between one and two NegativeFilterTTLs, so keys created at the
+	// origin may stay missing that long. If zero, it is one minute.
+	NegativeFilterTTL time.Duration
+
+	// AdmissionCounters, if positive, makes the group count the
+	// requests of keys in a count-min sketch of that many counters
+	// per row. Once the caches are full, a loaded value is then only
+	// cached if its key was requested more often than that of the
+	// entry it would evict first, which keeps the caches' bytes for
+	// popular keys through scans of one-off keys. The number of
+	// entries the caches hold is a good size.
+	AdmissionCounters int
+
+	// Recorder, if not nil, is told of every Get served by the
+	// group, except PeekOnly misses.
+	Recorder GetRecorder
+}
+
+// A ValueStore holds the values of a group's main cache. The cache
+// keeps the index of keys and decides evictions; a store may also drop
+// values on its own, which the cache then treats as misses.
+// Implementations must be safe for concurrent use.
+type ValueStore interface {
+	// Put stores a copy of value under key.
+	Put(key string, value []byte) error
+
+	// Get returns the value stored under key. The caller may keep
+	// the returned slice; it must not be modified by the store.
+	Get(key string) (value []byte, ok bool)
+
+	// Remove drops the value stored under key, if any.
+	Remove(key string)
+}
+
+// NewGroupOpts creates a Group like NewGroup, with the given options.
+func NewGroupOpts(name string, cacheBytes int64, getter Getter, o *GroupOptions) *Group {
+	var opts GroupOptions
+	if o != nil {
+		opts = *o
+	}
+	return newGroupOpts(name, cacheBytes, getter, opts)
 }
 
 // If peers is nil, the peerPicker is called via a sync.Once to initialize it.
 func newGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker) *Group {
+	return newGroupOpts(name, cacheBytes, getter, GroupOptions{Peers: peers})
+}
+
+func newGroupOpts(name string, cacheBytes int64, getter Getter, opts GroupOptions) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	if opts.MaxKeyBytes > 0 {
+		opts.MaxKeyBytes = max(opts.MaxKeyBytes, hashedKeyBytes)
+	}
 	mu.Lock()
 	defer mu.Unlock()
 	initPeerServerOnce.Do(callInitPeerServer)
-	if _, dup := groups[name]; dup {
+	if _, dup := groups[name]; dup && !opts.Unregistered {
 		panic("duplicate registration of group " + name)
 	}
 	g := &Group{
 		name:       name,
-		getter:     getter,
-		peers:      peers,
-		cacheBytes: cacheBytes,
+		getter:     Chain(getter, opts.Middleware...),
+		peers:      opts.Peers,
 		loadGroup:  &singleflight.Group{},
+		opts:       opts,
+		closed:     make(chan struct{}),
+		rates:      new(rateRing),
+	}
+	s := &settings{cacheBytes: cacheBytes, ttl: opts.TTL, ttlJitter: opts.TTLJitter}
+	if opts.MaxConcurrentLoads > 0 {
+		s.loadSem = make(chan struct{}, opts.MaxConcurrentLoads)
+	}
+	g.settings.Store(s)
+	if opts.SoftCacheBytes > 0 {
+		// The soft limit only matters while it is below cacheBytes,
+		// which SetCacheBytes may change.
+		g.evicting = make(chan struct{}, 1)
+	}
+	if opts.UseArena {
+		g.mainCache.arena = new(arena)
+		g.hotCache.arena = new(arena)
+	}
+	g.mainCache.store = opts.MainStore
+	g.mainCache.policy = opts.MainCachePolicy
+	g.hotCache.policy = opts.HotCachePolicy
+	g.hotCache.keepExpired = true
+	if opts.AdmissionCounters > 0 {
+		g.admission = lru.NewCountMinSketch(opts.AdmissionCounters)
+	}
+	if opts.NegativeFilterBits > 0 {
+		g.negative = newNegativeFilter(opts.NegativeFilterBits, opts.NegativeFilterTTL)
 	}
 	if fn := newGroupHook; fn != nil {
 		fn(g)
 	}
+	if opts.Unregistered {
+		return g
+	}
 	// 将新建的Group添加到全局的groups中，kv的形式，根据不同的name区别不同的group。
 	groups[name] = g
 	return g
@@ -149,7 +331,11 @@ type Group struct {
 	getter     Getter		// 缓存失效时从源数据的回调函数
 	peersOnce  sync.Once
 	peers      PeerPicker	// 与http部分进行联结的接口
-	cacheBytes int64 // limit for sum of mainCache and hotCache size
+	opts       GroupOptions
+
+	// settings holds the *settings in effect; see the Set methods.
+	settings   atomic.Value
+	settingsMu sync.Mutex // serializes updates of settings
 
 	// mainCache is a cache of the keys for which this process
 	// (amongst its peers) is authoritative. That is, this cache
@@ -172,6 +358,24 @@ type Group struct {
 	// concurrent callers.
 	loadGroup flightGroup	// 为fiightGroup是一个合并操作的部分
 
+	// closed is closed by Close.
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// evicting holds a token while a background eviction down to
+	// SoftCacheBytes runs.
+	evicting chan struct{}
+
+	// negative is the filter of missing keys, if NegativeFilterBits
+	// is set.
+	negative *negativeFilter
+
+	// admission counts key requests, if AdmissionCounters is set.
+	admission *lru.CountMinSketch
+
+	// rates samples Stats for Rates.
+	rates *rateRing
+
 	_ int32 // force Stats to be 8-byte aligned on 32-bit platforms
 
 	// Stats are statistics on the group.
@@ -186,6 +390,15 @@ type flightGroup interface {
 	Do(key string, fn func() (interface{}, error)) (interface{}, error)
 }
 
+// InFlight returns the group's loads in progress, oldest first, if its
+// flightGroup tracks them.
+func (g *Group) InFlight() []singleflight.Flight {
+	if fg, ok := g.loadGroup.(interface{ InFlight() []singleflight.Flight }); ok {
+		return fg.InFlight()
+	}
+	return nil
+}
+
 // Stats are per-group statistics.
 type Stats struct {
 	Gets           AtomicInt // any Get request, including from peers
@@ -196,7 +409,13 @@ type Stats struct {
 	LoadsDeduped   AtomicInt // after singleflight
 	LocalLoads     AtomicInt // total good local loads
 	LocalLoadErrs  AtomicInt // total bad local loads
+	LoadsRejected  AtomicInt // local loads refused or abandoned for MaxConcurrentLoads
 	ServerRequests AtomicInt // gets that came over the network from peers
+
+	PeerNotModified AtomicInt // peer loads that revalidated a stale hot cache value
+	NegativeHits    AtomicInt // gets failed by the negative filter
+	NotAdmitted     AtomicInt // loaded values not cached by AdmissionCounters
+	ServedBytes     AtomicInt // bytes of the values returned by Get
 }
 
 // Name returns the name of the group.
@@ -210,18 +429,51 @@ func (g *Group) initPeers() {
 	}
 }
 
-func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
+// Get populates dest with the value of key, from a cache or by loading
+// it. Options change how the value is found for this call only.
+//
+// To load one group's values from another, wrap the call in a
+// GetterFunc; the options make Get's signature differ from Getter's.
+func (g *Group) Get(ctx context.Context, key string, dest Sink, opts ...GetOption) error {
+	if g.isClosed() {
+		return ErrGroupClosed
+	}
 	g.peersOnce.Do(g.initPeers)
+	g.sampleRates()
 	g.Stats.Gets.Add(1)
 	if dest == nil {
 		return errors.New("groupcache: nil dest Sink")
 	}
+	var start time.Time
+	if g.opts.Recorder != nil {
+		start = time.Now()
+	}
+	o := newGetOptions(opts)
+	if o.peekOnly {
+		o.forceRefresh = false
+	}
+	ck := g.cacheKey(key)
+	if g.admission != nil && !o.peekOnly {
+		g.admission.Add(ck)
+	}
 	// 现在mainCache中查询缓存，存在直接返回value
-	value, cacheHit := g.lookupCache(key)
-
-	if cacheHit {
-		g.Stats.CacheHits.Add(1)
-		return setSinkView(dest, value)
+	if !o.forceRefresh {
+		value, src, cacheHit := g.findCached(ck, o)
+		if cacheHit {
+			g.Stats.CacheHits.Add(1)
+			g.Stats.ServedBytes.Add(int64(value.Len()))
+			g.record(start, key, value.Len(), src)
+			return setSinkView(dest, value)
+		}
+	}
+	if o.peekOnly {
+		return ErrNotCached
+	}
+	// 已知不存在的key直接返回。
+	if g.negative != nil && !o.forceRefresh && g.negative.has(ck) {
+		g.Stats.NegativeHits.Add(1)
+		g.record(start, key, 0, SourceError)
+		return fmt.Errorf("%w: %q is in the negative filter", ErrNotFound, key)
 	}
 	// 缓存不存在，则调用 load 方法；
 	// load 调用 getLocally（分布式场景下会调用 getFromPeer 从其他节点获取）；
@@ -232,11 +484,19 @@ func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
 	// (if local) will set this; the losers will not. The common
 	// case will likely be one caller.
 	destPopulated := false
-	value, destPopulated, err := g.load(ctx, key, dest)
+	value, src, destPopulated, err := g.load(ctx, key, ck, dest, o)
 	if err != nil {
+		g.record(start, key, 0, SourceError)
 		return err
 	}
+	g.Stats.ServedBytes.Add(int64(value.Len()))
+	g.record(start, key, value.Len(), src)
 	if destPopulated {
+		// The getter populated dest, but only the group knows when
+		// the value expires.
+		if es, ok := dest.(interface{ setExpire(time.Time) }); ok {
+			es.setExpire(value.e)
+		}
 		return nil
 	}
 	return setSinkView(dest, value)
@@ -246,9 +506,12 @@ func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
 // 使用 PickPeer() 方法选择节点；
 // 若非本机节点，则调用 getFromPeer() 从远程获取；
 // 若是本机节点或失败，则回退到 getLocally()。
-func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView, destPopulated bool, err error) {
+// ck is the cache key of key. src is where the value came from,
+// SourceShared if another caller's load found it.
+func (g *Group) load(ctx context.Context, key, ck string, dest Sink, o getOptions) (value ByteView, src GetSource, destPopulated bool, err error) {
 	g.Stats.Loads.Add(1)
-	viewi, err := g.loadGroup.Do(key, func() (interface{}, error) {
+	src = SourceShared
+	viewi, err := g.loadGroup.Do(ck, func() (interface{}, error) {
 		// Check the cache again because singleflight can only dedup calls
 		// that overlap concurrently.  It's possible for 2 concurrent
 		// requests to miss the cache, resulting in 2 load() calls.  An
@@ -271,20 +534,30 @@ func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView
 		// 2: loadGroup.Do("key", fn)
 		// 2: fn()
 
-		// 这里又查一次。
-		if value, cacheHit := g.lookupCache(key); cacheHit {
-			g.Stats.CacheHits.Add(1)
-			return value, nil
+		// 这里又查一次。强制刷新时不看缓存。
+		if !o.forceRefresh {
+			if value, which, cacheHit := g.findCached(ck, o); cacheHit {
+				g.Stats.CacheHits.Add(1)
+				src = which
+				return value, nil
+			}
 		}
 		g.Stats.LoadsDeduped.Add(1)
 		var value ByteView
 		var err error
-		if peer, ok := g.peers.PickPeer(key); ok {
-			value, err = g.getFromPeer(ctx, peer, key)
+		if peer, ok := g.pickPeer(ctx, ck); ok {
+			value, err = g.getFromPeer(ctx, peer, key, ck, o)
 			if err == nil {
 				g.Stats.PeerLoads.Add(1)
+				src = SourcePeer
 				return value, nil
 			}
+			if errors.Is(err, ErrNotFound) {
+				// The owner's Getter has spoken; loading the key here
+				// would only ask the origin again.
+				g.addNegative(ck)
+				return nil, err
+			}
 			g.Stats.PeerErrors.Add(1)
 			// TODO(bradfitz): log the peer's error? keep
 			// log of the past few for /groupcachez?  It's
@@ -294,11 +567,20 @@ func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView
 		value, err = g.getLocally(ctx, key, dest)
 		if err != nil {
 			g.Stats.LocalLoadErrs.Add(1)
+			if errors.Is(err, ErrNotFound) {
+				g.addNegative(ck)
+			}
 			return nil, err
 		}
 		g.Stats.LocalLoads.Add(1)
 		destPopulated = true // only one caller of load gets this return value
-		g.populateCache(key, value, &g.mainCache)
+		src = SourceOrigin
+		value.e = g.expiry()
+		if o.forceRefresh {
+			// Drop the old value first, so it isn't counted twice.
+			g.mainCache.remove(ck)
+		}
+		g.populateCache(ck, value, &g.mainCache)
 		return value, nil
 	})
 	if err == nil {
@@ -307,61 +589,263 @@ func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView
 	return
 }
 
+// addNegative records that key is missing, if the group has a
+// negative filter.
+func (g *Group) addNegative(key string) {
+	if g.negative != nil {
+		g.negative.add(key)
+	}
+}
+
 func (g *Group) getLocally(ctx context.Context, key string, dest Sink) (ByteView, error) {
-	err := g.getter.Get(ctx, key, dest)
+	sem, err := g.acquireLoad(ctx)
+	if err != nil {
+		g.Stats.LoadsRejected.Add(1)
+		return ByteView{}, err
+	}
+	defer releaseLoad(sem)
+	err = g.getter.Get(ctx, key, dest)
 	if err != nil {
 		return ByteView{}, err
 	}
 	return dest.view()
 }
 
+// acquireLoad takes a Getter slot, waiting for one unless the group
+// rejects overload. The slot must be given back to releaseLoad.
+func (g *Group) acquireLoad(ctx context.Context) (sem chan struct{}, err error) {
+	sem = g.current().loadSem
+	if sem == nil {
+		return nil, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return sem, nil
+	default:
+	}
+	if g.opts.RejectOverload {
+		return nil, ErrOverloaded
+	}
+	select {
+	case sem <- struct{}{}:
+		return sem, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func releaseLoad(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
+
 // 实现了 PeerGetter 接口的 httpGetter 从访问远程节点，获取缓存值。
-func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key, ck string, o getOptions) (ByteView, error) {
 	req := &pb.GetRequest{
 		Group: &g.name,
 		Key:   &key,
 	}
+	var stale ByteView
+	var revalidate bool
+	switch {
+	case o.skipHotCache:
+	case o.forceRefresh:
+		refresh := true
+		req.Refresh = &refresh
+		g.hotCache.remove(ck)
+	default:
+		// 热点缓存中有过期的副本时，带上它的etag，值没变的话peer只需回复"未修改"。
+		stale, revalidate = g.hotCache.stale(ck)
+	}
+	if revalidate {
+		tag := etag(stale)
+		req.IfNoneMatch = &tag
+	}
 	res := &pb.GetResponse{}
 	// 从peer中进行查找。
 	err := peer.Get(ctx, req, res)
 	if err != nil {
 		return ByteView{}, err
 	}
+	if o.skipHotCache {
+		value := ByteView{b: res.Value}
+		if res.Expire != nil {
+			value.e = time.Unix(0, *res.Expire)
+		}
+		return value, nil
+	}
+	if !revalidate {
+		return g.peerValue(ck, res.Value, res.Expire), nil
+	}
+	if res.GetNotModified() {
+		g.Stats.PeerNotModified.Add(1)
+		stale.e = time.Time{}
+		if res.Expire != nil {
+			stale.e = time.Unix(0, *res.Expire)
+		}
+		g.hotCache.renew(ck, stale.e)
+		return stale, nil
+	}
+	// The value changed; replace the stale copy, which was hot enough
+	// to be cached.
 	value := ByteView{b: res.Value}
+	if res.Expire != nil {
+		value.e = time.Unix(0, *res.Expire)
+	}
+	g.hotCache.remove(ck)
+	g.populateCache(ck, value, &g.hotCache)
+	return value, nil
+}
+
+// peerValue returns the value b sent by a peer for the key cached
+// under ck, which expires at the unix nanoseconds expire if set,
+// possibly copying it into the hot cache.
+func (g *Group) peerValue(ck string, b []byte, expire *int64) ByteView {
+	value := ByteView{b: b}
+	if expire != nil {
+		// 使用owner节点给出的过期时间，热点缓存中的副本不会比owner活得更久。
+		value.e = time.Unix(0, *expire)
+	}
 	// TODO(bradfitz): use res.MinuteQps or something smart to
 	// conditionally populate hotCache.  For now just do it some
 	// percentage of the time.
 	if rand.Intn(10) == 0 {
-		g.populateCache(key, value, &g.hotCache)
+		// Drop any expired copy first, so it isn't counted twice.
+		g.hotCache.remove(ck)
+		g.populateCache(ck, value, &g.hotCache)
+	}
+	return value
+}
+
+// hashedKeyBytes is the length of the hash ending shortened keys,
+// with its separator.
+const hashedKeyBytes = 1 + 2*16
+
+// cacheKey returns the key under which key is cached and routed to
+// peers: key itself or, if it is longer than MaxKeyBytes, a prefix of
+// it followed by a hash of all of it.
+func (g *Group) cacheKey(key string) string {
+	max := g.opts.MaxKeyBytes
+	if max <= 0 || len(key) <= max {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return key[:max-hashedKeyBytes] + "#" + hex.EncodeToString(sum[:16])
+}
+
+// expiry returns the expiration of a value loaded now, or the zero
+// time if the group's values do not expire.
+func (g *Group) expiry() time.Time {
+	s := g.current()
+	if s.ttl <= 0 {
+		return time.Time{}
+	}
+	ttl := s.ttl
+	if s.ttlJitter > 0 {
+		ttl += time.Duration(rand.Int63n(int64(s.ttlJitter)))
+	}
+	return time.Now().Add(ttl)
+}
+
+// Close unregisters the group, if it is registered, and drops its
+// cached values. Operations on a closed group fail with
+// ErrGroupClosed, and its name may be used for a new group.
+func (g *Group) Close() error {
+	g.closeOnce.Do(func() {
+		close(g.closed)
+		mu.Lock()
+		if groups[g.name] == g {
+			delete(groups, g.name)
+		}
+		mu.Unlock()
+		g.mainCache.clear()
+		g.hotCache.clear()
+	})
+	return nil
+}
+
+func (g *Group) isClosed() bool {
+	select {
+	case <-g.closed:
+		return true
+	default:
+		return false
 	}
-	return value, nil
 }
 
-func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
-	if g.cacheBytes <= 0 {
+// pickPeer picks the peer to load key from. A replica serving a peer
+// sends the request on to the key's owner rather than to another
+// replica, which could forward it back.
+func (g *Group) pickPeer(ctx context.Context, key string) (ProtoGetter, bool) {
+	if op, ok := g.peers.(ownerPicker); ok && isPeerRequest(ctx) {
+		return op.pickOwner(key)
+	}
+	return g.peers.PickPeer(key)
+}
+
+// clientOnly reports whether the group's peers are configured so that
+// this process never holds data.
+func (g *Group) clientOnly() bool {
+	co, ok := g.peers.(clientOnlyPicker)
+	return ok && co.clientOnly()
+}
+
+func (g *Group) lookupCache(key string, o getOptions) (value ByteView, ok bool) {
+	value, _, ok = g.findCached(key, o)
+	return
+}
+
+// findCached is like lookupCache, also telling which cache had key.
+func (g *Group) findCached(key string, o getOptions) (value ByteView, src GetSource, ok bool) {
+	if g.current().cacheBytes <= 0 {
 		return
 	}
 	// 先在mainCache中查，没有再在hotCache中查。
-	value, ok = g.mainCache.get(key)
-	if ok {
+	if value, ok = g.mainCache.get(key); ok {
+		return value, SourceMainCache, true
+	}
+	if o.skipHotCache {
 		return
 	}
 	value, ok = g.hotCache.get(key)
-	return
+	return value, SourceHotCache, ok
 }
 
 func (g *Group) populateCache(key string, value ByteView, cache *cache) {
 	// 因为没查到，所以要把这个数据刷到缓存中，可能需要缓存淘汰。
-	if g.cacheBytes <= 0 {
+	cacheBytes := g.current().cacheBytes
+	if cacheBytes <= 0 || g.clientOnly() {
+		return
+	}
+	if g.admission != nil && !g.admit(key, int64(len(key)+value.Len()), cacheBytes) {
+		g.Stats.NotAdmitted.Add(1)
 		return
 	}
 	cache.add(key, value)
 
 	// Evict items from cache(s) if necessary.
+	g.evictTo(cacheBytes)
+	if g.evicting == nil || g.mainCache.bytes()+g.hotCache.bytes() <= g.opts.SoftCacheBytes {
+		return
+	}
+	// 超过软限制，在后台淘汰，同一时间只有一个goroutine在淘汰。
+	select {
+	case g.evicting <- struct{}{}:
+		go func() {
+			defer func() { <-g.evicting }()
+			g.evictTo(g.opts.SoftCacheBytes)
+		}()
+	default:
+	}
+}
+
+// evictTo evicts items from the caches until their sum fits in limit.
+func (g *Group) evictTo(limit int64) {
 	for {
 		mainBytes := g.mainCache.bytes()
 		hotBytes := g.hotCache.bytes()
-		if mainBytes+hotBytes <= g.cacheBytes {
+		if mainBytes+hotBytes <= limit {
 			return
 		}
 
@@ -409,20 +893,42 @@ func (g *Group) CacheStats(which CacheType) CacheStats {
 type cache struct {
 	mu         sync.RWMutex
 	nbytes     int64 // of all keys and values
-	lru        *lru.Cache
+	lru        lru.Interface
+	policy     CachePolicy // creates lru; nil means LRUPolicy
 	nhit, nget int64
-	nevict     int64 // number of evictions
+	nevict     int64      // number of evictions
+	arena      *arena     // if non-nil, holds the values
+	store      ValueStore // if non-nil, holds the values instead
+
+	// keepExpired keeps expired values, which get misses, until
+	// they are evicted, so that they can be revalidated with their
+	// owner instead of fetched again.
+	keepExpired bool
+}
+
+// cacheEntry is the value type of cache.lru, by pointer so that arena
+// compaction can move values in place.
+type cacheEntry struct {
+	value  ByteView
+	chunk  *arenaChunk // the arena allocation holding value, if any
+	stored int         // length of the value in cache.store, if any
+	added  time.Time
+}
+
+func (e cacheEntry) len() int {
+	return e.value.Len() + e.stored
 }
 
 func (c *cache) stats() CacheStats {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return CacheStats{
-		Bytes:     c.nbytes,
-		Items:     c.itemsLocked(),
-		Gets:      c.nget,
-		Hits:      c.nhit,
-		Evictions: c.nevict,
+		Bytes:      c.nbytes,
+		Items:      c.itemsLocked(),
+		Gets:       c.nget,
+		Hits:       c.nhit,
+		Evictions:  c.nevict,
+		ArenaBytes: c.arenaBytesLocked(),
 	}
 }
 
@@ -430,15 +936,43 @@ func (c *cache) add(key string, value ByteView) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.lru == nil {
-		c.lru = &lru.Cache{
-			OnEvicted: func(key lru.Key, value interface{}) {
-				val := value.(ByteView)
-				c.nbytes -= int64(len(key.(string))) + int64(val.Len())
-				c.nevict++
-			},
+		policy := c.policy
+		if policy == nil {
+			policy = LRUPolicy()
+		}
+		c.lru = policy(func(key lru.Key, value interface{}) {
+			e := value.(*cacheEntry)
+			c.nbytes -= int64(len(key.(string))) + int64(e.len())
+			c.nevict++
+			if c.arena != nil {
+				c.arena.free(e.chunk, e.value.Len())
+			}
+			if c.store != nil {
+				c.store.Remove(key.(string))
+			}
+		})
+	}
+	e := &cacheEntry{value: value}
+	switch {
+	case c.store != nil:
+		b := value.b
+		if b == nil {
+			b = []byte(value.s)
+		}
+		// 值写入store，lru中只保留索引。
+		if err := c.store.Put(key, b); err != nil {
+			return
+		}
+		e = &cacheEntry{value: ByteView{e: value.e}, stored: len(b)}
+	case c.arena != nil:
+		// 把value拷贝进arena的大块内存中。
+		e.value, e.chunk = c.arena.alloc(value)
+		if c.arena.fragmented() {
+			c.compactLocked()
 		}
 	}
-	c.lru.Add(key, value)
+	e.added = time.Now()
+	c.lru.Add(key, e)
 	c.nbytes += int64(len(key)) + int64(value.Len())
 }
 
@@ -453,8 +987,70 @@ func (c *cache) get(key string) (value ByteView, ok bool) {
 	if !ok {
 		return
 	}
+	e := vi.(*cacheEntry)
+	if e.value.expired(time.Now()) {
+		// 过期的值当作未命中，由调用者重新加载。
+		if !c.keepExpired {
+			c.lru.Remove(key)
+		}
+		return ByteView{}, false
+	}
+	if c.store != nil {
+		b, ok := c.store.Get(key)
+		if !ok {
+			// The store dropped the value.
+			c.lru.Remove(key)
+			return ByteView{}, false
+		}
+		c.nhit++
+		return ByteView{b: b, e: e.value.e}, true
+	}
 	c.nhit++
-	return vi.(ByteView), true
+	return e.value, true
+}
+
+// stale returns key's value if it is cached but expired.
+func (c *cache) stale(key string) (value ByteView, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru == nil || c.store != nil {
+		return
+	}
+	vi, ok := c.lru.Get(key)
+	if !ok || !vi.(*cacheEntry).value.expired(time.Now()) {
+		return ByteView{}, false
+	}
+	return vi.(*cacheEntry).value, true
+}
+
+// renew sets the expiration of key's cached value.
+func (c *cache) renew(key string, expire time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru == nil {
+		return
+	}
+	if vi, ok := c.lru.Get(key); ok {
+		e := vi.(*cacheEntry)
+		e.value.e = expire
+		c.lru.Add(key, e)
+	}
+}
+
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru != nil {
+		c.lru.Remove(key)
+	}
+}
+
+func (c *cache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru != nil {
+		c.lru.Clear()
+	}
 }
 
 func (c *cache) removeOldest() {
@@ -477,6 +1073,13 @@ func (c *cache) items() int64 {
 	return c.itemsLocked()
 }
 
+func (c *cache) arenaBytesLocked() int64 {
+	if c.arena == nil {
+		return 0
+	}
+	return c.arena.bytes
+}
+
 func (c *cache) itemsLocked() int64 {
 	if c.lru == nil {
 		return 0
@@ -508,4 +1111,8 @@ type CacheStats struct {
 	Gets      int64
 	Hits      int64
 	Evictions int64
+
+	// ArenaBytes is the memory held by the cache's arena, if it
+	// uses one (see GroupOptions.UseArena).
+	ArenaBytes int64
 }
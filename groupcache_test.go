@@ -25,6 +25,7 @@ import (
 	"hash/crc32"
 	"math/rand"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -38,7 +39,7 @@ import (
 
 var (
 	once                    sync.Once
-	stringGroup, protoGroup Getter
+	stringGroup, protoGroup *Group
 
 	stringc = make(chan string)
 
@@ -203,7 +204,7 @@ func TestCacheEviction(t *testing.T) {
 		t.Fatalf("expected 1 cache fill; got %d", fills)
 	}
 
-	g := stringGroup.(*Group)
+	g := stringGroup
 	evict0 := g.mainCache.nevict
 
 	// Trash the cache with other keys.
@@ -295,7 +296,7 @@ func TestPeers(t *testing.T) {
 	}
 	resetCacheSize := func(maxBytes int64) {
 		g := testGroup
-		g.cacheBytes = maxBytes
+		g.SetCacheBytes(maxBytes)
 		g.mainCache = cache{}
 		g.hotCache = cache{}
 	}
@@ -446,6 +447,55 @@ func TestNoDedup(t *testing.T) {
 	}
 }
 
+func TestMaxConcurrentLoads(t *testing.T) {
+	for _, reject := range []bool{true, false} {
+		started := make(chan bool)
+		release := make(chan bool)
+		getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			started <- true
+			<-release
+			return dest.SetString(key)
+		})
+		g := NewGroupOpts("max-loads", 0, getter, &GroupOptions{
+			Peers:              NoPeers{},
+			Unregistered:       true,
+			MaxConcurrentLoads: 1,
+			RejectOverload:     reject,
+		})
+
+		done := make(chan error)
+		go func() {
+			var s string
+			done <- g.Get(dummyCtx, "first", StringSink(&s))
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(dummyCtx, 20*time.Millisecond)
+		var s string
+		err := g.Get(ctx, "second", StringSink(&s))
+		cancel()
+		want := context.DeadlineExceeded
+		if reject {
+			want = ErrOverloaded
+		}
+		if err != want {
+			t.Errorf("reject=%v: overloaded Get = %v; want %v", reject, err, want)
+		}
+		if got := g.Stats.LoadsRejected.Get(); got != 1 {
+			t.Errorf("reject=%v: LoadsRejected = %d; want 1", reject, got)
+		}
+
+		release <- true
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		go func() { <-started; release <- true }()
+		if err := g.Get(dummyCtx, "third", StringSink(&s)); err != nil {
+			t.Errorf("reject=%v: Get after release = %v", reject, err)
+		}
+	}
+}
+
 func TestGroupStatsAlignment(t *testing.T) {
 	var g Group
 	off := unsafe.Offsetof(g.Stats)
@@ -456,3 +506,266 @@ func TestGroupStatsAlignment(t *testing.T) {
 
 // TODO(bradfitz): port the Google-internal full integration test into here,
 // using HTTP requests instead of our RPC system.
+
+func TestTTL(t *testing.T) {
+	var loads int
+	g := NewGroupOpts("ttl", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString(fmt.Sprint(key, loads))
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, TTL: 20 * time.Millisecond})
+
+	var v ByteView
+	if err := g.Get(dummyCtx, "k", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if e := v.Expire(); e.IsZero() || time.Until(e) > 20*time.Millisecond {
+		t.Errorf("Expire = %v; want within 20ms", e)
+	}
+	g.Get(dummyCtx, "k", ByteViewSink(&v))
+	if loads != 1 {
+		t.Fatalf("loads = %d before expiry; want 1", loads)
+	}
+	time.Sleep(25 * time.Millisecond)
+	if err := g.Get(dummyCtx, "k", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if loads != 2 || v.String() != "k2" {
+		t.Errorf("after expiry got %q with %d loads; want k2, 2", v.String(), loads)
+	}
+}
+
+func TestTTLJitter(t *testing.T) {
+	g := NewGroupOpts("ttl-jitter", 0, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, TTL: time.Hour, TTLJitter: time.Hour})
+
+	seen := make(map[time.Duration]bool)
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		var v ByteView
+		if err := g.Get(dummyCtx, fmt.Sprint(i), ByteViewSink(&v)); err != nil {
+			t.Fatal(err)
+		}
+		ttl := v.Expire().Sub(start)
+		if ttl < time.Hour || ttl > 2*time.Hour+time.Second {
+			t.Errorf("ttl = %v; want in [1h, 2h)", ttl)
+		}
+		seen[ttl.Truncate(time.Minute)] = true
+	}
+	if len(seen) < 5 {
+		t.Errorf("20 loads expire in only %d distinct minutes", len(seen))
+	}
+}
+
+type expiringPeer time.Time
+
+func (p expiringPeer) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	out.Value = []byte(in.GetKey())
+	out.Expire = proto.Int64(time.Time(p).UnixNano())
+	return nil
+}
+
+func TestPeerExpire(t *testing.T) {
+	exp := time.Now().Add(time.Minute).Round(0)
+	g := NewGroupOpts("peer-expire", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Errorf("unexpected local load of %q", key)
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: fakePeers{expiringPeer(exp)}, Unregistered: true, TTL: time.Hour})
+
+	var v ByteView
+	if err := g.Get(dummyCtx, "k", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Expire().Equal(exp) {
+		t.Errorf("Expire = %v; want the peer's %v", v.Expire(), exp)
+	}
+}
+
+func TestSoftCacheBytes(t *testing.T) {
+	const soft, hard = 1000, 2000
+	g := NewGroupOpts("soft-limit", hard, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key + strings.Repeat("x", 90))
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, SoftCacheBytes: soft})
+
+	for i := 0; i < 100; i++ {
+		var s string
+		if err := g.Get(dummyCtx, fmt.Sprintf("key-%03d", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if b := g.mainCache.bytes(); b > hard {
+			t.Fatalf("cache holds %d bytes; over the hard limit %d", b, hard)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for g.mainCache.bytes() > soft {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache holds %d bytes; background eviction never reached %d", g.mainCache.bytes(), soft)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxKeyBytes(t *testing.T) {
+	var loaded []string
+	g := NewGroupOpts("max-key-bytes", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loaded = append(loaded, key)
+		return dest.SetString("v")
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, MaxKeyBytes: 64})
+
+	long := strings.Repeat("k", 5000)
+	for _, key := range []string{long, long, long + "x", "short"} {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(loaded) != 3 || loaded[0] != long || loaded[1] != long+"x" {
+		t.Errorf("getter loaded %d keys; want the 2 full long keys and short", len(loaded))
+	}
+	items, _, err := g.Scan(dummyCtx, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range items {
+		if len(it.Key) > 64 {
+			t.Errorf("cached key of %d bytes; want at most 64", len(it.Key))
+		}
+		if it.Key != "short" && !strings.HasPrefix(it.Key, "kkkk") {
+			t.Errorf("cached key %q lost the key's prefix", it.Key)
+		}
+	}
+
+	// Bounds too small for the hash are raised to fit it.
+	small := NewGroupOpts("max-key-bytes-small", cacheSize, g.getter, &GroupOptions{Peers: NoPeers{}, Unregistered: true, MaxKeyBytes: 10})
+	a, b := small.cacheKey(long), small.cacheKey(long+"x")
+	if len(a) != hashedKeyBytes || a == b {
+		t.Errorf("cache keys with a bound of 10: %q and %q; want distinct keys of %d bytes", a, b, hashedKeyBytes)
+	}
+	if k := small.cacheKey(strings.Repeat("k", hashedKeyBytes)); k != strings.Repeat("k", hashedKeyBytes) {
+		t.Errorf("key within the raised bound was shortened to %q", k)
+	}
+}
+
+func TestGroupClose(t *testing.T) {
+	const name = "TestGroupClose-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), NoPeers{})
+	var s string
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); !errors.Is(err, ErrGroupClosed) {
+		t.Errorf("Get after Close = %v; want ErrGroupClosed", err)
+	}
+	if _, _, err := g.Scan(dummyCtx, "", 1); !errors.Is(err, ErrGroupClosed) {
+		t.Errorf("Scan after Close = %v; want ErrGroupClosed", err)
+	}
+	if GetGroup(name) != nil || g.mainCache.items() != 0 {
+		t.Errorf("closed group still registered or caching %d items", g.mainCache.items())
+	}
+	g.Close()
+	// The name is free again.
+	newGroup(name, cacheSize, g.getter, NoPeers{})
+}
+
+func TestGetOptions(t *testing.T) {
+	var loads int
+	g := NewGroupOpts("get-options", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString(fmt.Sprintf("%s-%d", key, loads))
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true})
+
+	var s string
+	if err := g.Get(dummyCtx, "k", StringSink(&s), PeekOnly()); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("PeekOnly Get of uncached key = %v; want ErrNotCached", err)
+	}
+	if loads != 0 {
+		t.Fatalf("PeekOnly Get loaded the key")
+	}
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	bytes := g.mainCache.bytes()
+	if err := g.Get(dummyCtx, "k", StringSink(&s), PeekOnly(), ForceRefresh()); err != nil || s != "k-1" {
+		t.Fatalf("PeekOnly Get = %q, %v; want the cached k-1", s, err)
+	}
+	if err := g.Get(dummyCtx, "k", StringSink(&s), ForceRefresh()); err != nil || s != "k-2" {
+		t.Fatalf("ForceRefresh Get = %q, %v; want a reloaded k-2", s, err)
+	}
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); err != nil || s != "k-2" {
+		t.Fatalf("Get after refresh = %q, %v; want k-2", s, err)
+	}
+	if b := g.mainCache.bytes(); b != bytes {
+		t.Errorf("cache holds %d bytes after refresh; want %d", b, bytes)
+	}
+}
+
+// refreshPeer answers with its key and records whether it was asked
+// to refresh it.
+type refreshPeer struct{ refreshed *bool }
+
+func (p refreshPeer) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	*p.refreshed = in.GetRefresh()
+	out.Value = []byte("peer:" + in.GetKey())
+	return nil
+}
+
+func TestGetOptionsPeer(t *testing.T) {
+	var refreshed bool
+	g := NewGroupOpts("get-options-peer", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Errorf("unexpected local load of %q", key)
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: fakePeers{refreshPeer{&refreshed}}, Unregistered: true})
+	g.hotCache.add("k", ByteView{s: "hot"})
+
+	var s string
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); err != nil || s != "hot" {
+		t.Fatalf("Get = %q, %v; want the hot copy", s, err)
+	}
+	if err := g.Get(dummyCtx, "k", StringSink(&s), SkipHotCache()); err != nil || s != "peer:k" {
+		t.Fatalf("SkipHotCache Get = %q, %v; want the peer's value", s, err)
+	}
+	if refreshed {
+		t.Errorf("plain Get asked the peer to refresh")
+	}
+	if _, ok := g.hotCache.get("k"); !ok {
+		t.Errorf("SkipHotCache Get dropped the hot copy")
+	}
+	if err := g.Get(dummyCtx, "k", StringSink(&s), ForceRefresh()); err != nil || s != "peer:k" {
+		t.Fatalf("ForceRefresh Get = %q, %v; want the peer's value", s, err)
+	}
+	if !refreshed {
+		t.Errorf("ForceRefresh Get did not ask the peer to refresh")
+	}
+	if v, ok := g.hotCache.get("k"); ok && v.String() == "hot" {
+		t.Errorf("ForceRefresh Get kept the old hot copy")
+	}
+}
+
+func TestSetCacheBytes(t *testing.T) {
+	g := NewGroupOpts("set-cache-bytes", 10000, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(strings.Repeat("x", 100))
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true})
+	var s string
+	for i := 0; i < 50; i++ {
+		g.Get(dummyCtx, fmt.Sprint(i), StringSink(&s))
+	}
+	if n := g.mainCache.items(); n != 50 {
+		t.Fatalf("cache holds %d items; want 50", n)
+	}
+	g.SetCacheBytes(1000)
+	if b := g.mainCache.bytes(); b > 1000 {
+		t.Errorf("cache holds %d bytes after SetCacheBytes(1000)", b)
+	}
+	if _, ok := g.mainCache.get("49"); !ok {
+		t.Errorf("SetCacheBytes dropped the most recent value")
+	}
+
+	g.SetTTL(time.Minute, 0)
+	g.Get(dummyCtx, "new", StringSink(&s))
+	if v, _ := g.mainCache.get("new"); v.Expire().IsZero() {
+		t.Errorf("value loaded after SetTTL does not expire")
+	}
+}
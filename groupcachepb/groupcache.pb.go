@@ -16,6 +16,8 @@ var _ = math.Inf
 type GetRequest struct {
 	Group            *string `protobuf:"bytes,1,req,name=group" json:"group,omitempty"`
 	Key              *string `protobuf:"bytes,2,req,name=key" json:"key,omitempty"`
+	IfNoneMatch      *string `protobuf:"bytes,3,opt,name=if_none_match" json:"if_none_match,omitempty"`
+	Refresh          *bool   `protobuf:"varint,4,opt,name=refresh" json:"refresh,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
 }
 
@@ -37,9 +39,26 @@ func (m *GetRequest) GetKey() string {
 	return ""
 }
 
+func (m *GetRequest) GetIfNoneMatch() string {
+	if m != nil && m.IfNoneMatch != nil {
+		return *m.IfNoneMatch
+	}
+	return ""
+}
+
+func (m *GetRequest) GetRefresh() bool {
+	if m != nil && m.Refresh != nil {
+		return *m.Refresh
+	}
+	return false
+}
+
 type GetResponse struct {
 	Value            []byte   `protobuf:"bytes,1,opt,name=value" json:"value,omitempty"`
 	MinuteQps        *float64 `protobuf:"fixed64,2,opt,name=minute_qps" json:"minute_qps,omitempty"`
+	Expire           *int64   `protobuf:"varint,3,opt,name=expire" json:"expire,omitempty"`
+	Etag             *string  `protobuf:"bytes,4,opt,name=etag" json:"etag,omitempty"`
+	NotModified      *bool    `protobuf:"varint,5,opt,name=not_modified" json:"not_modified,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -61,5 +80,98 @@ func (m *GetResponse) GetMinuteQps() float64 {
 	return 0
 }
 
+func (m *GetResponse) GetExpire() int64 {
+	if m != nil && m.Expire != nil {
+		return *m.Expire
+	}
+	return 0
+}
+
+func (m *GetResponse) GetEtag() string {
+	if m != nil && m.Etag != nil {
+		return *m.Etag
+	}
+	return ""
+}
+
+func (m *GetResponse) GetNotModified() bool {
+	if m != nil && m.NotModified != nil {
+		return *m.NotModified
+	}
+	return false
+}
+
+type GetMultiRequest struct {
+	Group            *string  `protobuf:"bytes,1,req,name=group" json:"group,omitempty"`
+	Key              []string `protobuf:"bytes,2,rep,name=key" json:"key,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *GetMultiRequest) Reset()         { *m = GetMultiRequest{} }
+func (m *GetMultiRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMultiRequest) ProtoMessage()    {}
+
+func (m *GetMultiRequest) GetGroup() string {
+	if m != nil && m.Group != nil {
+		return *m.Group
+	}
+	return ""
+}
+
+func (m *GetMultiRequest) GetKey() []string {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+type GetMultiResponse struct {
+	Key              *string `protobuf:"bytes,1,req,name=key" json:"key,omitempty"`
+	Value            []byte  `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	Expire           *int64  `protobuf:"varint,3,opt,name=expire" json:"expire,omitempty"`
+	Error            *string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	NotFound         *bool   `protobuf:"varint,5,opt,name=not_found" json:"not_found,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetMultiResponse) Reset()         { *m = GetMultiResponse{} }
+func (m *GetMultiResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMultiResponse) ProtoMessage()    {}
+
+func (m *GetMultiResponse) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+func (m *GetMultiResponse) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *GetMultiResponse) GetExpire() int64 {
+	if m != nil && m.Expire != nil {
+		return *m.Expire
+	}
+	return 0
+}
+
+func (m *GetMultiResponse) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func (m *GetMultiResponse) GetNotFound() bool {
+	if m != nil && m.NotFound != nil {
+		return *m.NotFound
+	}
+	return false
+}
+
 func init() {
 }
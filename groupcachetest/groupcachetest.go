@@ -0,0 +1,330 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package groupcachetest provides utilities for testing code that uses
+// groupcache: an in-process cluster of nodes connected by an in-memory
+// transport, so that cache interaction across peers can be exercised
+// deterministically without sockets.
+package groupcachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/golang/groupcache/consistenthash"
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrNodeDown is returned for peer requests sent to a node that has
+// been marked down.
+var ErrNodeDown = errors.New("groupcachetest: node is down")
+
+// Picker is a groupcache.PeerPicker over a fixed set of named peers,
+// placed on a consistent hash like HTTPPool places its peers.
+type Picker struct {
+	self string
+
+	mu    sync.Mutex
+	ring  *consistenthash.Map
+	peers map[string]groupcache.ProtoGetter
+}
+
+// NewPicker returns an empty Picker for the node named self.
+func NewPicker(self string) *Picker {
+	return &Picker{
+		self:  self,
+		ring:  consistenthash.New(50, nil),
+		peers: make(map[string]groupcache.ProtoGetter),
+	}
+}
+
+// Set replaces the picker's peers. The entry named after the picker's
+// own node, if any, only takes part in the ring.
+func (p *Picker) Set(peers map[string]groupcache.ProtoGetter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ring = consistenthash.New(50, nil)
+	p.peers = make(map[string]groupcache.ProtoGetter, len(peers))
+	for name, g := range peers {
+		p.ring.Add(name)
+		p.peers[name] = g
+	}
+}
+
+// PickPeer implements groupcache.PeerPicker.
+func (p *Picker) PickPeer(key string) (groupcache.ProtoGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ring.IsEmpty() {
+		return nil, false
+	}
+	if owner := p.ring.Get(key); owner != p.self {
+		return p.peers[owner], true
+	}
+	return nil, false
+}
+
+// Owner returns the name of the node owning key.
+func (p *Picker) Owner(key string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ring.Get(key)
+}
+
+// A Fault decides the fate of a peer request before it is delivered
+// from one node to another. The request is delayed by the returned
+// duration and then, if err is non-nil, fails with err.
+type Fault func(from, to, group, key string) (delay time.Duration, err error)
+
+// A Cluster is a set of nodes that reach each other through an
+// in-memory transport.
+type Cluster struct {
+	mu     sync.Mutex
+	nodes  []*Node
+	specs  []groupSpec
+	fault  Fault
+	nextID int
+}
+
+type groupSpec struct {
+	name       string
+	cacheBytes int64
+	getter     groupcache.Getter
+}
+
+// NewCluster returns a cluster of n nodes, named "node0" through
+// "node<n-1>".
+func NewCluster(n int) *Cluster {
+	c := new(Cluster)
+	for i := 0; i < n; i++ {
+		c.addNodeLocked()
+	}
+	c.updatePeersLocked()
+	return c
+}
+
+// SetFault installs f to be consulted for every peer request. A nil f
+// removes any fault.
+func (c *Cluster) SetFault(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fault = f
+}
+
+// SetLatency delays every peer request by d.
+func (c *Cluster) SetLatency(d time.Duration) {
+	c.SetFault(func(_, _, _, _ string) (time.Duration, error) { return d, nil })
+}
+
+// Nodes returns the cluster's nodes, in the order they were added.
+func (c *Cluster) Nodes() []*Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Node(nil), c.nodes...)
+}
+
+// Node returns the i'th node.
+func (c *Cluster) Node(i int) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodes[i]
+}
+
+// NewGroup creates a group on every node, present and future. All
+// nodes share getter; use NodeFromContext to tell which node a load
+// runs on.
+func (c *Cluster) NewGroup(name string, cacheBytes int64, getter groupcache.Getter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spec := groupSpec{name, cacheBytes, getter}
+	c.specs = append(c.specs, spec)
+	for _, n := range c.nodes {
+		n.newGroup(spec)
+	}
+}
+
+// AddNode adds a node to the cluster and to every node's peers.
+func (c *Cluster) AddNode() *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.addNodeLocked()
+	c.updatePeersLocked()
+	return n
+}
+
+// RemoveNode removes a node from every node's peers. The removed node
+// keeps its own view of the cluster.
+func (c *Cluster) RemoveNode(n *Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, m := range c.nodes {
+		if m == n {
+			c.nodes = append(c.nodes[:i], c.nodes[i+1:]...)
+			break
+		}
+	}
+	c.updatePeersLocked()
+}
+
+func (c *Cluster) addNodeLocked() *Node {
+	n := &Node{
+		Name:    fmt.Sprintf("node%d", c.nextID),
+		cluster: c,
+		groups:  make(map[string]*groupcache.Group),
+	}
+	c.nextID++
+	n.Picker = NewPicker(n.Name)
+	for _, spec := range c.specs {
+		n.newGroup(spec)
+	}
+	c.nodes = append(c.nodes, n)
+	return n
+}
+
+func (c *Cluster) updatePeersLocked() {
+	for _, from := range c.nodes {
+		peers := make(map[string]groupcache.ProtoGetter, len(c.nodes))
+		for _, to := range c.nodes {
+			peers[to.Name] = &transport{from: from, to: to}
+		}
+		from.Picker.Set(peers)
+	}
+}
+
+func (c *Cluster) faultFor(from, to, group, key string) (time.Duration, error) {
+	c.mu.Lock()
+	f := c.fault
+	c.mu.Unlock()
+	if f == nil {
+		return 0, nil
+	}
+	return f(from, to, group, key)
+}
+
+// A Node is one simulated groupcache process.
+type Node struct {
+	Name   string
+	Picker *Picker
+
+	cluster *Cluster
+
+	mu       sync.Mutex
+	groups   map[string]*groupcache.Group
+	down     bool
+	requests groupcache.AtomicInt
+}
+
+func (n *Node) newGroup(spec groupSpec) {
+	g := groupcache.NewGroupOpts(spec.name, spec.cacheBytes, spec.getter, &groupcache.GroupOptions{
+		Peers:        n.Picker,
+		Unregistered: true,
+	})
+	n.mu.Lock()
+	n.groups[spec.name] = g
+	n.mu.Unlock()
+}
+
+// Group returns the node's instance of the named group, or nil.
+func (n *Node) Group(name string) *groupcache.Group {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.groups[name]
+}
+
+// Get gets key from the node's instance of the named group.
+func (n *Node) Get(ctx context.Context, group, key string, dest groupcache.Sink) error {
+	g := n.Group(group)
+	if g == nil {
+		return fmt.Errorf("groupcachetest: no group %q on %s", group, n.Name)
+	}
+	return g.Get(withNode(ctx, n), key, dest)
+}
+
+// SetDown marks the node as down or up. Peer requests to a down node
+// fail with ErrNodeDown; the node stays in its peers' rings.
+func (n *Node) SetDown(down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.down = down
+}
+
+func (n *Node) isDown() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.down
+}
+
+// PeerRequests returns the number of peer requests the node has served.
+func (n *Node) PeerRequests() int64 {
+	return n.requests.Get()
+}
+
+type nodeKey struct{}
+
+func withNode(ctx context.Context, n *Node) context.Context {
+	return context.WithValue(ctx, nodeKey{}, n)
+}
+
+// NodeFromContext returns the node a Getter is loading on, if the load
+// was started through Node.Get or a peer request.
+func NodeFromContext(ctx context.Context) (*Node, bool) {
+	n, ok := ctx.Value(nodeKey{}).(*Node)
+	return n, ok
+}
+
+// transport delivers peer requests from one node to another.
+type transport struct {
+	from, to *Node
+}
+
+func (t *transport) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	delay, err := t.from.cluster.faultFor(t.from.Name, t.to.Name, in.GetGroup(), in.GetKey())
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if t.to.isDown() {
+		return ErrNodeDown
+	}
+	g := t.to.Group(in.GetGroup())
+	if g == nil {
+		return fmt.Errorf("groupcachetest: no group %q on %s", in.GetGroup(), t.to.Name)
+	}
+	t.to.requests.Add(1)
+	g.Stats.ServerRequests.Add(1)
+	var value groupcache.ByteView
+	if err := g.Get(withNode(ctx, t.to), in.GetKey(), groupcache.ByteViewSink(&value)); err != nil {
+		return err
+	}
+	out.Value = value.ByteSlice()
+	if e := value.Expire(); !e.IsZero() {
+		out.Expire = proto.Int64(e.UnixNano())
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// loadCounter is a Getter recording which node loaded each key.
+type loadCounter struct {
+	mu    sync.Mutex
+	loads map[string][]string // key -> node names
+}
+
+func (l *loadCounter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	n, _ := NodeFromContext(ctx)
+	l.mu.Lock()
+	if l.loads == nil {
+		l.loads = make(map[string][]string)
+	}
+	l.loads[key] = append(l.loads[key], n.Name)
+	l.mu.Unlock()
+	return dest.SetString(n.Name + ":" + key)
+}
+
+func TestClusterLoadsOncePerKey(t *testing.T) {
+	c := NewCluster(3)
+	var loads loadCounter
+	c.NewGroup("g", 1<<20, &loads)
+
+	ctx := context.Background()
+	for _, n := range c.Nodes() {
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			var s string
+			if err := n.Get(ctx, "g", key, groupcache.StringSink(&s)); err != nil {
+				t.Fatal(err)
+			}
+			owner := n.Picker.Owner(key)
+			if want := owner + ":" + key; s != want {
+				t.Errorf("%s: Get(%q) = %q; want %q", n.Name, key, s, want)
+			}
+		}
+	}
+	for key, nodes := range loads.loads {
+		if len(nodes) != 1 {
+			t.Errorf("key %q loaded %d times (on %v); want once", key, len(nodes), nodes)
+		}
+	}
+	var served int64
+	for _, n := range c.Nodes() {
+		served += n.PeerRequests()
+	}
+	if served == 0 {
+		t.Error("no peer requests were served")
+	}
+}
+
+func TestClusterFaults(t *testing.T) {
+	c := NewCluster(2)
+	var loads loadCounter
+	c.NewGroup("g", 0, &loads)
+	errInjected := errors.New("injected")
+	c.SetFault(func(from, to, group, key string) (time.Duration, error) {
+		return 0, errInjected
+	})
+
+	// With every peer request failing, each node loads locally.
+	ctx := context.Background()
+	n0 := c.Node(0)
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		var s string
+		if err := n0.Get(ctx, "g", key, groupcache.StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if want := "node0:" + key; s != want {
+			t.Errorf("Get(%q) = %q; want %q", key, s, want)
+		}
+	}
+	if got := n0.Group("g").Stats.PeerErrors.Get(); got == 0 {
+		t.Error("PeerErrors = 0; want injected failures counted")
+	}
+
+	c.SetFault(nil)
+	c.Node(1).SetDown(true)
+	if err := (&transport{from: n0, to: c.Node(1)}).Get(ctx, nil, nil); err != ErrNodeDown {
+		t.Errorf("request to down node = %v; want ErrNodeDown", err)
+	}
+}
+
+func TestClusterLatency(t *testing.T) {
+	c := NewCluster(2)
+	c.NewGroup("g", 0, &loadCounter{})
+	c.SetLatency(time.Hour)
+
+	// A request that would be delayed past its deadline fails over to
+	// a local load.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n0 := c.Node(0)
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if n0.Picker.Owner(key) == n0.Name {
+			continue
+		}
+		var s string
+		err := n0.Get(ctx, "g", key, groupcache.StringSink(&s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "node0:" + key; s != want {
+			t.Errorf("Get(%q) = %q; want %q", key, s, want)
+		}
+		break
+	}
+}
+
+func TestClusterMembership(t *testing.T) {
+	c := NewCluster(1)
+	c.NewGroup("g", 1<<20, &loadCounter{})
+	n1 := c.AddNode()
+	if n1.Group("g") == nil {
+		t.Fatal("added node has no group")
+	}
+	owners := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		owners[c.Node(0).Picker.Owner(fmt.Sprint(i))] = true
+	}
+	if !owners["node1"] {
+		t.Error("added node owns no keys")
+	}
+	c.RemoveNode(n1)
+	for i := 0; i < 50; i++ {
+		if owner := c.Node(0).Picker.Owner(fmt.Sprint(i)); owner != "node0" {
+			t.Fatalf("after removal, key %d is owned by %s", i, owner)
+		}
+	}
+}
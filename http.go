@@ -19,12 +19,20 @@ package groupcache
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/groupcache/consistenthash"
 	pb "github.com/golang/groupcache/groupcachepb"
@@ -35,6 +43,55 @@ const defaultBasePath = "/_groupcache/"
 
 const defaultReplicas = 50
 
+// peerErrorPenalty is the latency charged to a peer for a failed
+// request, so that erroring peers are steered around like slow ones.
+const peerErrorPenalty = time.Second
+
+// exploreOdds controls how often PickPeer bypasses the latency ranking
+// and uses a key's owner anyway, so that the owner's latency estimate
+// keeps being refreshed after it recovers.
+const exploreOdds = 20
+
+// getPath is the path, below the pool's BasePath, of value requests
+// sent as a GetRequest body because their key is too long for a URL.
+const getPath = "_get"
+
+// maxURLKey is the length of the longest key sent in a URL.
+const maxURLKey = 1024
+
+// errorHeader classifies the error of a failed peer request, so that
+// it can be told apart from failures of the HTTP layer.
+const (
+	errorHeader  = "X-Groupcache-Error"
+	notFoundCode = "not-found"
+)
+
+// peerError returns the error of a peer request that got no response.
+func peerError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		// The caller gave up; keep the context's error visible.
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrPeerUnavailable, err)
+}
+
+// statusError returns the error of a peer response with a status
+// other than OK.
+func statusError(res *http.Response) error {
+	switch {
+	case res.StatusCode == http.StatusNotFound && res.Header.Get(errorHeader) == notFoundCode:
+		return fmt.Errorf("%w: server returned: %v", ErrNotFound, res.Status)
+	case res.StatusCode == http.StatusBadGateway, res.StatusCode == http.StatusServiceUnavailable,
+		res.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: server returned: %v", ErrPeerUnavailable, res.Status)
+	}
+	return fmt.Errorf("server returned: %v", res.Status)
+}
+
+// expireHeader carries the unix nanoseconds expiration of a value on
+// Not Modified responses, which have no body.
+const expireHeader = "X-Groupcache-Expire"
+
 // HTTPPool implements PeerPicker for a pool of HTTP peers.
 // 承载节点间 HTTP 通信的核心数据结构，其中包括服务端、客户端。
 type HTTPPool struct {
@@ -55,10 +112,22 @@ type HTTPPool struct {
 	// opts specifies the options.
 	opts HTTPPoolOptions
 
+	// me recognizes the peer URLs that refer to this process.
+	me *selfMatcher
+
 	mu          sync.Mutex // guards peers and httpGetters
 	peers       *consistenthash.Map	// 根据具体的 key 选择节点
 	// 映射远程节点与对应的 httpGetter。
 	httpGetters map[string]*httpGetter // keyed by e.g. "http://10.0.0.2:8008"
+	selves      map[string]bool        // peers that are this process
+	peerList    []string               // as last passed to Set
+	draining    bool                   // whether Drain is running
+
+	faults *faultInjector // nil unless opts.Faults is set
+
+	// transport is used for peer requests when the options tune peer
+	// connections and Transport is nil.
+	transport *http.Transport
 }
 
 // HTTPPoolOptions are the configurations of a HTTPPool.
@@ -75,16 +144,97 @@ type HTTPPoolOptions struct {
 	// HashFn specifies the hash function of the consistent hash.
 	// If blank, it defaults to crc32.ChecksumIEEE.
 	HashFn consistenthash.Hash
+
+	// PeerCandidates specifies how many distinct peers, walking the
+	// consistent hash from a key, are considered replica candidates
+	// for that key. Among the candidates the peer with the lowest
+	// moving average response latency is picked. If this process is
+	// the key's owner, the key is loaded locally; if it is another
+	// candidate, it is not picked, so that only the owner loads keys.
+	// A candidate that misses a request from a peer sends it on to
+	// the owner, so every request reaches the owner within two hops.
+	// If blank or 1, the key's owner is always picked.
+	PeerCandidates int
+
+	// SelfAliases lists other base URLs under which peers may know
+	// this process, such as further advertised addresses. Peers are
+	// compared with self and its aliases after normalization, and a
+	// peer on this machine with self's scheme and port is this
+	// process too if self names this machine.
+	SelfAliases []string
+
+	// ClientOnly makes the pool a pure client of its peers: this
+	// process never owns any part of the keyspace, never caches
+	// values, and refuses peer requests. Useful for frontends that
+	// want cache access without holding data.
+	ClientOnly bool
+
+	// Faults optionally injects faults into peer requests, for
+	// testing. See FaultOptions.
+	Faults *FaultOptions
+
+	// MaxConnsPerPeer limits the connections, idle or in use, to each
+	// peer. Requests beyond the limit wait for a connection.
+	// If blank, there is no limit.
+	MaxConnsPerPeer int
+
+	// MaxIdleConnsPerPeer is the number of idle connections kept open
+	// to each peer for reuse.
+	// If blank, it defaults to that of http.DefaultTransport.
+	MaxIdleConnsPerPeer int
+
+	// IdleConnTimeout is how long an idle peer connection is kept.
+	// If blank, it defaults to that of http.DefaultTransport.
+	IdleConnTimeout time.Duration
+
+	// The connection options above are ignored if the pool's
+	// Transport is set.
+
+	// ProtocolVersion caps the peer protocol version the pool speaks,
+	// for example to keep to the version of the oldest release in a
+	// cluster being upgraded. If blank, it defaults to the newest,
+	// ProtocolVersion.
+	ProtocolVersion int
+
+	// UpstreamCompat makes the pool interoperate with peers running
+	// golang/groupcache, so that a cluster can migrate to or from this
+	// package one node at a time. The pool then speaks protocol
+	// version 1 only and places keys on the ring as upstream does,
+	// overriding ProtocolVersion, Replicas, HashFn and PeerCandidates.
+	// Groups must leave MaxKeyBytes unset. Upstream peers don't answer
+	// Leave or ClusterStats, which report them as failed, and values
+	// they serve carry no expiration.
+	UpstreamCompat bool
+
+	// Middleware wraps the handler returned by Handler, for example
+	// with authentication or request logging. The first middleware is
+	// the outermost.
+	Middleware []HandlerMiddleware
+
+	// AdminMiddleware likewise wraps the handler returned by
+	// AdminHandler, for example with access control or CORS.
+	AdminMiddleware []HandlerMiddleware
+}
+
+// A HandlerMiddleware wraps an http.Handler with additional behavior.
+type HandlerMiddleware func(http.Handler) http.Handler
+
+// chainHandler wraps h with mws, the first middleware outermost.
+func chainHandler(h http.Handler, mws []HandlerMiddleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
 }
 
 // NewHTTPPool initializes an HTTP pool of peers, and registers itself as a PeerPicker.
-// For convenience, it also registers itself as an http.Handler with http.DefaultServeMux.
+// For convenience, it also registers its Handler with http.DefaultServeMux.
 // The self argument should be a valid base URL that points to the current server,
 // for example "http://example.net:8000".
 func NewHTTPPool(self string) *HTTPPool {
 	p := NewHTTPPoolOpts(self, nil)
 	// p.opts.BasePath为路由路径，p为路由处理结构
-	http.Handle(p.opts.BasePath, p)
+	http.Handle(p.opts.BasePath, p.Handler())
 	return p
 }
 
@@ -92,13 +242,23 @@ var httpPoolMade bool
 
 // NewHTTPPoolOpts initializes an HTTP pool of peers with the given options.
 // Unlike NewHTTPPool, this function does not register the created pool as an HTTP handler.
-// The returned *HTTPPool implements http.Handler and must be registered using http.Handle.
+// The returned *HTTPPool implements http.Handler and must be registered using http.Handle;
+// register its Handler instead to apply the Middleware option.
 func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	if httpPoolMade {
 		panic("groupcache: NewHTTPPool must be called only once")
 	}
 	httpPoolMade = true
 
+	p := newHTTPPool(self, o)
+	// 注册PeerPicker?
+	RegisterPeerPicker(func() PeerPicker { return p })
+	return p
+}
+
+// newHTTPPool creates a pool without registering it as the process's
+// PeerPicker.
+func newHTTPPool(self string, o *HTTPPoolOptions) *HTTPPool {
 	p := &HTTPPool{
 		self:        self,
 		httpGetters: make(map[string]*httpGetter),
@@ -106,6 +266,13 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	if o != nil {
 		p.opts = *o
 	}
+	if p.opts.UpstreamCompat {
+		// 上游groupcache只懂最初的协议，环上的节点位置也要和它一致。
+		p.opts.ProtocolVersion = 1
+		p.opts.Replicas = defaultReplicas
+		p.opts.HashFn = nil
+		p.opts.PeerCandidates = 0
+	}
 	// 默认的路由路径是defaultBasePath
 	if p.opts.BasePath == "" {
 		p.opts.BasePath = defaultBasePath
@@ -115,8 +282,9 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	}
 	// 一致性hash的初始化。
 	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
-	// 注册PeerPicker?
-	RegisterPeerPicker(func() PeerPicker { return p })
+	p.faults = newFaultInjector(p.opts.Faults)
+	p.transport = newPeerTransport(&p.opts)
+	p.me = newSelfMatcher(self, p.opts.SelfAliases)
 	return p
 }
 
@@ -126,15 +294,63 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 func (p *HTTPPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.setLocked(peers)
+}
+
+// setLocked replaces the pool's peers. p.mu must be held.
+func (p *HTTPPool) setLocked(peers []string) {
+	p.peerList = peers
+	// 地址写法不同（主机名/IP、端口、IPv6括号）的自身节点也要识别出来，
+	// 否则会通过网络把请求发给自己。
+	selves := make(map[string]bool)
+	for _, peer := range peers {
+		if p.me.match(peer) {
+			selves[peer] = true
+		}
+	}
+	if p.opts.ClientOnly {
+		// 只作为客户端的节点不参与一致性hash。
+		peers = removeSelves(peers, selves)
+	}
+	p.selves = selves
 	// 实例化一致性hash算法
 	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
 	// 添加节点。
 	p.peers.Add(peers...)
 	// 为每一个节点创建了一个 HTTP 客户端 httpGetter
-	p.httpGetters = make(map[string]*httpGetter, len(peers))
+	// 已有的 httpGetter 会被保留，这样它们的延迟统计不会因为 Set 而丢失。
+	getters := make(map[string]*httpGetter, len(peers))
 	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{transport: p.Transport, baseURL: peer + p.opts.BasePath}
+		if selves[peer] {
+			continue
+		}
+		if g, ok := p.httpGetters[peer]; ok {
+			getters[peer] = g
+			continue
+		}
+		getters[peer] = &httpGetter{
+			transport:   p.peerTransport(),
+			baseURL:     peer + p.opts.BasePath,
+			faults:      p.faults,
+			maxProtocol: p.maxProtocol(),
+		}
 	}
+	p.httpGetters = getters
+}
+
+// removeSelves returns peers without those in selves.
+func removeSelves(peers []string, selves map[string]bool) []string {
+	kept := make([]string, 0, len(peers))
+	for _, p := range peers {
+		if !selves[p] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func (p *HTTPPool) clientOnly() bool {
+	return p.opts.ClientOnly
 }
 
 // 包装了一致性哈希算法的 Get() 方法，根据具体的 key，选择节点，返回节点对应的 HTTP 客户端。
@@ -144,30 +360,132 @@ func (p *HTTPPool) PickPeer(key string) (ProtoGetter, bool) {
 	if p.peers.IsEmpty() {
 		return nil, false
 	}
+	if p.opts.PeerCandidates > 1 {
+		return p.pickFastestLocked(key)
+	}
+	return p.pickOwnerLocked(key)
+}
+
+// pickOwner implements ownerPicker.
+func (p *HTTPPool) pickOwner(key string) (ProtoGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers.IsEmpty() {
+		return nil, false
+	}
+	return p.pickOwnerLocked(key)
+}
+
+// pickOwnerLocked picks the owner of key. p.mu must be held.
+func (p *HTTPPool) pickOwnerLocked(key string) (ProtoGetter, bool) {
 	// 先通过一致性hash找到对应的peer，返回的是对应节点的httpGetter结构。
 	// httpGetter中有Get方法可以构造url查找对应的数据。
-	if peer := p.peers.Get(key); peer != p.self {
+	if peer := p.peers.Get(key); !p.selves[peer] {
 		return p.httpGetters[peer], true
 	}
 	return nil, false
 }
 
+// pickFastestLocked picks, among the key's replica candidates other
+// than this process, the peer with the lowest average latency, unless
+// this process owns the key. Peers that have not answered yet rank
+// first so that they get measured. p.mu must be held.
+func (p *HTTPPool) pickFastestLocked(key string) (ProtoGetter, bool) {
+	candidates := p.peers.GetN(key, p.opts.PeerCandidates)
+	if p.selves[candidates[0]] {
+		return nil, false
+	}
+	var best *httpGetter
+	for _, peer := range candidates {
+		if p.selves[peer] {
+			// 只有owner才从源加载，其他副本不能本地加载。
+			continue
+		}
+		g := p.httpGetters[peer]
+		if best == nil || g.latency.value() < best.latency.value() {
+			best = g
+		}
+	}
+	if rand.Intn(exploreOdds) == 0 {
+		return p.httpGetters[candidates[0]], true
+	}
+	return best, true
+}
+
+// Handler returns the pool's peer handler wrapped with the Middleware
+// option. It serves requests below the BasePath option.
+func (p *HTTPPool) Handler() http.Handler {
+	return chainHandler(p, p.opts.Middleware)
+}
+
+// peerRequestKey is the context key marking requests served for peers.
+type peerRequestKey struct{}
+
+// isPeerRequest reports whether ctx is that of a request served for a
+// peer.
+func isPeerRequest(ctx context.Context) bool {
+	return ctx.Value(peerRequestKey{}) != nil
+}
+
+// requestContext returns the context of a peer request.
+func (p *HTTPPool) requestContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if p.Context != nil {
+		ctx = p.Context(r)
+	}
+	return context.WithValue(ctx, peerRequestKey{}, true)
+}
+
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Parse request.
 	// 先判断前缀，前缀不对，直接返回错误。
 	if !strings.HasPrefix(r.URL.Path, p.opts.BasePath) {
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
 	}
-	// 访问路径格式为 /<basepath>/<groupname>/<key>，
-	// 将url分割，拿到groupName和key
-
-	parts := strings.SplitN(r.URL.Path[len(p.opts.BasePath):], "/", 2)
-	if len(parts) != 2 {
-		http.Error(w, "bad request", http.StatusBadRequest)
+	if p.opts.ClientOnly {
+		http.Error(w, "groupcache: client-only pool does not serve peer requests", http.StatusServiceUnavailable)
 		return
 	}
-	groupName := parts[0]
-	key := parts[1]
+	switch r.URL.Path[len(p.opts.BasePath):] {
+	case statsPath:
+		p.serveStats(w, r)
+		return
+	case leavePath:
+		p.serveLeave(w, r)
+		return
+	case negativePath:
+		p.serveNegative(w, r)
+		return
+	case multiPath:
+		p.serveMulti(w, r)
+		return
+	case prewarmPath:
+		p.servePrewarm(w, r)
+		return
+	}
+	var groupName, key, ifNoneMatch string
+	var refresh bool
+	if r.URL.Path[len(p.opts.BasePath):] == getPath {
+		// 过长的key放在请求体中，而不是URL中。
+		var in pb.GetRequest
+		if err := readProto(w, r, &in); err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		groupName, key, ifNoneMatch = in.GetGroup(), in.GetKey(), in.GetIfNoneMatch()
+		refresh = in.GetRefresh()
+	} else {
+		// 访问路径格式为 /<basepath>/<groupname>/<key>，
+		// 将url分割，拿到groupName和key
+		parts := strings.SplitN(r.URL.Path[len(p.opts.BasePath):], "/", 2)
+		if len(parts) != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		groupName, key = parts[0], parts[1]
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		refresh = r.URL.Query().Get("refresh") == "1"
+	}
 
 	// Fetch the value for this group/key.
 	// 先找到对应的节点；通过 groupname 得到 group 实例，
@@ -176,30 +494,66 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
 		return
 	}
-	var ctx context.Context
-	if p.Context != nil {
-		ctx = p.Context(r)
-	} else {
-		ctx = r.Context()
-	}
+	ctx := p.requestContext(r)
+	version := p.negotiate(w, r)
 
 	group.Stats.ServerRequests.Add(1)
-	var value []byte
+	var value ByteView
 	// 在对应的节点中，再使用 group.Get(key) 获取缓存数据，通过key找到value
-	err := group.Get(ctx, key, AllocatingByteSliceSink(&value))
+	var opts []GetOption
+	if refresh {
+		opts = append(opts, ForceRefresh())
+	}
+	err := group.Get(ctx, key, ByteViewSink(&value), opts...)
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			w.Header().Set(errorHeader, notFoundCode)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	var tag string
+	if version >= 2 {
+		tag = etag(value)
+		w.Header().Set("ETag", tag)
+	}
+	if version >= 2 && ifNoneMatch == tag {
+		// 调用者已经有相同的值，只需要告诉它新的过期时间。
+		if e := value.Expire(); !e.IsZero() {
+			w.Header().Set(expireHeader, fmt.Sprint(e.UnixNano()))
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	// Write the value to the response body as a proto message.
 	// 将查询到的结果通过pb发出去。
-	body, err := proto.Marshal(&pb.GetResponse{Value: value})
+	res := &pb.GetResponse{Value: value.ByteSlice()}
+	if version >= 2 {
+		res.Etag = proto.String(tag)
+		if e := value.Expire(); !e.IsZero() {
+			res.Expire = proto.Int64(e.UnixNano())
+		}
+	}
+	body, err := proto.Marshal(res)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/x-protobuf")
+	if p.faults.abort() {
+		// Promise the whole body, send half of it, then drop the
+		// connection.
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.Write(body[:len(body)/2])
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	}
 	// 使用 w.Write() 将缓存值作为 httpResponse 的 body 返回。
 	w.Write(body)
 }
@@ -208,6 +562,39 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 type httpGetter struct {
 	transport func(context.Context) http.RoundTripper
 	baseURL   string		// baseURL 表示将要访问的远程节点的地址
+	latency   ewma			// 该节点的平均响应延迟
+	faults    *faultInjector
+	metrics   peerMetrics		// 连接复用、拨号失败等统计
+
+	maxProtocol int   // newest protocol version to offer
+	protocol    int32 // version of the peer's last response, atomic
+}
+
+// An ewma is an exponentially weighted moving average of durations,
+// safe for concurrent use. The zero value has no observations.
+type ewma struct {
+	bits uint64 // math.Float64bits of the average in nanoseconds
+}
+
+// ewmaWeight is the weight given to each new observation.
+const ewmaWeight = 0.2
+
+func (e *ewma) observe(d time.Duration) {
+	for {
+		old := atomic.LoadUint64(&e.bits)
+		avg := float64(d)
+		if old != 0 {
+			prev := math.Float64frombits(old)
+			avg = prev + ewmaWeight*(avg-prev)
+		}
+		if atomic.CompareAndSwapUint64(&e.bits, old, math.Float64bits(avg)) {
+			return
+		}
+	}
+}
+
+func (e *ewma) value() time.Duration {
+	return time.Duration(math.Float64frombits(atomic.LoadUint64(&e.bits)))
 }
 
 var bufferPool = sync.Pool{
@@ -216,29 +603,80 @@ var bufferPool = sync.Pool{
 
 // 获取通过对应远程节点查询到的结果。
 func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
-	// 构造URL，将构造好的url写入out
-	u := fmt.Sprintf(
-		"%v%v/%v",
-		h.baseURL,
-		url.QueryEscape(in.GetGroup()),
-		url.QueryEscape(in.GetKey()),
-	)
-	req, err := http.NewRequest("GET", u, nil)
-	if err != nil {
-		return err
+	start := time.Now()
+	err := h.get(ctx, in, out)
+	switch {
+	case err == nil || errors.Is(err, ErrNotFound):
+		h.latency.observe(time.Since(start))
+	case ctx.Err() == nil:
+		// Only charge the peer for failures that weren't caused by
+		// the caller giving up.
+		elapsed := time.Since(start)
+		if elapsed < peerErrorPenalty {
+			elapsed = peerErrorPenalty
+		}
+		h.latency.observe(elapsed)
+	}
+	return err
+}
+
+func (h *httpGetter) get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	var req *http.Request
+	var err error
+	if len(in.GetKey()) > maxURLKey && h.peerProtocol() >= 3 {
+		// 过长的key会超出URL长度限制，放到POST请求体中发送。
+		body, err := proto.Marshal(in)
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequest("POST", h.baseURL+getPath, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	} else {
+		// 构造URL，将构造好的url写入out
+		u := fmt.Sprintf(
+			"%v%v/%v",
+			h.baseURL,
+			url.QueryEscape(in.GetGroup()),
+			url.QueryEscape(in.GetKey()),
+		)
+		if in.GetRefresh() {
+			u += "?refresh=1"
+		}
+		req, err = http.NewRequest("GET", u, nil)
+		if err != nil {
+			return err
+		}
+		if in.IfNoneMatch != nil && h.peerProtocol() >= 2 {
+			req.Header.Set("If-None-Match", in.GetIfNoneMatch())
+		}
 	}
-	req = req.WithContext(ctx)
-	tr := http.DefaultTransport
-	if h.transport != nil {
-		tr = h.transport(ctx)
+	if err := h.faults.beforeRequest(ctx); err != nil {
+		return err
 	}
-	res, err := tr.RoundTrip(req)
+	res, err := h.roundTrip(ctx, req)
 	if err != nil {
-		return err
+		return peerError(ctx, err)
 	}
 	defer res.Body.Close()
+	h.observeProtocol(res)
+	if res.StatusCode == http.StatusNotModified && in.IfNoneMatch != nil {
+		out.Reset()
+		out.NotModified = proto.Bool(true)
+		out.Etag = proto.String(res.Header.Get("ETag"))
+		if s := res.Header.Get(expireHeader); s != "" {
+			e, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad %s header: %v", expireHeader, err)
+			}
+			out.Expire = proto.Int64(e)
+		}
+		return nil
+	}
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned: %v", res.Status)
+		return statusError(res)
 	}
 	b := bufferPool.Get().(*bytes.Buffer)
 	b.Reset()
@@ -247,9 +685,17 @@ func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResp
 	if err != nil {
 		return fmt.Errorf("reading response body: %v", err)
 	}
+	h.faults.corrupt(b.Bytes())
 	err = proto.Unmarshal(b.Bytes(), out)
 	if err != nil {
 		return fmt.Errorf("decoding response body: %v", err)
 	}
 	return nil
 }
+
+// etag returns the entity tag of v, a hash of its bytes.
+func etag(v ByteView) string {
+	h := sha256.New()
+	v.WriteTo(h)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
@@ -20,16 +20,23 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/golang/groupcache/consistenthash"
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
 )
 
 var (
@@ -165,3 +172,601 @@ func awaitAddrReady(t *testing.T, addr string, wg *sync.WaitGroup) {
 		time.Sleep(delay)
 	}
 }
+
+func TestPickPeerPrefersFastest(t *testing.T) {
+	p := newHTTPPool("http://self", &HTTPPoolOptions{PeerCandidates: 3})
+	p.Set("http://a", "http://b", "http://c")
+
+	// Make every peer but one look slow.
+	const fastPeer = "http://b"
+	for peer, g := range p.httpGetters {
+		if peer == fastPeer {
+			g.latency.observe(time.Millisecond)
+		} else {
+			g.latency.observe(time.Second)
+		}
+	}
+
+	const n = 200
+	fast := 0
+	for _, key := range testKeys(n) {
+		peer, ok := p.PickPeer(key)
+		if !ok {
+			t.Fatalf("PickPeer(%q) picked self, which is not in the ring", key)
+		}
+		if peer == p.httpGetters[fastPeer] {
+			fast++
+		}
+	}
+	// Every pick should go to the fast peer, except for the occasional
+	// exploration of a key's owner.
+	if fast < n*8/10 {
+		t.Errorf("fast peer picked %d of %d times; want at least %d", fast, n, n*8/10)
+	}
+}
+
+func TestPickPeerCandidateSelf(t *testing.T) {
+	p := newHTTPPool("http://a", &HTTPPoolOptions{PeerCandidates: 2})
+	p.Set("http://a", "http://b")
+	for _, key := range testKeys(50) {
+		peer, ok := p.PickPeer(key)
+		if owner := p.peers.Get(key); owner == "http://a" && ok {
+			t.Fatalf("PickPeer(%q) = %v; want local load when self owns the key", key, peer)
+		} else if owner == "http://b" && peer != p.httpGetters["http://b"] {
+			t.Fatalf("PickPeer(%q) = %v, %v; want the owner b when self is another candidate", key, peer, ok)
+		}
+	}
+}
+
+func TestReplicaForwardsToOwner(t *testing.T) {
+	// Every node serves its own group; the handlers rename the group of
+	// incoming requests, so that the nodes can share this process.
+	const name = "TestReplicaForwardsToOwner-group"
+	const nodes = 3
+	var (
+		mu    sync.Mutex
+		hops  = make(map[string]int)
+		loads = make(map[string][]int)
+		urls  []string
+		pools []*HTTPPool
+	)
+	for i := 0; i < nodes; i++ {
+		i := i
+		node := fmt.Sprintf("%s-%d", name, i)
+		var p *HTTPPool
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, defaultBasePath), "/", 2)
+			mu.Lock()
+			hops[parts[1]]++
+			mu.Unlock()
+			r.URL.Path = defaultBasePath + node + "/" + parts[1]
+			p.ServeHTTP(w, r)
+		}))
+		defer ts.Close()
+		p = newHTTPPool(ts.URL, &HTTPPoolOptions{PeerCandidates: nodes})
+		newGroup(node, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			mu.Lock()
+			loads[key] = append(loads[key], i)
+			mu.Unlock()
+			return dest.SetString(key)
+		}), p)
+		urls = append(urls, ts.URL)
+		pools = append(pools, p)
+	}
+	for _, p := range pools {
+		p.Set(urls...)
+	}
+	client := newHTTPPool("http://client", &HTTPPoolOptions{PeerCandidates: nodes})
+	client.Set(urls...)
+	// Make the first node look fastest, so that it is asked for the
+	// keys it doesn't own.
+	for peer, h := range client.httpGetters {
+		if peer == urls[0] {
+			h.latency.observe(time.Millisecond)
+		} else {
+			h.latency.observe(time.Second)
+		}
+	}
+	g := NewGroupOpts(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("client getter called")
+	}), &GroupOptions{Peers: client, Unregistered: true})
+
+	forwarded := 0
+	for _, key := range testKeys(50) {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		owner := client.peers.Get(key)
+		if n := hops[key]; n > 2 {
+			t.Errorf("Get(%q) took %d hops; want at most 2", key, n)
+		} else if n == 2 {
+			forwarded++
+		}
+		if l := loads[key]; len(l) != 1 || urls[l[0]] != owner {
+			t.Errorf("Get(%q) loaded on nodes %v; want only the owner %s", key, l, owner)
+		}
+	}
+	if forwarded == 0 {
+		t.Error("no request went through a replica")
+	}
+}
+
+func TestReplicaDoesNotLoad(t *testing.T) {
+	const name = "TestReplicaDoesNotLoad-group"
+	var ownerLoads int32
+	newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		atomic.AddInt32(&ownerLoads, 1)
+		return dest.SetString(key)
+	}), NoPeers{})
+	_, ts := startPool(t, nil)
+	const self = "http://replica"
+	replica := newHTTPPool(self, &HTTPPoolOptions{PeerCandidates: 2})
+	replica.Set(ts.URL, self)
+	var replicaLoads int
+	g := NewGroupOpts(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		replicaLoads++
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: replica, Unregistered: true})
+
+	n := 0
+	for _, key := range testKeys(50) {
+		if replica.peers.Get(key) != ts.URL {
+			continue
+		}
+		n++
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil || s != key {
+			t.Fatalf("Get(%q) = %q, %v; want %q", key, s, err, key)
+		}
+	}
+	if n == 0 {
+		t.Fatal("no test key is owned by the other peer")
+	}
+	if replicaLoads != 0 || int(atomic.LoadInt32(&ownerLoads)) != n {
+		t.Errorf("replica loaded %d keys and owner %d; want 0 and %d", replicaLoads, ownerLoads, n)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	var e ewma
+	if got := e.value(); got != 0 {
+		t.Fatalf("zero ewma = %v; want 0", got)
+	}
+	e.observe(100 * time.Millisecond)
+	if got := e.value(); got != 100*time.Millisecond {
+		t.Fatalf("after first observation = %v; want 100ms", got)
+	}
+	for i := 0; i < 50; i++ {
+		e.observe(10 * time.Millisecond)
+	}
+	if got := e.value(); got > 11*time.Millisecond {
+		t.Errorf("after many fast observations = %v; want close to 10ms", got)
+	}
+}
+
+func TestClientOnlyPool(t *testing.T) {
+	const self = "http://frontend"
+	p := newHTTPPool(self, &HTTPPoolOptions{ClientOnly: true})
+	p.Set(self, "http://a", "http://b")
+
+	if _, ok := p.httpGetters[self]; ok {
+		t.Error("client-only pool created a getter for itself")
+	}
+	for _, key := range testKeys(50) {
+		if _, ok := p.PickPeer(key); !ok {
+			t.Fatalf("PickPeer(%q) chose self in a client-only pool", key)
+		}
+	}
+
+	for _, path := range []string{"group/key", statsPath, leavePath, negativePath, multiPath, prewarmPath} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("POST", defaultBasePath+path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("ServeHTTP(%s) status = %d; want %d", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	g := newGroup("TestClientOnlyPool-group", 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local:" + key)
+	}), p)
+	p.Set(self)
+	var s string
+	if err := g.Get(context.TODO(), "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.mainCache.items() + g.hotCache.items(); n != 0 {
+		t.Errorf("client-only group cached %d items; want 0", n)
+	}
+}
+
+func TestFaultsRequireBuildTag(t *testing.T) {
+	if chaosEnabled {
+		t.Skip("built with groupcache_chaos")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("creating a pool with FaultOptions did not panic")
+		}
+	}()
+	newHTTPPool("http://self", &HTTPPoolOptions{Faults: &FaultOptions{DropRate: 1}})
+}
+
+func TestHotCacheRevalidation(t *testing.T) {
+	const name = "TestHotCacheRevalidation-group"
+	var (
+		mu      sync.Mutex
+		version = "v1"
+	)
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return dest.SetString(key + ":" + version)
+	})
+	const ttl = 30 * time.Millisecond
+	NewGroupOpts(name, 1<<20, getter, &GroupOptions{Peers: NoPeers{}, TTL: ttl})
+	_, ts := startPool(t, nil)
+	client := newHTTPPool("http://client", nil)
+	client.Set(ts.URL)
+	g := NewGroupOpts(name, 1<<20, getter, &GroupOptions{Peers: client, Unregistered: true})
+
+	get := func() string {
+		var s string
+		if err := g.Get(dummyCtx, "k", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	// Peer values reach the hot cache at random.
+	for i := 0; g.hotCache.items() == 0; i++ {
+		if i == 1000 {
+			t.Fatal("value never reached the hot cache")
+		}
+		get()
+	}
+
+	time.Sleep(ttl + 10*time.Millisecond)
+	if s := get(); s != "k:v1" || g.Stats.PeerNotModified.Get() != 1 {
+		t.Errorf("got %q with %d not modified; want k:v1 revalidated", s, g.Stats.PeerNotModified.Get())
+	}
+	if s := get(); s != "k:v1" || g.Stats.PeerNotModified.Get() != 1 {
+		t.Errorf("got %q with %d not modified; want a hot cache hit", s, g.Stats.PeerNotModified.Get())
+	}
+
+	mu.Lock()
+	version = "v2"
+	mu.Unlock()
+	time.Sleep(ttl + 10*time.Millisecond)
+	if s := get(); s != "k:v2" || g.Stats.PeerNotModified.Get() != 1 {
+		t.Errorf("got %q with %d not modified; want k:v2 refetched", s, g.Stats.PeerNotModified.Get())
+	}
+	if g.hotCache.items() != 1 {
+		t.Errorf("hot cache holds %d items; want the new value", g.hotCache.items())
+	}
+}
+
+func TestETag(t *testing.T) {
+	a, b := ByteView{s: "x"}, ByteView{b: []byte("x")}
+	if etag(a) != etag(b) || etag(a) == etag(ByteView{s: "y"}) {
+		t.Errorf("etags %s, %s, %s: want equal for equal values only", etag(a), etag(b), etag(ByteView{s: "y"}))
+	}
+}
+
+func TestHandlerMiddleware(t *testing.T) {
+	var order []string
+	mw := func(name string) HandlerMiddleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				if r.Header.Get("Authorization") != "secret" {
+					http.Error(w, "forbidden", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	p := newHTTPPool("http://self", &HTTPPoolOptions{
+		Middleware:      []HandlerMiddleware{mw("outer"), mw("inner")},
+		AdminMiddleware: []HandlerMiddleware{mw("admin")},
+	})
+
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/_groupcache/_stats", nil))
+	if rec.Code != http.StatusForbidden || strings.Join(order, ",") != "outer" {
+		t.Errorf("unauthorized request: status %d, middlewares %v; want 403 from outer", rec.Code, order)
+	}
+
+	order = nil
+	req := httptest.NewRequest("GET", "/_groupcache/_stats", nil)
+	req.Header.Set("Authorization", "secret")
+	rec = httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("authorized request: status %d, middlewares %v; want 200 through outer,inner", rec.Code, order)
+	}
+
+	order = nil
+	rec = httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	if rec.Code != http.StatusForbidden || strings.Join(order, ",") != "admin" {
+		t.Errorf("admin request: status %d, middlewares %v; want 403 from admin", rec.Code, order)
+	}
+}
+
+func TestNormalizePeerURL(t *testing.T) {
+	tests := map[string]string{
+		"http://Example.NET:8000/":       "http://example.net:8000",
+		"HTTP://example.net:80":          "http://example.net",
+		"https://example.net:443/x/":     "https://example.net/x",
+		"http://[::1]:8000":              "http://[::1]:8000",
+		"http://[0:0:0:0:0:0:0:1]:8000/": "http://[::1]:8000",
+		"http://[::1]":                   "http://[::1]",
+	}
+	for in, want := range tests {
+		if got := normalizePeerURL(in); got != want {
+			t.Errorf("normalizePeerURL(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestSelfRecognition(t *testing.T) {
+	p := newHTTPPool("http://localhost:8000", &HTTPPoolOptions{SelfAliases: []string{"http://cache-1.example:9000"}})
+	peers := []string{
+		"http://127.0.0.1:8000",       // this machine, self's port
+		"http://LOCALHOST:8000/",      // another spelling of self
+		"http://cache-1.example:9000", // alias
+		"http://127.0.0.1:8001",       // another process on this machine
+		"http://10.255.0.1:8000",      // another machine
+	}
+	p.Set(peers...)
+	for i, peer := range peers {
+		if want := i < 3; p.selves[peer] != want {
+			t.Errorf("%s recognized as self = %v; want %v", peer, p.selves[peer], want)
+		}
+	}
+	if len(p.httpGetters) != 2 {
+		t.Errorf("pool has %d peer clients; want 2", len(p.httpGetters))
+	}
+}
+
+func TestLeave(t *testing.T) {
+	a, tsa := startPool(t, nil)
+	b, tsb := startPool(t, nil)
+	peers := []string{tsa.URL, tsb.URL}
+	a.Set(peers...)
+	b.Set(peers...)
+
+	if err := b.Leave(dummyCtx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := a.PickPeer(strconv.Itoa(i)); ok {
+			t.Fatalf("key %d still routed to the departed peer", i)
+		}
+	}
+	// b left already, so a no longer knows it.
+	if err := b.Leave(dummyCtx); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("second Leave = %v; want a 404 from a", err)
+	}
+}
+
+func TestProtocolNegotiation(t *testing.T) {
+	const name = "TestProtocolNegotiation-group"
+	NewGroupOpts(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: NoPeers{}, TTL: time.Hour})
+
+	// An old server answers a new client in version 1.
+	_, old := startPool(t, &HTTPPoolOptions{ProtocolVersion: 1})
+	client := newHTTPPool("http://client", nil)
+	client.Set(old.URL)
+	peer, _ := client.PickPeer("k")
+	var res pb.GetResponse
+	if err := peer.Get(dummyCtx, &pb.GetRequest{Group: proto.String(name), Key: proto.String("k")}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "k" || res.Expire != nil || res.Etag != nil {
+		t.Errorf("version 1 response = %v; want the value only", &res)
+	}
+	h := peer.(*httpGetter)
+	if v := h.peerProtocol(); v != 1 {
+		t.Errorf("negotiated version %d; want 1", v)
+	}
+	if err := h.GetMulti(dummyCtx, &pb.GetMultiRequest{Group: proto.String(name), Key: []string{"k"}}, nil); err != errProtocol {
+		t.Errorf("GetMulti of a version 1 peer = %v; want errProtocol", err)
+	}
+
+	// A new server answers a newer client in its own version.
+	_, cur := startPool(t, nil)
+	req, _ := http.NewRequest("GET", cur.URL+defaultBasePath+name+"/k", nil)
+	req.Header.Set(protocolHeader, "99")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if v := r.Header.Get(protocolHeader); v != strconv.Itoa(ProtocolVersion) {
+		t.Errorf("server answered in version %q; want %d", v, ProtocolVersion)
+	}
+
+	// An error without a version, e.g. from a proxy, keeps the version.
+	p := newHTTPPool("http://proxied", nil)
+	var failing bool
+	proxied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		p.ServeHTTP(w, r)
+	}))
+	defer proxied.Close()
+	client.Set(proxied.URL)
+	peer, _ = client.PickPeer("k")
+	h = peer.(*httpGetter)
+	req2 := &pb.GetRequest{Group: proto.String(name), Key: proto.String("k")}
+	if err := h.Get(dummyCtx, req2, &res); err != nil {
+		t.Fatal(err)
+	}
+	failing = true
+	if err := h.Get(dummyCtx, req2, &res); err == nil {
+		t.Fatal("Get through a failing proxy succeeded")
+	}
+	if v := h.peerProtocol(); v != ProtocolVersion {
+		t.Errorf("version after a bare error = %d; want %d", v, ProtocolVersion)
+	}
+}
+
+func TestLongKeyPeerRequest(t *testing.T) {
+	const name = "TestLongKeyPeerRequest-group"
+	newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(strconv.Itoa(len(key)))
+	}), NoPeers{})
+	var methods []string
+	p := newHTTPPool("http://owner", nil)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		p.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	client := newHTTPPool("http://client", nil)
+	client.Set(ts.URL)
+	peer, _ := client.PickPeer("")
+
+	for _, n := range []int{10, 100000} {
+		var res pb.GetResponse
+		key := strings.Repeat("k", n)
+		if err := peer.Get(dummyCtx, &pb.GetRequest{Group: proto.String(name), Key: &key}, &res); err != nil {
+			t.Fatalf("key of %d bytes: %v", n, err)
+		}
+		if string(res.Value) != strconv.Itoa(n) {
+			t.Errorf("key of %d bytes: got %q", n, res.Value)
+		}
+	}
+	if strings.Join(methods, ",") != "GET,POST" {
+		t.Errorf("requests used %v; want GET then POST", methods)
+	}
+}
+
+func TestPeerErrors(t *testing.T) {
+	const name = "TestPeerErrors-group"
+	newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return fmt.Errorf("no row for %q: %w", key, ErrNotFound)
+	}), NoPeers{})
+	_, ts := startPool(t, nil)
+	client := newHTTPPool("http://client", nil)
+	client.Set(ts.URL)
+	var localLoads int
+	g := NewGroupOpts(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		localLoads++
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: client, Unregistered: true})
+
+	var s string
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v; want ErrNotFound from the owner", err)
+	}
+	if _, err := g.GetMulti(dummyCtx, []string{"k"}); !errors.Is(err.(MultiError)["k"], ErrNotFound) {
+		t.Errorf("GetMulti = %v; want ErrNotFound for k", err)
+	}
+	if localLoads != 0 {
+		t.Errorf("loaded a not found key locally %d times", localLoads)
+	}
+
+	down := newHTTPPool("http://client", nil)
+	down.Set("http://127.0.0.1:1")
+	peer, _ := down.PickPeer("k")
+	err := peer.Get(dummyCtx, &pb.GetRequest{Group: proto.String(name), Key: proto.String("k")}, &pb.GetResponse{})
+	if !errors.Is(err, ErrPeerUnavailable) {
+		t.Errorf("Get from a down peer = %v; want ErrPeerUnavailable", err)
+	}
+}
+
+func TestPeerRefresh(t *testing.T) {
+	const name = "TestPeerRefresh-group"
+	var loads int
+	newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString(strconv.Itoa(loads))
+	}), NoPeers{})
+	_, ts := startPool(t, nil)
+	client := newHTTPPool("http://client", nil)
+	client.Set(ts.URL)
+	peer, _ := client.PickPeer("")
+
+	for i, refresh := range []bool{false, false, true} {
+		var res pb.GetResponse
+		req := &pb.GetRequest{Group: proto.String(name), Key: proto.String("k")}
+		if refresh {
+			req.Refresh = proto.Bool(true)
+		}
+		if err := peer.Get(dummyCtx, req, &res); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"1", "1", "2"}[i]
+		if string(res.Value) != want {
+			t.Errorf("request %d (refresh %v) = %q; want %q", i, refresh, res.Value, want)
+		}
+	}
+}
+
+// upstreamHandler serves peer requests as golang/groupcache does.
+func upstreamHandler(requests *[]*http.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r)
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, defaultBasePath), "/", 2)
+		if len(parts) != 2 || r.Method != "GET" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := proto.Marshal(&pb.GetResponse{Value: []byte("upstream:" + parts[1])})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(body)
+	})
+}
+
+func TestUpstreamCompat(t *testing.T) {
+	var requests []*http.Request
+	ts := httptest.NewServer(upstreamHandler(&requests))
+	defer ts.Close()
+	client := newHTTPPool("http://client", &HTTPPoolOptions{UpstreamCompat: true, Replicas: 3, PeerCandidates: 2})
+	client.Set(ts.URL)
+	g := NewGroupOpts("upstream-compat", 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Errorf("unexpected local load of %q", key)
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: client, Unregistered: true})
+
+	long := strings.Repeat("k", 2*maxURLKey)
+	got, err := g.GetMulti(dummyCtx, []string{"a", "b", long})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["a"].String() != "upstream:a" || got[long].String() != "upstream:"+long {
+		t.Errorf("GetMulti = %v; want the upstream values", got)
+	}
+	for _, r := range requests {
+		if r.Method != "GET" || r.Header.Get(protocolHeader) != "" || strings.HasPrefix(r.URL.Path, defaultBasePath+"_") {
+			t.Errorf("sent %s %s with protocol %q; want plain version 1 GETs", r.Method, r.URL.Path, r.Header.Get(protocolHeader))
+		}
+	}
+
+	// Keys land on the peers upstream would pick.
+	peers := []string{"http://a", "http://b", "http://c", "http://client"}
+	client.Set(peers...)
+	upstream := consistenthash.New(defaultReplicas, nil)
+	upstream.Add(peers...)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		want := upstream.Get(key)
+		peer, ok := client.PickPeer(key)
+		if !ok {
+			if want != "http://client" {
+				t.Errorf("key %q kept local; upstream picks %s", key, want)
+			}
+			continue
+		}
+		if got := peer.(*httpGetter).baseURL; got != want+defaultBasePath {
+			t.Errorf("key %q sent to %s; upstream picks %s", key, got, want)
+		}
+	}
+}
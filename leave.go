@@ -0,0 +1,110 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// leavePath is the path, below the pool's BasePath, where peers
+// announce that they are shutting down.
+const leavePath = "_leave"
+
+// Leave tells the pool's peers that this process is shutting down, so
+// that they drop it from their rings right away instead of failing
+// requests to it until their next Set. Call it before stopping to
+// serve peer requests. Peers that could not be told are listed in the
+// returned error.
+func (p *HTTPPool) Leave(ctx context.Context) error {
+	p.mu.Lock()
+	getters := make(map[string]*httpGetter, len(p.httpGetters))
+	for peer, g := range p.httpGetters {
+		getters[peer] = g
+	}
+	p.mu.Unlock()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed []string
+	)
+	for peer, g := range getters {
+		wg.Add(1)
+		go func(peer string, g *httpGetter) {
+			defer wg.Done()
+			if err := g.leave(ctx, p.self); err != nil {
+				mu.Lock()
+				failed = append(failed, peer+": "+err.Error())
+				mu.Unlock()
+			}
+		}(peer, g)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("groupcache: %d of %d peers not told of departure: %s", len(failed), len(getters), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// leave announces to the peer that self is leaving.
+func (h *httpGetter) leave(ctx context.Context, self string) error {
+	body := url.Values{"peer": {self}}.Encode()
+	req, err := http.NewRequest("POST", h.baseURL+leavePath, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+// serveLeave drops a departing peer from the pool's ring.
+func (p *HTTPPool) serveLeave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	leaving := normalizePeerURL(r.FormValue("peer"))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := make([]string, 0, len(p.peerList))
+	for _, peer := range p.peerList {
+		// 不能把自己从环中删除。
+		if normalizePeerURL(peer) != leaving || p.selves[peer] {
+			kept = append(kept, peer)
+		}
+	}
+	if len(kept) == len(p.peerList) {
+		http.Error(w, "not a peer: "+r.FormValue("peer"), http.StatusNotFound)
+		return
+	}
+	p.setLocked(kept)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// ARC is an adaptive replacement cache. It keeps entries hit once in a
+// recency list and entries hit again in a frequency list, and
+// remembers the keys recently evicted from each. A miss on a
+// remembered key grows the share of the list it was evicted from, so
+// the cache adapts between recency and frequency to the workload. Like
+// Cache it is not safe for concurrent access, and its zero value is
+// ready to use.
+type ARC struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit, in which case the
+	// cache's current length is used as its capacity when adapting.
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	// lists are T1, T2, B1 and B2 of the ARC paper: the entries hit
+	// once and hit again, and the keys evicted from each. Each runs
+	// from the most to the least recently used.
+	lists [4]*list.List
+	p     int // target length of T1
+	cache map[interface{}]*list.Element
+}
+
+var _ Interface = (*ARC)(nil)
+
+// The lists of an ARC.
+const (
+	arcT1 = iota // 只命中过一次的缓存
+	arcT2        // 命中过多次的缓存
+	arcB1        // 从T1淘汰的key
+	arcB2        // 从T2淘汰的key
+)
+
+type arcEntry struct {
+	key   Key
+	value interface{} // nil in B1 and B2
+	list  int
+}
+
+func (c *ARC) init() {
+	if c.cache == nil {
+		c.cache = make(map[interface{}]*list.Element)
+		for i := range c.lists {
+			c.lists[i] = list.New()
+		}
+		c.p = 0
+	}
+}
+
+// capacity is the c of the ARC paper.
+func (c *ARC) capacity() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	if n := c.Len(); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// move moves the entry to the front of list l.
+func (c *ARC) move(ele *list.Element, l int) {
+	e := ele.Value.(*arcEntry)
+	c.lists[e.list].Remove(ele)
+	e.list = l
+	c.cache[e.key] = c.lists[l].PushFront(e)
+}
+
+// Add adds a value to the cache.
+func (c *ARC) Add(key Key, value interface{}) {
+	c.init()
+	ele, ok := c.cache[key]
+	if !ok {
+		if c.MaxEntries != 0 && c.Len() >= c.MaxEntries {
+			c.replace(false)
+		}
+		c.cache[key] = c.lists[arcT1].PushFront(&arcEntry{key: key, value: value, list: arcT1})
+		c.trimGhosts()
+		return
+	}
+	e := ele.Value.(*arcEntry)
+	switch e.list {
+	case arcB1:
+		// 最近从T1淘汰的key又被访问，说明T1应该更大。
+		c.p = min(c.capacity(), c.p+max(c.lists[arcB2].Len()/c.lists[arcB1].Len(), 1))
+		if c.MaxEntries != 0 && c.Len() >= c.MaxEntries {
+			c.replace(false)
+		}
+	case arcB2:
+		c.p = max(0, c.p-max(c.lists[arcB1].Len()/c.lists[arcB2].Len(), 1))
+		if c.MaxEntries != 0 && c.Len() >= c.MaxEntries {
+			c.replace(true)
+		}
+	}
+	e.value = value
+	c.move(ele, arcT2)
+	c.trimGhosts()
+}
+
+// Get looks up a key's value from the cache.
+func (c *ARC) Get(key Key) (value interface{}, ok bool) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	e := ele.Value.(*arcEntry)
+	if e.list != arcT1 && e.list != arcT2 {
+		return nil, false
+	}
+	c.move(ele, arcT2)
+	return e.value, true
+}
+
+// victim returns the entry replace evicts.
+func (c *ARC) victim(inB2 bool) *list.Element {
+	t1, t2 := c.lists[arcT1], c.lists[arcT2]
+	if t1.Len() > 0 && (t1.Len() > c.p || (inB2 && t1.Len() == c.p) || t2.Len() == 0) {
+		return t1.Back()
+	}
+	return t2.Back()
+}
+
+// replace evicts an entry from T1 or T2, remembering its key.
+func (c *ARC) replace(inB2 bool) {
+	ele := c.victim(inB2)
+	if ele == nil {
+		return
+	}
+	e := ele.Value.(*arcEntry)
+	value := e.value
+	e.value = nil
+	if e.list == arcT1 {
+		c.move(ele, arcB1)
+	} else {
+		c.move(ele, arcB2)
+	}
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, value)
+	}
+}
+
+// trimGhosts forgets the oldest evicted keys beyond the capacity.
+func (c *ARC) trimGhosts() {
+	n := c.capacity()
+	for _, l := range []int{arcB1, arcB2} {
+		for c.lists[l].Len() > n {
+			ele := c.lists[l].Back()
+			c.lists[l].Remove(ele)
+			delete(c.cache, ele.Value.(*arcEntry).key)
+		}
+	}
+}
+
+// Remove removes the provided key from the cache.
+func (c *ARC) Remove(key Key) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	e := ele.Value.(*arcEntry)
+	c.lists[e.list].Remove(ele)
+	delete(c.cache, key)
+	if c.OnEvicted != nil && (e.list == arcT1 || e.list == arcT2) {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// RemoveOldest removes the item the cache's current balance of recency
+// and frequency considers least valuable.
+func (c *ARC) RemoveOldest() {
+	if c.Len() > 0 {
+		c.replace(false)
+		c.trimGhosts()
+	}
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it.
+func (c *ARC) PeekOldest() (key Key, value interface{}, ok bool) {
+	if c.Len() == 0 {
+		return
+	}
+	e := c.victim(false).Value.(*arcEntry)
+	return e.key, e.value, true
+}
+
+// Len returns the number of items in the cache.
+func (c *ARC) Len() int {
+	if c.cache == nil {
+		return 0
+	}
+	return c.lists[arcT1].Len() + c.lists[arcT2].Len()
+}
+
+// Clear purges all stored items from the cache, and forgets the
+// evicted keys.
+func (c *ARC) Clear() {
+	if c.OnEvicted != nil && c.cache != nil {
+		for _, l := range []int{arcT1, arcT2} {
+			for ele := c.lists[l].Front(); ele != nil; ele = ele.Next() {
+				e := ele.Value.(*arcEntry)
+				c.OnEvicted(e.key, e.value)
+			}
+		}
+	}
+	c.cache = nil
+}
+
+// Range calls f for each item in the cache, first those hit more than
+// once, each list from the most to the least recently used, until f
+// returns false. It does not change the recency of the items; f must
+// not modify the cache.
+func (c *ARC) Range(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for _, l := range []int{arcT2, arcT1} {
+		for ele := c.lists[l].Front(); ele != nil; ele = ele.Next() {
+			e := ele.Value.(*arcEntry)
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
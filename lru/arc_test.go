@@ -0,0 +1,89 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestARC(t *testing.T) {
+	var evicted []Key
+	c := &ARC{MaxEntries: 2, OnEvicted: func(key Key, _ interface{}) { evicted = append(evicted, key) }}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	// b is the only entry hit once.
+	c.Add("c", 3)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	// b was evicted recently: adding it again puts it with the entries
+	// hit more than once, and makes the cache favor recency, so a is
+	// evicted rather than c.
+	c.Add("b", 4)
+	if got, want := fmt.Sprint(evicted), "[b a]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+	if c.p != 1 {
+		t.Errorf("p = %d; want 1", c.p)
+	}
+	if k, _, ok := c.PeekOldest(); k != "b" || !ok {
+		t.Errorf("PeekOldest = %v, %v; want b, true", k, ok)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get(a) hit an evicted key")
+	}
+
+	var keys []Key
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := fmt.Sprint(keys), "[b c]"; got != want {
+		t.Errorf("Range visited %s; want %s", got, want)
+	}
+	c.Remove("c")
+	c.RemoveOldest()
+	if c.Len() != 0 {
+		t.Errorf("Len = %d; want 0", c.Len())
+	}
+	c.Add("d", 5)
+	c.Clear()
+	if c.Len() != 0 {
+		t.Errorf("Len after Clear = %d; want 0", c.Len())
+	}
+}
+
+func TestARCScanResistance(t *testing.T) {
+	c := &ARC{MaxEntries: 10}
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprint("hot", i), i)
+		c.Get(fmt.Sprint("hot", i))
+	}
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprint("scan", i), i)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(fmt.Sprint("hot", i)); !ok {
+			t.Errorf("hot%d was evicted by a scan", i)
+		}
+	}
+	if c.Len() != 10 {
+		t.Errorf("Len = %d; want 10", c.Len())
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"container/list"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// stripeSize is the number of accesses a stripe of a Buffered cache
+// records before they are applied.
+const stripeSize = 16
+
+// Buffered is a Cache safe for concurrent access whose Gets only take
+// a read lock: instead of moving the entry to the front of the list,
+// a Get records the access in one of several buffers, with atomic
+// operations. The recorded accesses are applied, under the write lock,
+// when a buffer fills or before the next write. Accesses recorded
+// while a buffer is full are dropped, so the eviction order
+// approximates LRU under heavy load.
+//
+// Gets of a Buffered cache don't update the EntryInfo of their entry
+// until the accesses are applied, don't remove expired entries, and
+// don't consult the Overflow store.
+type Buffered struct {
+	mu      sync.RWMutex
+	c       *Cache
+	stripes []accessStripe
+}
+
+var _ Interface = (*Buffered)(nil)
+
+// accessStripe records accesses to the elements of a cache.
+type accessStripe struct {
+	n     atomic.Int32
+	slots [stripeSize]atomic.Pointer[list.Element]
+	_     [64]byte // 避免相邻的stripe共享缓存行
+}
+
+// NewBuffered returns a Buffered cache wrapping c, which must not be
+// used directly afterwards. If c is nil, the cache has no limit. c's
+// callbacks are called with the write lock held, and must not call the
+// Buffered cache.
+func NewBuffered(c *Cache) *Buffered {
+	if c == nil {
+		c = New(0)
+	}
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n *= 2
+	}
+	return &Buffered{c: c, stripes: make([]accessStripe, n)}
+}
+
+// Get looks up a key's value from the cache.
+func (b *Buffered) Get(key Key) (value interface{}, ok bool) {
+	b.mu.RLock()
+	ele, hit := b.c.cache[b.c.canon(key)]
+	if hit {
+		e := ele.Value.(*entry)
+		if hit = !e.expired(now()); hit {
+			var err error
+			value, err = b.c.unpack(e.value)
+			hit = err == nil
+		}
+	}
+	full := false
+	if hit {
+		b.c.hits.Add(1)
+		s := &b.stripes[rand.Uint32()&uint32(len(b.stripes)-1)]
+		if i := s.n.Add(1) - 1; i < stripeSize {
+			s.slots[i].Store(ele)
+			full = i == stripeSize-1
+		}
+	} else {
+		b.c.misses.Add(1)
+	}
+	b.mu.RUnlock()
+	if full {
+		// 缓冲区满了，把记录的访问应用到链表上。
+		b.mu.Lock()
+		b.drain()
+		b.mu.Unlock()
+	}
+	return value, hit
+}
+
+// drain applies the recorded accesses. b.mu must be held for writing.
+func (b *Buffered) drain() {
+	t := now()
+	for i := range b.stripes {
+		s := &b.stripes[i]
+		n := int(s.n.Load())
+		if n > stripeSize {
+			n = stripeSize
+		}
+		for j := 0; j < n; j++ {
+			ele := s.slots[j].Swap(nil)
+			if ele == nil {
+				continue
+			}
+			// 记录之后缓存可能已经被删除了。
+			e := ele.Value.(*entry)
+			if b.c.cache[e.key] != ele {
+				continue
+			}
+			b.c.promoteHit(ele, t)
+			e.accessed = t
+			e.hits++
+		}
+		s.n.Store(0)
+	}
+}
+
+// Flush applies the accesses recorded by Gets to the eviction order.
+func (b *Buffered) Flush() {
+	b.mu.Lock()
+	b.drain()
+	b.mu.Unlock()
+}
+
+// lock takes the write lock, applying the recorded accesses.
+func (b *Buffered) lock() {
+	b.mu.Lock()
+	b.drain()
+}
+
+// Add adds a value to the cache.
+func (b *Buffered) Add(key Key, value interface{}) {
+	b.lock()
+	defer b.mu.Unlock()
+	b.c.Add(key, value)
+}
+
+// Remove removes the provided key from the cache.
+func (b *Buffered) Remove(key Key) {
+	b.lock()
+	defer b.mu.Unlock()
+	b.c.Remove(key)
+}
+
+// RemoveOldest removes the oldest item from the cache.
+func (b *Buffered) RemoveOldest() {
+	b.lock()
+	defer b.mu.Unlock()
+	b.c.RemoveOldest()
+}
+
+// Len returns the number of items in the cache.
+func (b *Buffered) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.c.Len()
+}
+
+// Clear purges all stored items from the cache.
+func (b *Buffered) Clear() {
+	b.lock()
+	defer b.mu.Unlock()
+	b.c.Clear()
+}
+
+// Range calls f for each unexpired item in the cache, from the most to
+// the least recently used, until f returns false. It applies the
+// recorded accesses first, and holds the write lock while it runs, so f
+// must not call the cache.
+func (b *Buffered) Range(f func(key Key, value interface{}) bool) {
+	b.lock()
+	defer b.mu.Unlock()
+	b.c.Range(f)
+}
+
+// Stats returns the counters of the cache's operations, without taking
+// the lock.
+func (b *Buffered) Stats() Stats {
+	return b.c.Stats()
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestBuffered(t *testing.T) {
+	c := NewBuffered(New(3))
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	// The access to a is applied before the next write, so b is the
+	// oldest.
+	c.Add("d", 4)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b not evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a evicted")
+	}
+	c.Flush()
+	var keys []Key
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got := fmt.Sprint(keys); got != "[a d c]" {
+		t.Errorf("Range visited %s; want [a d c]", got)
+	}
+	if st := c.Stats(); st.Hits != 2 || st.Misses != 1 {
+		t.Errorf("Stats = %+v; want 2 hits, 1 miss", st)
+	}
+	c.Remove("a")
+	c.RemoveOldest()
+	if c.Len() != 1 {
+		t.Errorf("Len = %d; want 1", c.Len())
+	}
+}
+
+func TestBufferedConcurrent(t *testing.T) {
+	c := NewBuffered(New(50))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				key := (i*j + j) % 80
+				if _, ok := c.Get(key); !ok {
+					c.Add(key, j)
+				}
+				if j%500 == 0 {
+					c.Remove(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 50 {
+		t.Errorf("Len = %d; want at most 50", n)
+	}
+}
+
+func BenchmarkBufferedGet(b *testing.B) {
+	c := NewBuffered(New(1000))
+	for i := 0; i < 1000; i++ {
+		c.Add(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(i % 1000)
+			i++
+		}
+	})
+}
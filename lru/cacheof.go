@@ -0,0 +1,157 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+// CacheOf is an LRU cache with keys of type K and values of type V.
+// It behaves like Cache, but is checked at compile time and stores its
+// values without boxing them in interfaces. It is not safe for
+// concurrent access, and its zero value is ready to use.
+type CacheOf[K comparable, V any] struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key K, value V)
+
+	// root is the sentinel of a circular list of the entries, from the
+	// most recently used after root to the least before it.
+	root  nodeOf[K, V]
+	cache map[K]*nodeOf[K, V]
+}
+
+type nodeOf[K comparable, V any] struct {
+	prev, next *nodeOf[K, V]
+	key        K
+	value      V
+}
+
+// NewOf creates a new CacheOf.
+// If maxEntries is zero, the cache has no limit and it's assumed
+// that eviction is done by the caller.
+func NewOf[K comparable, V any](maxEntries int) *CacheOf[K, V] {
+	return &CacheOf[K, V]{MaxEntries: maxEntries}
+}
+
+func (c *CacheOf[K, V]) init() {
+	if c.cache == nil {
+		c.cache = make(map[K]*nodeOf[K, V])
+		c.root.prev, c.root.next = &c.root, &c.root
+	}
+}
+
+// unlink removes n from the list.
+func (c *CacheOf[K, V]) unlink(n *nodeOf[K, V]) {
+	n.prev.next, n.next.prev = n.next, n.prev
+}
+
+// pushFront inserts n after root.
+func (c *CacheOf[K, V]) pushFront(n *nodeOf[K, V]) {
+	n.prev, n.next = &c.root, c.root.next
+	c.root.next.prev = n
+	c.root.next = n
+}
+
+// Add adds a value to the cache.
+func (c *CacheOf[K, V]) Add(key K, value V) {
+	c.init()
+	if n, ok := c.cache[key]; ok {
+		c.unlink(n)
+		c.pushFront(n)
+		n.value = value
+		return
+	}
+	n := &nodeOf[K, V]{key: key, value: value}
+	c.pushFront(n)
+	c.cache[key] = n
+	if c.MaxEntries != 0 && len(c.cache) > c.MaxEntries {
+		c.RemoveOldest()
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *CacheOf[K, V]) Get(key K) (value V, ok bool) {
+	n, hit := c.cache[key]
+	if !hit {
+		return value, false
+	}
+	c.unlink(n)
+	c.pushFront(n)
+	return n.value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *CacheOf[K, V]) Remove(key K) {
+	if n, hit := c.cache[key]; hit {
+		c.remove(n)
+	}
+}
+
+// RemoveOldest removes the oldest item from the cache.
+func (c *CacheOf[K, V]) RemoveOldest() {
+	if len(c.cache) > 0 {
+		c.remove(c.root.prev)
+	}
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or changing its recency.
+func (c *CacheOf[K, V]) PeekOldest() (key K, value V, ok bool) {
+	if len(c.cache) == 0 {
+		return key, value, false
+	}
+	n := c.root.prev
+	return n.key, n.value, true
+}
+
+func (c *CacheOf[K, V]) remove(n *nodeOf[K, V]) {
+	c.unlink(n)
+	delete(c.cache, n.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(n.key, n.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *CacheOf[K, V]) Len() int {
+	return len(c.cache)
+}
+
+// Clear purges all stored items from the cache.
+func (c *CacheOf[K, V]) Clear() {
+	if c.OnEvicted != nil {
+		for _, n := range c.cache {
+			c.OnEvicted(n.key, n.value)
+		}
+	}
+	c.cache = nil
+}
+
+// Range calls f for each item in the cache, from the most to the
+// least recently used, until f returns false. It does not change the
+// recency of the items; f must not modify the cache.
+func (c *CacheOf[K, V]) Range(f func(key K, value V) bool) {
+	if c.cache == nil {
+		return
+	}
+	for n := c.root.next; n != &c.root; n = n.next {
+		if !f(n.key, n.value) {
+			return
+		}
+	}
+}
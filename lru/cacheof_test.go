@@ -0,0 +1,77 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCacheOf(t *testing.T) {
+	var evicted []string
+	c := NewOf[string, []byte](3)
+	c.OnEvicted = func(key string, value []byte) {
+		evicted = append(evicted, key)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		c.Add(k, []byte(k))
+	}
+	c.Get("a")
+	c.Add("d", []byte("d"))
+	c.Add("c", []byte("C"))
+	c.Add("e", []byte("e"))
+	if got, want := fmt.Sprint(evicted), "[b a]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+	if v, ok := c.Get("c"); !ok || string(v) != "C" {
+		t.Errorf("Get(c) = %q, %v; want C, true", v, ok)
+	}
+	if k, _, ok := c.PeekOldest(); !ok || k != "d" {
+		t.Errorf("PeekOldest = %q, %v; want d, true", k, ok)
+	}
+
+	var keys []string
+	c.Range(func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := fmt.Sprint(keys), "[c e d]"; got != want {
+		t.Errorf("Range visited %s; want %s", got, want)
+	}
+
+	c.Remove("e")
+	if _, ok := c.Get("e"); ok {
+		t.Error("Get(e) hit after Remove")
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Errorf("Len after Clear = %d; want 0", c.Len())
+	}
+	c.Add("f", nil)
+	if c.Len() != 1 {
+		t.Errorf("Len after Clear and Add = %d; want 1", c.Len())
+	}
+}
+
+func BenchmarkCacheOf(b *testing.B) {
+	c := NewOf[int, int](1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Add(i%2000, i)
+		c.Get(i % 1500)
+	}
+}
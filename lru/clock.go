@@ -0,0 +1,180 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+// Clock is a cache evicting with the clock, or second chance,
+// approximation of LRU. Entries sit in the slots of a circular buffer;
+// Get only sets the entry's reference bit, instead of moving it in a
+// list, which makes hits cheaper than in Cache. Eviction sweeps a hand
+// over the slots, clearing set bits and evicting the first entry whose
+// bit is clear. Like Cache it is not safe for concurrent access, and
+// its zero value is ready to use.
+type Clock struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	slots []clockSlot
+	free  []int // indexes of unused slots
+	hand  int
+	cache map[interface{}]int // key to slot index
+}
+
+var _ Interface = (*Clock)(nil)
+
+type clockSlot struct {
+	key        Key
+	value      interface{}
+	used       bool
+	referenced bool // 最近被访问过
+}
+
+// Add adds a value to the cache.
+func (c *Clock) Add(key Key, value interface{}) {
+	if c.cache == nil {
+		c.cache = make(map[interface{}]int)
+	}
+	if i, ok := c.cache[key]; ok {
+		c.slots[i].value = value
+		c.slots[i].referenced = true
+		return
+	}
+	if c.MaxEntries != 0 && len(c.cache) >= c.MaxEntries {
+		c.RemoveOldest()
+	}
+	var i int
+	if n := len(c.free); n > 0 {
+		i = c.free[n-1]
+		c.free = c.free[:n-1]
+	} else {
+		i = len(c.slots)
+		c.slots = append(c.slots, clockSlot{})
+	}
+	c.slots[i] = clockSlot{key: key, value: value, used: true}
+	c.cache[key] = i
+}
+
+// Get looks up a key's value from the cache.
+func (c *Clock) Get(key Key) (value interface{}, ok bool) {
+	i, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	c.slots[i].referenced = true
+	return c.slots[i].value, true
+}
+
+// victim returns the slot the hand would evict from, without clearing
+// reference bits: the first unreferenced entry from the hand, or, if
+// all are referenced, the first entry.
+func (c *Clock) victim() int {
+	first := -1
+	for n, i := 0, c.hand; n < len(c.slots); n, i = n+1, (i+1)%len(c.slots) {
+		s := &c.slots[i]
+		if !s.used {
+			continue
+		}
+		if !s.referenced {
+			return i
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	return first
+}
+
+// RemoveOldest sweeps the hand to the first entry not referenced since
+// the hand last passed it, clearing the bits it passes, and removes
+// that entry.
+func (c *Clock) RemoveOldest() {
+	if len(c.cache) == 0 {
+		return
+	}
+	i := c.victim()
+	// 指针经过的缓存失去第二次机会。
+	for j := c.hand; j != i; j = (j + 1) % len(c.slots) {
+		c.slots[j].referenced = false
+	}
+	if c.slots[i].referenced {
+		// 所有缓存都被访问过，指针转了一圈。
+		for j := range c.slots {
+			c.slots[j].referenced = false
+		}
+	}
+	c.hand = (i + 1) % len(c.slots)
+	c.removeSlot(i)
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or clearing reference bits.
+func (c *Clock) PeekOldest() (key Key, value interface{}, ok bool) {
+	if len(c.cache) == 0 {
+		return
+	}
+	s := &c.slots[c.victim()]
+	return s.key, s.value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *Clock) Remove(key Key) {
+	if i, hit := c.cache[key]; hit {
+		c.removeSlot(i)
+	}
+}
+
+func (c *Clock) removeSlot(i int) {
+	s := c.slots[i]
+	c.slots[i] = clockSlot{}
+	c.free = append(c.free, i)
+	delete(c.cache, s.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(s.key, s.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *Clock) Len() int {
+	return len(c.cache)
+}
+
+// Clear purges all stored items from the cache.
+func (c *Clock) Clear() {
+	if c.OnEvicted != nil {
+		for _, s := range c.slots {
+			if s.used {
+				c.OnEvicted(s.key, s.value)
+			}
+		}
+	}
+	*c = Clock{MaxEntries: c.MaxEntries, OnEvicted: c.OnEvicted}
+}
+
+// Range calls f for each item in the cache, in the order the hand will
+// reach them, until f returns false. It does not set reference bits;
+// f must not modify the cache.
+func (c *Clock) Range(f func(key Key, value interface{}) bool) {
+	for n, i := 0, c.hand; n < len(c.slots); n, i = n+1, (i+1)%len(c.slots) {
+		if s := &c.slots[i]; s.used && !f(s.key, s.value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClock(t *testing.T) {
+	var evicted []Key
+	c := &Clock{MaxEntries: 3, OnEvicted: func(key Key, _ interface{}) { evicted = append(evicted, key) }}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Get("a")
+	// a gets a second chance; b is evicted and d takes its slot.
+	c.Add("d", 4)
+	if k, _, ok := c.PeekOldest(); k != "c" || !ok {
+		t.Errorf("PeekOldest = %v, %v; want c, true", k, ok)
+	}
+	c.Add("e", 5)
+	if got, want := fmt.Sprint(evicted), "[b c]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+
+	// When every entry was referenced, the hand goes round once.
+	c.Get("a")
+	c.Get("d")
+	c.Get("e")
+	c.Add("f", 6)
+	if got, want := fmt.Sprint(evicted), "[b c a]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+
+	var keys []Key
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := fmt.Sprint(keys), "[d e f]"; got != want {
+		t.Errorf("Range visited %s; want %s", got, want)
+	}
+	if v, ok := c.Get("f"); !ok || v != 6 {
+		t.Errorf("Get(f) = %v, %v; want 6, true", v, ok)
+	}
+	c.Remove("d")
+	if c.Len() != 2 {
+		t.Errorf("Len = %d; want 2", c.Len())
+	}
+	c.Clear()
+	if c.Len() != 0 || len(evicted) != 6 {
+		t.Errorf("Len, evictions = %d, %d; want 0, 6", c.Len(), len(evicted))
+	}
+}
+
+func BenchmarkClockGet(b *testing.B) {
+	c := &Clock{MaxEntries: 1000}
+	for i := 0; i < 1000; i++ {
+		c.Add(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(i % 1000)
+	}
+}
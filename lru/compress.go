@@ -0,0 +1,97 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// A Compressor compresses the large []byte values of a Cache. See
+// Cache.Compressor.
+type Compressor interface {
+	Compress(b []byte) []byte
+	Decompress(b []byte) ([]byte, error)
+}
+
+// GzipCompressor is a Compressor using gzip at Level, the default
+// compression level if zero.
+type GzipCompressor struct {
+	Level int
+}
+
+// Compress returns b compressed, or b itself if it can't be.
+func (g GzipCompressor) Compress(b []byte) []byte {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return b
+	}
+	w.Write(b)
+	if w.Close() != nil {
+		return b
+	}
+	return buf.Bytes()
+}
+
+// Decompress returns the data Compress compressed into b.
+func (g GzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// compressed is a value stored compressed by the Cache's Compressor.
+type compressed []byte
+
+// pack returns the form in which value is stored: compressed if it is
+// a []byte longer than CompressAbove that compresses.
+func (c *Cache) pack(value interface{}) interface{} {
+	b, ok := value.([]byte)
+	if c.Compressor == nil || !ok || len(b) <= c.CompressAbove {
+		return value
+	}
+	if z := c.Compressor.Compress(b); len(z) < len(b) {
+		return compressed(z)
+	}
+	return value
+}
+
+// unpack returns the value stored as v.
+func (c *Cache) unpack(v interface{}) (interface{}, error) {
+	if z, ok := v.(compressed); ok {
+		return c.Compressor.Decompress(z)
+	}
+	return v, nil
+}
+
+// unpacked is unpack for the callbacks, which get nil for values that
+// don't decompress.
+func (c *Cache) unpacked(v interface{}) interface{} {
+	v, err := c.unpack(v)
+	if err != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressor(t *testing.T) {
+	var evicted []byte
+	lru := &Cache{
+		MaxBytes:      100,
+		SizeFunc:      func(_ Key, v interface{}) int64 { return int64(len(v.([]byte))) },
+		Compressor:    GzipCompressor{},
+		CompressAbove: 16,
+		OnEvicted:     func(_ Key, v interface{}) { evicted = v.([]byte) },
+	}
+	big := bytes.Repeat([]byte("groupcache"), 100)
+	lru.Add("big", big)
+	lru.Add("small", []byte("x"))
+	if lru.Len() != 2 || lru.Bytes() >= 100 {
+		t.Fatalf("Len = %d, Bytes = %d; want 2 entries fitting in 100 bytes", lru.Len(), lru.Bytes())
+	}
+	if v, ok := lru.Get("big"); !ok || !bytes.Equal(v.([]byte), big) {
+		t.Errorf("Get(big) did not return the value added")
+	}
+	if v, ok := lru.Peek("small"); !ok || string(v.([]byte)) != "x" {
+		t.Errorf("Peek(small) = %q, %v; want x, true", v, ok)
+	}
+	lru.Remove("big")
+	if !bytes.Equal(evicted, big) {
+		t.Errorf("OnEvicted got %d bytes; want the value added", len(evicted))
+	}
+}
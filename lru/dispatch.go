@@ -0,0 +1,143 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// An Eviction is an entry that left a cache.
+type Eviction struct {
+	Key    Key
+	Value  interface{}
+	Reason EvictReason
+}
+
+// Backpressure tells a Dispatcher what to do with evictions when its
+// queue is full.
+type Backpressure int
+
+const (
+	// Block waits for room in the queue, stalling the cache until the
+	// callback catches up.
+	Block Backpressure = iota
+	// DropNewest discards the eviction being queued.
+	DropNewest
+	// DropOldest discards the oldest queued eviction to make room.
+	DropOldest
+)
+
+// DispatcherOptions are the options of a Dispatcher.
+type DispatcherOptions struct {
+	// Buffer is the number of evictions queued before Backpressure
+	// applies. If zero, it defaults to 1024.
+	Buffer int
+
+	// Backpressure is what to do when the queue is full. The default
+	// is Block.
+	Backpressure Backpressure
+}
+
+// A Dispatcher calls an eviction callback from its own goroutine, so
+// that a slow callback doesn't stall the cache and the lock held
+// around it. Set a Cache's OnEvictedReason to its Notify method.
+type Dispatcher struct {
+	f       func(Eviction)
+	o       DispatcherOptions
+	ch      chan Eviction
+	mu      sync.RWMutex // held by Notify against Close
+	closed  bool
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// NewDispatcher returns a Dispatcher calling f, in order, with the
+// evictions passed to Notify. Close it to stop its goroutine.
+func NewDispatcher(f func(Eviction), o *DispatcherOptions) *Dispatcher {
+	d := &Dispatcher{f: f, done: make(chan struct{})}
+	if o != nil {
+		d.o = *o
+	}
+	if d.o.Buffer <= 0 {
+		d.o.Buffer = 1024
+	}
+	d.ch = make(chan Eviction, d.o.Buffer)
+	go d.run()
+	return d
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for e := range d.ch {
+		d.f(e)
+	}
+}
+
+// Notify queues an eviction for the callback. Evictions after Close
+// are dropped.
+func (d *Dispatcher) Notify(key Key, value interface{}, reason EvictReason) {
+	e := Eviction{key, value, reason}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		d.dropped.Add(1)
+		return
+	}
+	switch d.o.Backpressure {
+	case DropNewest:
+		select {
+		case d.ch <- e:
+		default:
+			d.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case d.ch <- e:
+				return
+			default:
+			}
+			// 队列满了，丢弃最早的通知再重试。
+			select {
+			case <-d.ch:
+				d.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		d.ch <- e
+	}
+}
+
+// Dropped returns the number of evictions discarded because the queue
+// was full or the Dispatcher closed.
+func (d *Dispatcher) Dropped() int64 {
+	return d.dropped.Load()
+}
+
+// Close stops accepting evictions, and returns once the callback was
+// called for the queued ones.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.ch)
+	}
+	d.mu.Unlock()
+	<-d.done
+}
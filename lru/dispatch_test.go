@@ -0,0 +1,78 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	var got []string
+	d := NewDispatcher(func(e Eviction) {
+		got = append(got, fmt.Sprintf("%v:%v", e.Key, e.Reason))
+	}, nil)
+	c := New(1)
+	c.OnEvictedReason = d.Notify
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Remove("b")
+	d.Close()
+	if s := fmt.Sprint(got); s != "[a:capacity b:removed]" {
+		t.Errorf("callback got %s; want [a:capacity b:removed]", s)
+	}
+	d.Notify("c", 3, EvictRemoved)
+	if d.Dropped() != 1 {
+		t.Errorf("Dropped = %d after Close; want 1", d.Dropped())
+	}
+}
+
+func TestDispatcherBackpressure(t *testing.T) {
+	for _, tt := range []struct {
+		bp   Backpressure
+		want string
+	}{
+		{DropNewest, "[0 1]"},
+		{DropOldest, "[3 4]"},
+	} {
+		release := make(chan struct{})
+		started := make(chan struct{})
+		var got []Key
+		d := NewDispatcher(func(e Eviction) {
+			if e.Key == "block" {
+				close(started)
+				<-release
+				return
+			}
+			got = append(got, e.Key)
+		}, &DispatcherOptions{Buffer: 2, Backpressure: tt.bp})
+		// The callback blocks on the first eviction, so the queue fills.
+		d.Notify("block", nil, EvictCapacity)
+		<-started
+		for i := 0; i < 5; i++ {
+			d.Notify(i, nil, EvictCapacity)
+		}
+		close(release)
+		d.Close()
+		if s := fmt.Sprint(got); s != tt.want {
+			t.Errorf("backpressure %d: callback got %s; want %s", tt.bp, s, tt.want)
+		}
+		if d.Dropped() != 3 {
+			t.Errorf("backpressure %d: Dropped = %d; want 3", tt.bp, d.Dropped())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap is a min-heap of the entries that expire, by expiration
+// time, so that RemoveExpired only visits the expired ones.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].hidx = i + 1
+	h[j].hidx = j + 1
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.hidx = len(*h) + 1
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.hidx = 0
+	return e
+}
+
+// setExpires sets when e expires, keeping it in the heap only if it
+// does.
+func (c *Cache) setExpires(e *entry, t time.Time) {
+	e.expires = t
+	switch {
+	case t.IsZero() && e.hidx > 0:
+		heap.Remove(&c.expiry, e.hidx-1)
+	case t.IsZero():
+	case e.hidx > 0:
+		heap.Fix(&c.expiry, e.hidx-1)
+	default:
+		heap.Push(&c.expiry, e)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "expvar"
+
+// PublishExpvar publishes the cache's statistics as the expvar
+// variable name, a JSON object holding its Stats counters, its Len as
+// "entries" and its Bytes as "bytes". The variable may be read while
+// the cache is in use. Like expvar.Publish, it panics if name is
+// already published.
+func (c *Cache) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		st := c.Stats()
+		return map[string]int64{
+			"hits":      st.Hits,
+			"misses":    st.Misses,
+			"adds":      st.Adds,
+			"updates":   st.Updates,
+			"evictions": st.Evictions,
+			"removals":  st.Removals,
+			"rejected":  st.Rejected,
+			"entries":   c.lenVar.Load(),
+			"bytes":     c.bytesVar.Load(),
+		}
+	}))
+}
+
+// storeSize records Len and Bytes for PublishExpvar.
+func (c *Cache) storeSize() {
+	c.lenVar.Store(int64(c.Len()))
+	c.bytesVar.Store(c.nbytes)
+}
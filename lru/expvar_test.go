@@ -0,0 +1,43 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	c := New(2)
+	c.PublishExpvar("lru_test")
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Get("a")
+	c.Get("c")
+	var got map[string]int64
+	if err := json.Unmarshal([]byte(expvar.Get("lru_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"hits": 1, "misses": 1, "adds": 3, "evictions": 1, "entries": 2, "bytes": 2}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %d; want %d", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// LFU is a least frequently used cache: it evicts the entry hit the
+// fewest times, and the least recently used of those on ties. It suits
+// workloads whose popular keys stay popular, at the cost of keeping
+// keys that were popular once. Like Cache it is not safe for
+// concurrent access, and its zero value is ready to use.
+type LFU struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	// freqs holds an *lfuBucket per distinct hit count, in increasing
+	// order of count; each bucket lists its entries from the most to
+	// the least recently used.
+	freqs *list.List
+	cache map[interface{}]*list.Element
+}
+
+var _ Interface = (*LFU)(nil)
+
+type lfuBucket struct {
+	count int
+	items *list.List
+}
+
+type lfuEntry struct {
+	key    Key
+	value  interface{}
+	bucket *list.Element // element of freqs
+}
+
+func (c *LFU) init() {
+	if c.cache == nil {
+		c.cache = make(map[interface{}]*list.Element)
+		c.freqs = list.New()
+	}
+}
+
+// touch counts a hit of the entry, moving it to the next bucket.
+// 命中次数加一，移到下一个频率桶中。
+func (c *LFU) touch(ele *list.Element) {
+	e := ele.Value.(*lfuEntry)
+	b := e.bucket.Value.(*lfuBucket)
+	next := e.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).count != b.count+1 {
+		next = c.freqs.InsertAfter(&lfuBucket{count: b.count + 1, items: list.New()}, e.bucket)
+	}
+	b.items.Remove(ele)
+	if b.items.Len() == 0 {
+		c.freqs.Remove(e.bucket)
+	}
+	e.bucket = next
+	c.cache[e.key] = next.Value.(*lfuBucket).items.PushFront(e)
+}
+
+// Add adds a value to the cache. Replacing a value counts as a hit.
+func (c *LFU) Add(key Key, value interface{}) {
+	c.init()
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*lfuEntry).value = value
+		c.touch(ele)
+		return
+	}
+	if c.MaxEntries != 0 && len(c.cache) >= c.MaxEntries {
+		c.RemoveOldest()
+	}
+	first := c.freqs.Front()
+	if first == nil || first.Value.(*lfuBucket).count != 1 {
+		first = c.freqs.PushFront(&lfuBucket{count: 1, items: list.New()})
+	}
+	c.cache[key] = first.Value.(*lfuBucket).items.PushFront(&lfuEntry{key, value, first})
+}
+
+// Get looks up a key's value from the cache.
+func (c *LFU) Get(key Key) (value interface{}, ok bool) {
+	if ele, hit := c.cache[key]; hit {
+		c.touch(ele)
+		return c.cache[key].Value.(*lfuEntry).value, true
+	}
+	return
+}
+
+// Remove removes the provided key from the cache.
+func (c *LFU) Remove(key Key) {
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveOldest removes the least frequently used item from the cache.
+func (c *LFU) RemoveOldest() {
+	if c.cache == nil {
+		return
+	}
+	if b := c.freqs.Front(); b != nil {
+		c.removeElement(b.Value.(*lfuBucket).items.Back())
+	}
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or counting a hit.
+func (c *LFU) PeekOldest() (key Key, value interface{}, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if b := c.freqs.Front(); b != nil {
+		e := b.Value.(*lfuBucket).items.Back().Value.(*lfuEntry)
+		return e.key, e.value, true
+	}
+	return
+}
+
+func (c *LFU) removeElement(ele *list.Element) {
+	e := ele.Value.(*lfuEntry)
+	b := e.bucket.Value.(*lfuBucket)
+	b.items.Remove(ele)
+	if b.items.Len() == 0 {
+		c.freqs.Remove(e.bucket)
+	}
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *LFU) Len() int {
+	return len(c.cache)
+}
+
+// Clear purges all stored items from the cache.
+func (c *LFU) Clear() {
+	if c.OnEvicted != nil {
+		for _, ele := range c.cache {
+			e := ele.Value.(*lfuEntry)
+			c.OnEvicted(e.key, e.value)
+		}
+	}
+	c.freqs = nil
+	c.cache = nil
+}
+
+// Range calls f for each item in the cache, from the most to the least
+// frequently used, until f returns false. It does not count hits; f
+// must not modify the cache.
+func (c *LFU) Range(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for b := c.freqs.Back(); b != nil; b = b.Prev() {
+		for ele := b.Value.(*lfuBucket).items.Front(); ele != nil; ele = ele.Next() {
+			e := ele.Value.(*lfuEntry)
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
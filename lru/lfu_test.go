@@ -0,0 +1,82 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLFU(t *testing.T) {
+	var evicted []Key
+	c := &LFU{MaxEntries: 3, OnEvicted: func(key Key, _ interface{}) { evicted = append(evicted, key) }}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	// c has the fewest hits.
+	c.Add("d", 4)
+	// d and now b tie with c's count; b was used least recently.
+	c.Get("d")
+	c.Add("e", 5)
+	if got, want := fmt.Sprint(evicted), "[c b]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+	if k, v, ok := c.PeekOldest(); k != "e" || v != 5 || !ok {
+		t.Errorf("PeekOldest = %v, %v, %v; want e, 5, true", k, v, ok)
+	}
+
+	var keys []Key
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := fmt.Sprint(keys), "[a d e]"; got != want {
+		t.Errorf("Range visited %s; want %s", got, want)
+	}
+
+	c.Add("e", 50)
+	if v, ok := c.Get("e"); !ok || v != 50 {
+		t.Errorf("Get(e) = %v, %v; want 50, true", v, ok)
+	}
+	c.Remove("a")
+	if c.Len() != 2 {
+		t.Errorf("Len = %d; want 2", c.Len())
+	}
+	c.Clear()
+	if c.Len() != 0 || len(evicted) != 5 {
+		t.Errorf("Len, evictions = %d, %d; want 0, 5", c.Len(), len(evicted))
+	}
+}
+
+func TestLFUScanResistance(t *testing.T) {
+	c := &LFU{MaxEntries: 10}
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprint("hot", i), i)
+		c.Get(fmt.Sprint("hot", i))
+	}
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprint("scan", i), i)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(fmt.Sprint("hot", i)); !ok {
+			t.Errorf("hot%d was evicted by a scan", i)
+		}
+	}
+}
@@ -18,7 +18,14 @@ limitations under the License.
 // LRU缓存，非并发安全，而且GO的map也不是并发安全的
 package lru
 
-import "container/list"
+import (
+	"container/heap"
+	"container/list"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type Cache struct {
 	// MaxEntries is the maximum number of cache entries before
@@ -29,16 +36,183 @@ type Cache struct {
 	// executed when an entry is purged from the cache.
 	OnEvicted func(key Key, value interface{}) // 缓存淘汰时使用的回调函数；可选项。
 
+	// OnEvictedReason optionally specifies a callback function to be
+	// executed, after OnEvicted, when an entry is purged from the
+	// cache, with the reason it was. It is also called with
+	// EvictReplaced and the old value when Add replaces a value, for
+	// which OnEvicted is not called.
+	OnEvictedReason func(key Key, value interface{}, reason EvictReason)
+
+	// OnAdded and OnUpdated optionally specify callback functions to
+	// be executed when Add adds a key, and when it replaces the value
+	// of a cached key. They run before any eviction the addition
+	// causes.
+	OnAdded   func(key Key, value interface{})
+	OnUpdated func(key Key, old, new interface{})
+
+	// Overflow optionally specifies a second tier, such as a disk
+	// store, receiving the entries evicted to make room. Get looks up
+	// the keys it misses there, and moves the values it finds back
+	// into the cache. Remove deletes keys from it too.
+	Overflow OverflowStore
+
+	// KeyFunc optionally canonicalizes keys, for example by
+	// lowercasing them, so that equivalent keys share an entry. The
+	// methods taking a key apply it first, and callbacks see its
+	// result. Since it may be applied more than once to a key, it
+	// must return its argument for the keys it returns.
+	KeyFunc func(Key) Key
+
+	// Compressor optionally compresses the []byte values longer than
+	// CompressAbove bytes, which are stored compressed when that
+	// makes them shorter and decompressed by Get. SizeFunc is then
+	// called with the compressed bytes, so that more values fit in
+	// MaxBytes. Set both before adding entries.
+	Compressor    Compressor
+	CompressAbove int
+
+	// Observer optionally specifies instrumentation, such as tracing
+	// or metrics, called at each Get, Add and eviction.
+	Observer Observer
+
+	// GhostEntries is the number of keys evicted to make room that
+	// the cache remembers, without their values, for
+	// WasRecentlyEvicted. Zero means none.
+	GhostEntries int
+
+	// Mode chooses the order in which entries are evicted. The zero
+	// value is LRU.
+	Mode EvictionMode
+
+	// MaxAge is how long after being added entries expire, unless
+	// added with their own TTL. Zero means they don't.
+	MaxAge time.Duration
+
+	// NegativeTTL is how long the entries of AddNegative last. Zero
+	// means MaxAge.
+	NegativeTTL time.Duration
+
+	// JanitorInterval, if positive, makes the cache purge its expired
+	// entries, as by PurgeExpired, at the first Add or Get after each
+	// interval. Unlike StartJanitor it needs no goroutine or lock, so
+	// it suits caches used by a single goroutine.
+	JanitorInterval time.Duration
+
+	// MaxBytes is the maximum total size of the cache entries, as
+	// measured by SizeFunc, before an item is evicted. Zero means no
+	// limit. It may be combined with MaxEntries.
+	MaxBytes int64
+
+	// SizeFunc returns the size of an entry, counted against
+	// MaxBytes. If nil, every entry has size 1. It must return the
+	// same size for an entry each time it is called; set it before
+	// adding entries.
+	SizeFunc func(key Key, value interface{}) int64
+
+	// PromoteEvery and PromoteInterval optionally make Get move an
+	// entry to the front lazily: only once every PromoteEvery hits,
+	// and once PromoteInterval has passed since it was last moved.
+	// This spares most of the list updates for very hot keys, which
+	// stay near the front anyway. Zero means every hit.
+	PromoteEvery    int
+	PromoteInterval time.Duration
+
+	// OverflowPolicy chooses what Add does with a new key when the
+	// cache is full. The zero value is InsertThenEvict.
+	OverflowPolicy OverflowPolicy
+
+	// LowWatermark optionally makes the cache evict in batches: once
+	// an Add takes it beyond MaxEntries or MaxBytes, it evicts down
+	// to that fraction of the limit, such as 0.9, rather than to the
+	// limit itself. Zero, or 1 or more, means the limit.
+	LowWatermark float64
+
+	nbytes int64  // 所有缓存的大小之和。
+	ntier  [3]int // number of entries of each Priority
+	vbytes int64  // 缓存值的大致内存占用，见SizeBytes
+
+	hits, misses, adds, updates, evictions, removals, rejected atomic.Int64
+
+	purged time.Time // of the last purge by JanitorInterval
+
+	// Len and Bytes, for PublishExpvar, which reads them concurrently.
+	lenVar, bytesVar atomic.Int64
+
+	expiry expiryHeap // 会过期的缓存，最早过期的在前
+
+	ghostLL *list.List // 最近淘汰的key，最新的在前
+	ghosts  map[interface{}]*list.Element
+
 	ll    *list.List	// 数据用链表来存储，适合缓存淘汰。
 	cache map[interface{}]*list.Element		// 并且查缓存时用的是map，查询更快。
 }
 
+// Interface is implemented by the caches of this package, so that
+// users can choose an eviction policy. RemoveOldest evicts the entry
+// the policy considers least valuable.
+type Interface interface {
+	Add(key Key, value interface{})
+	Get(key Key) (value interface{}, ok bool)
+	Remove(key Key)
+	RemoveOldest()
+	Len() int
+	Clear()
+	Range(f func(key Key, value interface{}) bool)
+}
+
+var _ Interface = (*Cache)(nil)
+
+// An EvictReason tells why an entry left a Cache.
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // removed by RemoveOldest, as when the cache is full
+	EvictRemoved                     // removed by Remove
+	EvictCleared                     // removed by Clear
+	EvictExpired                     // its TTL passed
+	EvictReplaced                    // its value was replaced by Add
+)
+
+var evictReasons = [...]string{"capacity", "removed", "cleared", "expired", "replaced"}
+
+func (r EvictReason) String() string {
+	if r >= 0 && int(r) < len(evictReasons) {
+		return evictReasons[r]
+	}
+	return "EvictReason(" + strconv.Itoa(int(r)) + ")"
+}
+
 // A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
 type Key interface{}
 
 type entry struct {
-	key   Key
-	value interface{}
+	key     Key
+	value   interface{}
+	expires time.Time // zero if the entry doesn't expire
+	size    int64     // as returned by SizeFunc
+	pinned  bool      // skipped by RemoveOldest
+	prio    Priority
+	ttl     time.Duration // of AddWithTTL, for Touch
+	vsize   int64         // as returned by valueSize
+	hidx    int           // index in Cache.expiry plus one, zero if not there
+
+	created, accessed time.Time // for EntryInfo
+	hits              int64
+
+	promoted time.Time // when last moved to the front, for lazy promotion
+	since    int       // hits since then
+}
+
+// entryPool recycles the entries removed from caches. The list
+// elements holding them can't be recycled, since container/list
+// allocates its own.
+var entryPool = sync.Pool{New: func() interface{} { return new(entry) }}
+
+// now is time.Now, replaced by tests.
+var now = time.Now
+
+func (e *entry) expired(t time.Time) bool {
+	return !e.expires.IsZero() && !t.Before(e.expires)
 }
 
 // New creates a new Cache.
@@ -52,74 +226,907 @@ func New(maxEntries int) *Cache {
 	}
 }
 
-// Add adds a value to the cache.
+// An EvictionMode is the order in which a Cache evicts its entries.
+type EvictionMode int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU EvictionMode = iota
+	// FIFO evicts the entry added first: Gets don't change the
+	// order, which spares their bookkeeping for caches of data
+	// accessed uniformly.
+	FIFO
+	// MRU evicts the most recently used entry other than the one
+	// being added, which keeps more of a large cyclic scan cached
+	// than LRU does. RemoveOldest and PeekOldest then deal with the
+	// most recently used entry.
+	MRU
+)
+
+// An OverflowPolicy is what a Cache does with new keys added when it
+// is full.
+type OverflowPolicy int
+
+const (
+	// InsertThenEvict adds the new entry, then evicts to make room,
+	// so the cache briefly exceeds its limits.
+	InsertThenEvict OverflowPolicy = iota
+	// EvictThenInsert evicts to make room, then adds the new entry,
+	// so the cache never exceeds MaxEntries or MaxBytes, except for
+	// pinned entries and for entries larger than MaxBytes, which are
+	// evicted right after being added.
+	EvictThenInsert
+	// RejectNew doesn't add new keys that don't fit, and counts them
+	// in Stats.Rejected. Cached keys are still replaced.
+	RejectNew
+)
+
+// promote marks the element as just used.
+func (c *Cache) promote(ele *list.Element) {
+	if c.Mode != FIFO {
+		c.ll.MoveToFront(ele)
+	}
+	if c.PromoteEvery > 1 || c.PromoteInterval > 0 {
+		e := ele.Value.(*entry)
+		e.promoted, e.since = now(), 0
+	}
+}
+
+// promoteHit is promote for a hit at t, unless PromoteEvery or
+// PromoteInterval says to skip it.
+func (c *Cache) promoteHit(ele *list.Element, t time.Time) {
+	if c.PromoteEvery > 1 || c.PromoteInterval > 0 {
+		e := ele.Value.(*entry)
+		if e.since++; e.since < c.PromoteEvery || t.Sub(e.promoted) < c.PromoteInterval {
+			return
+		}
+	}
+	c.promote(ele)
+}
+
+// An OverflowStore holds the entries a Cache evicted to make room.
+// The Cache calls it while its users hold their lock, so it should be
+// fast, or hand work to another goroutine.
+type OverflowStore interface {
+	Put(key Key, value interface{})
+	Get(key Key) (value interface{}, ok bool)
+	Delete(key Key)
+}
+
+// An Observer is told of the operations of a Cache. The Cache calls
+// it while its users hold their lock, so it should be fast. SyncCache
+// reports misses holding only a read lock, so OnGet must be safe for
+// concurrent use.
+type Observer interface {
+	// OnGet is called after each Get, with whether it was a hit and
+	// how long it took.
+	OnGet(key Key, hit bool, latency time.Duration)
+	// OnAdd is called after each addition of a value, with how long
+	// it took, including the evictions it caused.
+	OnAdd(key Key, latency time.Duration)
+	// OnEvict is called when an entry leaves the cache, and with
+	// EvictReplaced when Add replaces a value.
+	OnEvict(key Key, reason EvictReason)
+}
+
+// A Priority orders the entries of a Cache for eviction: entries of a
+// lower priority are all evicted before those of a higher one, and
+// entries of the same priority from the least recently used.
+type Priority int8
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0 // the priority of Add and AddWithTTL
+	PriorityHigh   Priority = 1
+)
+
+// Options configure a Cache created by NewWithOptions.
+type Options struct {
+	MaxEntries int
+	MaxBytes   int64
+	SizeFunc   func(key Key, value interface{}) int64
+	KeyFunc    func(Key) Key
+	OnEvicted  func(key Key, value interface{})
+
+	GhostEntries   int
+	Mode           EvictionMode
+	MaxAge         time.Duration
+	NegativeTTL    time.Duration
+	OverflowPolicy OverflowPolicy
+	LowWatermark   float64
+
+	JanitorInterval time.Duration
+
+	PromoteEvery    int
+	PromoteInterval time.Duration
+
+	// Capacity is the number of entries to allocate room for up
+	// front, sparing the cache the growth of its index as it fills.
+	// If zero, it defaults to MaxEntries.
+	Capacity int
+}
+
+// NewWithOptions creates a new Cache configured by o. See the fields
+// of Cache for the meaning of the options.
+func NewWithOptions(o Options) *Cache {
+	n := o.Capacity
+	if n <= 0 {
+		n = o.MaxEntries
+	}
+	return &Cache{
+		MaxEntries:      o.MaxEntries,
+		MaxBytes:        o.MaxBytes,
+		SizeFunc:        o.SizeFunc,
+		KeyFunc:         o.KeyFunc,
+		OnEvicted:       o.OnEvicted,
+		GhostEntries:    o.GhostEntries,
+		Mode:            o.Mode,
+		MaxAge:          o.MaxAge,
+		NegativeTTL:     o.NegativeTTL,
+		JanitorInterval: o.JanitorInterval,
+		OverflowPolicy:  o.OverflowPolicy,
+		LowWatermark:    o.LowWatermark,
+		PromoteEvery:    o.PromoteEvery,
+		PromoteInterval: o.PromoteInterval,
+		ll:              list.New(),
+		// 预先分配map的容量，避免缓存填满时反复扩容。
+		cache: make(map[interface{}]*list.Element, n),
+	}
+}
+
+// NewWithWarmup creates a new Cache like New, and fills it before
+// returning by calling loader, which adds entries with add, for
+// example from a snapshot or a list of hot keys. Items added last are
+// the most recently used. See NewSyncWithWarmup to fill a cache in the
+// background.
+func NewWithWarmup(maxEntries int, loader func(add func(key Key, value interface{}))) *Cache {
+	c := New(maxEntries)
+	loader(c.Add)
+	return c
+}
+
+// Add adds a value to the cache. The entry expires after MaxAge if
+// set, and otherwise doesn't, even if it replaces one that did. It has
+// PriorityNormal.
 func (c *Cache) Add(key Key, value interface{}) {
+	c.add(key, value, time.Time{}, PriorityNormal)
+}
+
+// AddMulti adds each of the values to the cache, in no particular
+// order.
+func (c *Cache) AddMulti(values map[Key]interface{}) {
+	for key, value := range values {
+		c.Add(key, value)
+	}
+}
+
+// Negative is the value Get returns for the keys added by AddNegative.
+var Negative interface{} = negative{}
+
+type negative struct{}
+
+// AddNegative records that key has no value upstream, so that callers
+// checking the cache before loading don't load it again: Get then
+// returns Negative and true. The entry expires after NegativeTTL, and
+// counts for 1 against MaxBytes. Eviction callbacks get Negative as
+// its value.
+func (c *Cache) AddNegative(key Key) {
+	c.AddWithTTL(key, Negative, c.NegativeTTL)
+}
+
+// Update replaces the value of key, if it is cached and unexpired, by
+// fn of its old value, and reports whether it did. Only the value
+// changes: the item keeps its expiration, TTL, priority and pin. It is
+// marked as just used, and the callbacks are called as by Add.
+func (c *Cache) Update(key Key, fn func(old interface{}) interface{}) bool {
+	key = c.canon(key)
+	ele, hit := c.cache[key]
+	if !hit {
+		return false
+	}
+	e := ele.Value.(*entry)
+	if e.expired(now()) {
+		return false
+	}
+	old, err := c.unpack(e.value)
+	if err != nil {
+		return false
+	}
+	c.replace(ele, key, fn(old))
+	return true
+}
+
+// AddIfAbsent adds a value to the cache if key is not cached, or only
+// cached expired, and reports whether it did. Otherwise it returns the
+// cached value, without changing its recency.
+func (c *Cache) AddIfAbsent(key Key, value interface{}) (existing interface{}, added bool) {
+	if v, ok := c.Peek(key); ok {
+		return v, false
+	}
+	c.Add(key, value)
+	return nil, true
+}
+
+// AddWithPriority adds a value to the cache with the given priority.
+// The entry doesn't expire.
+func (c *Cache) AddWithPriority(key Key, value interface{}, p Priority) {
+	if p < PriorityLow {
+		p = PriorityLow
+	} else if p > PriorityHigh {
+		p = PriorityHigh
+	}
+	c.add(key, value, time.Time{}, p)
+}
+
+// AddWithTTL adds a value to the cache that expires after ttl. Get
+// treats expired entries as misses and removes them; RemoveExpired
+// removes them all. With a ttl of zero or less the entry expires after
+// MaxAge, if set, like one added by Add.
+func (c *Cache) AddWithTTL(key Key, value interface{}, ttl time.Duration) {
+	key = c.canon(key)
+	var expires time.Time
+	if ttl > 0 {
+		expires = now().Add(ttl)
+	}
+	c.add(key, value, expires, PriorityNormal)
+	if ele, ok := c.cache[key]; ok && ttl > 0 {
+		ele.Value.(*entry).ttl = ttl
+	}
+}
+
+func (c *Cache) add(key Key, value interface{}, expires time.Time, prio Priority) {
+	key = c.canon(key)
+	if c.Observer != nil {
+		start := now()
+		defer func() { c.Observer.OnAdd(key, now().Sub(start)) }()
+	}
+	if expires.IsZero() && c.MaxAge > 0 {
+		expires = now().Add(c.MaxAge)
+	}
 	if c.cache == nil {
 		c.cache = make(map[interface{}]*list.Element)
 		c.ll = list.New()
 	}
+	c.janitor()
 	// 如果缓存存在，就把该值放到链表最前面，表示刚刚访问过的。
 	if ee, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ee)
-		ee.Value.(*entry).value = value
+		e := ee.Value.(*entry)
+		e.ttl = 0
+		c.setExpires(e, expires)
+		c.ntier[e.prio+1]--
+		c.ntier[prio+1]++
+		e.prio = prio
+		c.replace(ee, key, value)
 		return
 	}
 	// 缓存不存在，就在链表前面插入；如果超范围了，就在删除链表最后一个缓存。
 	// 但是这样其实不是很合理，正常来说，缓存满了应该先删除，后添加。
-	ele := c.ll.PushFront(&entry{key, value})
+	// 先删除后添加见OverflowPolicy。
+	stored := c.pack(value)
+	size := c.size(key, stored)
+	if !c.makeRoom(size) {
+		c.rejected.Add(1)
+		return
+	}
+	c.adds.Add(1)
+	if g, ok := c.ghosts[key]; ok {
+		c.ghostLL.Remove(g)
+		delete(c.ghosts, key)
+	}
+	vsize := c.valueSize(size, stored)
+	t := now()
+	e := entryPool.Get().(*entry)
+	*e = entry{key: key, value: stored, size: size, prio: prio, vsize: vsize, created: t, accessed: t, promoted: t}
+	c.setExpires(e, expires)
+	ele := c.ll.PushFront(e)
 	c.cache[key] = ele
+	c.nbytes += size
+	c.vbytes += vsize
+	c.ntier[prio+1]++
+	if c.OnAdded != nil {
+		c.OnAdded(key, value)
+	}
 	if c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries {
-		c.RemoveOldest()
+		low := int(c.lowWater(int64(c.MaxEntries)))
+		for c.ll.Len() > low {
+			// 其他缓存都被固定时，保留新加入的缓存。
+			o := c.victim(ele)
+			if o == nil || o == ele {
+				break
+			}
+			c.evictions.Add(1)
+			c.removeElement(o, EvictCapacity)
+		}
 	}
+	c.evictBytes(ele)
+	c.storeSize()
+}
+
+// replace replaces the value of the cached element ee of key, and
+// marks it as just used.
+func (c *Cache) replace(ee *list.Element, key Key, value interface{}) {
+	c.updates.Add(1)
+	c.promote(ee)
+	e := ee.Value.(*entry)
+	old := c.unpacked(e.value)
+	if c.OnEvictedReason != nil {
+		c.OnEvictedReason(key, old, EvictReplaced)
+	}
+	if c.Observer != nil {
+		c.Observer.OnEvict(key, EvictReplaced)
+	}
+	stored := c.pack(value)
+	size := c.size(key, stored)
+	c.nbytes += size - e.size
+	vsize := c.valueSize(size, stored)
+	c.vbytes += vsize - e.vsize
+	e.value, e.size, e.vsize = stored, size, vsize
+	if c.OnUpdated != nil {
+		c.OnUpdated(key, old, value)
+	}
+	c.evictBytes(ee)
+	c.storeSize()
+}
+
+// makeRoom prepares the cache to add an entry of the given size as its
+// OverflowPolicy says, and reports whether the entry may be added.
+func (c *Cache) makeRoom(size int64) bool {
+	switch c.OverflowPolicy {
+	case RejectNew:
+		return (c.MaxEntries == 0 || c.ll.Len() < c.MaxEntries) &&
+			(c.MaxBytes <= 0 || c.nbytes+size <= c.MaxBytes)
+	case EvictThenInsert:
+		if c.MaxEntries != 0 && c.ll.Len() >= c.MaxEntries {
+			low := int(c.lowWater(int64(c.MaxEntries)))
+			for c.ll.Len() >= low && c.removeOldest() {
+			}
+		}
+		if c.MaxBytes > 0 && c.nbytes+size > c.MaxBytes {
+			low := c.lowWater(c.MaxBytes)
+			for c.nbytes+size > low && c.removeOldest() {
+			}
+		}
+	}
+	return true
+}
+
+// valueSize estimates the memory taken by an entry, for SizeBytes: its
+// size if there is a SizeFunc, and otherwise the length of []byte and
+// string values.
+func (c *Cache) valueSize(size int64, value interface{}) int64 {
+	if c.SizeFunc != nil {
+		return size
+	}
+	switch v := value.(type) {
+	case []byte:
+		return int64(len(v))
+	case compressed:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	}
+	return 0
+}
+
+func (c *Cache) size(key Key, value interface{}) int64 {
+	if c.SizeFunc == nil || value == Negative {
+		return 1
+	}
+	if z, ok := value.(compressed); ok {
+		value = []byte(z)
+	}
+	return c.SizeFunc(key, value)
+}
+
+// evictBytes removes the oldest items until the cache fits in
+// MaxBytes. An item larger than MaxBytes is removed too.
+func (c *Cache) evictBytes(keep *list.Element) {
+	if c.MaxBytes <= 0 || c.nbytes <= c.MaxBytes {
+		return
+	}
+	low := c.lowWater(c.MaxBytes)
+	for c.nbytes > low {
+		ele := c.victim(keep)
+		if ele == nil {
+			// 只剩下keep时，也淘汰它。
+			if ele = c.oldest(); ele == nil {
+				return
+			}
+		}
+		c.evictions.Add(1)
+		c.removeElement(ele, EvictCapacity)
+	}
+}
+
+// lowWater returns the size down to which a cache beyond limit is
+// evicted.
+func (c *Cache) lowWater(limit int64) int64 {
+	if c.LowWatermark <= 0 || c.LowWatermark >= 1 {
+		return limit
+	}
+	return int64(float64(limit) * c.LowWatermark)
+}
+
+// canon returns the canonical form of key, as given by KeyFunc.
+func (c *Cache) canon(key Key) Key {
+	if c.KeyFunc == nil {
+		return key
+	}
+	return c.KeyFunc(key)
 }
 
 // Get looks up a key's value from the cache.
 func (c *Cache) Get(key Key) (value interface{}, ok bool) {
+	key = c.canon(key)
+	if c.Observer != nil {
+		start := now()
+		defer func() { c.Observer.OnGet(key, ok, now().Sub(start)) }()
+	}
+	c.janitor()
+	// 如果缓存存在，就把该值放到链表最前面，表示刚刚访问过的。返回查询到的数据。
+	if ele, hit := c.cache[key]; hit {
+		e, t := ele.Value.(*entry), now()
+		if e.expired(t) {
+			// 过期的缓存在访问时删除。
+			c.evictions.Add(1)
+			c.removeElement(ele, EvictExpired)
+			c.misses.Add(1)
+			return nil, false
+		}
+		if value, err := c.unpack(e.value); err == nil {
+			c.hits.Add(1)
+			c.promoteHit(ele, t)
+			e.accessed = t
+			e.hits++
+			return value, true
+		}
+		// 解压失败时当作未命中。
+		c.misses.Add(1)
+		return nil, false
+	}
+	if c.Overflow != nil {
+		// 内存中没有时，再到二级存储中查找，找到后移回内存。
+		if value, ok = c.Overflow.Get(key); ok {
+			c.hits.Add(1)
+			c.Add(key, value)
+			// 只有真正移回内存后才从二级存储中删除。
+			if _, in := c.cache[key]; in {
+				c.Overflow.Delete(key)
+			}
+			return value, true
+		}
+	}
+	c.misses.Add(1)
+	return
+}
+
+// Touch marks key's item as just used, and if it was added with
+// AddWithTTL, restarts its TTL, without reading it. It reports whether
+// key is cached; expired items are removed as by Get. Items loaded by
+// LoadFrom keep their expiration but have no TTL to restart.
+// 刷新缓存的访问时间和过期时间。
+func (c *Cache) Touch(key Key) bool {
+	key = c.canon(key)
+	ele, hit := c.cache[key]
+	if !hit {
+		return false
+	}
+	e := ele.Value.(*entry)
+	t := now()
+	if e.expired(t) {
+		c.evictions.Add(1)
+		c.removeElement(ele, EvictExpired)
+		return false
+	}
+	c.promote(ele)
+	e.accessed = t
+	if e.ttl > 0 {
+		c.setExpires(e, t.Add(e.ttl))
+	}
+	return true
+}
+
+// EntryInfo describes a cached item.
+type EntryInfo struct {
+	Created    time.Time // when the key was added, not replaced
+	LastAccess time.Time // of the last Get or Touch, or Created
+	Hits       int64     // Gets of the key since it was added
+	Expires    time.Time // zero if the item doesn't expire
+	Size       int64     // as counted against MaxBytes
+	Priority   Priority
+	Pinned     bool
+}
+
+// EntryInfo describes the unexpired item of key, without changing its
+// recency, and reports whether there is one.
+func (c *Cache) EntryInfo(key Key) (info EntryInfo, ok bool) {
+	key = c.canon(key)
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	e := ele.Value.(*entry)
+	if e.expired(now()) {
+		return
+	}
+	return EntryInfo{
+		Created:    e.created,
+		LastAccess: e.accessed,
+		Hits:       e.hits,
+		Expires:    e.expires,
+		Size:       e.size,
+		Priority:   e.prio,
+		Pinned:     e.pinned,
+	}, true
+}
+
+// Contains reports whether key is cached and unexpired, without
+// updating its recency.
+func (c *Cache) Contains(key Key) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+// GetOrAdd returns key's value if it is cached, and otherwise calls
+// loader and adds the value it returns. Errors of loader are returned
+// and nothing is added.
+func (c *Cache) GetOrAdd(key Key, loader func() (interface{}, error)) (value interface{}, err error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	if value, err = loader(); err != nil {
+		return nil, err
+	}
+	c.Add(key, value)
+	return value, nil
+}
+
+// GetWithExpiration is like Get, but also returns when the item
+// expires, the zero Time if it doesn't.
+func (c *Cache) GetWithExpiration(key Key) (value interface{}, expiresAt time.Time, ok bool) {
+	key = c.canon(key)
+	if value, ok = c.Get(key); ok {
+		// 从二级存储取回的值可能没能放回内存。
+		if ele, hit := c.cache[key]; hit {
+			expiresAt = ele.Value.(*entry).expires
+		}
+	}
+	return
+}
+
+// GetMulti looks up the values of keys, like Get, and returns those of
+// the keys that are cached.
+func (c *Cache) GetMulti(keys []Key) map[Key]interface{} {
+	values := make(map[Key]interface{}, len(keys))
+	for _, key := range keys {
+		if v, ok := c.Get(key); ok {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+// Peek looks up a key's value from the cache without updating its
+// recency, so that lookups for monitoring don't change what is
+// evicted. Expired entries are misses, but are not removed.
+func (c *Cache) Peek(key Key) (value interface{}, ok bool) {
+	key = c.canon(key)
 	if c.cache == nil {
 		return
 	}
-	// 如果缓存存在，就把该值放到链表最前面，表示刚刚访问过的。返回查询到的数据。
 	if ele, hit := c.cache[key]; hit {
-		c.ll.MoveToFront(ele)
-		return ele.Value.(*entry).value, true
+		if e := ele.Value.(*entry); !e.expired(now()) {
+			v, err := c.unpack(e.value)
+			return v, err == nil
+		}
 	}
 	return
 }
 
+// PurgeExpired removes the expired entries from the cache and returns
+// how many there were. It only inspects the expired entries.
+func (c *Cache) PurgeExpired() int {
+	return c.purgeExpired(now())
+}
+
+// RemoveExpired is PurgeExpired.
+func (c *Cache) RemoveExpired() int {
+	return c.PurgeExpired()
+}
+
+// janitor runs PurgeExpired if JanitorInterval has passed since it
+// last did.
+func (c *Cache) janitor() {
+	if c.JanitorInterval <= 0 {
+		return
+	}
+	if t := now(); t.Sub(c.purged) >= c.JanitorInterval {
+		c.purged = t
+		c.purgeExpired(t)
+	}
+}
+
+func (c *Cache) purgeExpired(t time.Time) int {
+	n := 0
+	// 堆顶是最早过期的缓存。
+	for len(c.expiry) > 0 && c.expiry[0].expired(t) {
+		c.removeElement(c.cache[c.expiry[0].key], EvictExpired)
+		n++
+	}
+	c.evictions.Add(int64(n))
+	return n
+}
+
+// StartJanitor calls PurgeExpired every interval, holding mu, until
+// the returned function is called. Since Cache is not safe for
+// concurrent access, mu must be the lock its users hold. Caches used by
+// a single goroutine may set JanitorInterval instead.
+func (c *Cache) StartJanitor(interval time.Duration, mu sync.Locker) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				mu.Lock()
+				c.PurgeExpired()
+				mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
 // Remove removes the provided key from the cache.
 // 根据key删除缓存。
 func (c *Cache) Remove(key Key) {
-	if c.cache == nil {
-		return
+	key = c.canon(key)
+	if c.Overflow != nil {
+		c.Overflow.Delete(key)
 	}
 	if ele, hit := c.cache[key]; hit {
-		c.removeElement(ele)
+		c.removals.Add(1)
+		c.removeElement(ele, EvictRemoved)
 	}
 }
 
-// RemoveOldest removes the oldest item from the cache.
+// RemoveOldest removes the oldest item from the cache that is not
+// pinned, of the lowest Priority that has one.
 // 删除最早的数据。
 func (c *Cache) RemoveOldest() {
+	c.removeOldest()
+}
+
+// removeOldest is RemoveOldest, reporting whether it removed an item.
+func (c *Cache) removeOldest() bool {
+	ele := c.oldest()
+	if ele == nil {
+		return false
+	}
+	c.evictions.Add(1)
+	c.removeElement(ele, EvictCapacity)
+	return true
+}
+
+// oldest returns the oldest element that is not pinned, of the lowest
+// priority that has one, if any. In MRU mode it is the newest instead.
+func (c *Cache) oldest() *list.Element {
+	return c.victim(nil)
+}
+
+// victim is oldest, except that in MRU mode it skips keep, the element
+// being added.
+func (c *Cache) victim(keep *list.Element) *list.Element {
 	if c.cache == nil {
-		return
+		return nil
+	}
+	for p := PriorityLow; p <= PriorityHigh; p++ {
+		if c.ntier[p+1] == 0 {
+			continue
+		}
+		// 从最低优先级开始找最早的缓存。
+		if c.Mode == MRU {
+			for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+				if e := ele.Value.(*entry); ele != keep && e.prio == p && !e.pinned {
+					return ele
+				}
+			}
+			continue
+		}
+		for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+			if e := ele.Value.(*entry); e.prio == p && !e.pinned {
+				return ele
+			}
+		}
+	}
+	return nil
+}
+
+// Pin keeps the cached item of key from being removed by RemoveOldest,
+// and so by the cache's limits, and reports whether key is cached. Once
+// the items other than the newest are all pinned, the cache grows
+// beyond MaxEntries. Pinned items are still removed by Remove, Clear
+// and expiration.
+// 固定的缓存不会被淘汰。
+func (c *Cache) Pin(key Key) bool {
+	key = c.canon(key)
+	ele, ok := c.cache[key]
+	if ok {
+		ele.Value.(*entry).pinned = true
 	}
-	ele := c.ll.Back()
-	if ele != nil {
-		c.removeElement(ele)
+	return ok
+}
+
+// Unpin undoes Pin, and reports whether key is cached. The item may be
+// removed at the next eviction.
+func (c *Cache) Unpin(key Key) bool {
+	key = c.canon(key)
+	ele, ok := c.cache[key]
+	if ok {
+		ele.Value.(*entry).pinned = false
 	}
+	return ok
 }
 
-func (c *Cache) removeElement(e *list.Element) {
+// RemoveOldestN removes up to n of the oldest items from the cache,
+// calling OnEvicted for each, and returns how many it removed.
+// 批量删除最早的数据。
+func (c *Cache) RemoveOldestN(n int) int {
+	removed := 0
+	for removed < n && c.removeOldest() {
+		removed++
+	}
+	return removed
+}
+
+// Resize sets MaxEntries to newMax and removes the oldest items until
+// the cache fits, returning how many it removed. Zero means no limit.
+// 运行时调整缓存大小。
+func (c *Cache) Resize(newMax int) (evicted int) {
+	c.MaxEntries = newMax
+	for newMax > 0 && c.Len() > newMax && c.removeOldest() {
+		evicted++
+	}
+	return evicted
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or changing its recency.
+func (c *Cache) PeekOldest() (key Key, value interface{}, ok bool) {
+	if ele := c.oldest(); ele != nil {
+		kv := ele.Value.(*entry)
+		return kv.key, c.unpacked(kv.value), true
+	}
+	return
+}
+
+func (c *Cache) removeElement(e *list.Element, reason EvictReason) {
 	c.ll.Remove(e)
 	kv := e.Value.(*entry)
 	delete(c.cache, kv.key)
+	if kv.hidx > 0 {
+		heap.Remove(&c.expiry, kv.hidx-1)
+	}
+	c.nbytes -= kv.size
+	c.vbytes -= kv.vsize
+	c.ntier[kv.prio+1]--
+	if reason == EvictCapacity && c.Overflow != nil {
+		c.Overflow.Put(kv.key, c.unpacked(kv.value))
+	}
+	if reason == EvictCapacity && c.GhostEntries > 0 {
+		c.addGhost(kv.key)
+	}
+	c.evicted(kv, reason)
+	c.storeSize()
+	// 回收entry，减少淘汰频繁时的内存分配。
+	*kv = entry{}
+	entryPool.Put(kv)
+}
+
+// addGhost remembers that key was evicted, forgetting the oldest
+// ghosts beyond GhostEntries.
+func (c *Cache) addGhost(key Key) {
+	if c.ghosts == nil {
+		c.ghosts = make(map[interface{}]*list.Element)
+		c.ghostLL = list.New()
+	}
+	c.ghosts[key] = c.ghostLL.PushFront(key)
+	for c.ghostLL.Len() > c.GhostEntries {
+		delete(c.ghosts, c.ghostLL.Remove(c.ghostLL.Back()))
+	}
+}
+
+// WasRecentlyEvicted reports whether key is among the last
+// GhostEntries keys evicted to make room, and was not added since.
+func (c *Cache) WasRecentlyEvicted(key Key) bool {
+	key = c.canon(key)
+	_, ok := c.ghosts[key]
+	return ok
+}
+
+// evicted calls the eviction callbacks for the entry.
+func (c *Cache) evicted(kv *entry, reason EvictReason) {
+	var value interface{}
+	if c.OnEvicted != nil || c.OnEvictedReason != nil {
+		// 只在有回调时解压。
+		value = c.unpacked(kv.value)
+	}
 	if c.OnEvicted != nil {
 		// 缓存淘汰时如果有回调函数，会直接调用。
-		c.OnEvicted(kv.key, kv.value)
+		c.OnEvicted(kv.key, value)
+	}
+	if c.OnEvictedReason != nil {
+		c.OnEvictedReason(kv.key, value, reason)
+	}
+	if c.Observer != nil {
+		c.Observer.OnEvict(kv.key, reason)
 	}
 }
 
-// Len returns the number of items in the cache.
+// Range calls f for each unexpired item in the cache, from the most to
+// the least recently used, until f returns false. It does not change
+// the recency of the items; f must not modify the cache.
+// 遍历缓存，不改变链表顺序。
+func (c *Cache) Range(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	t := now()
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*entry)
+		if kv.expired(t) {
+			continue
+		}
+		if v, err := c.unpack(kv.value); err == nil && !f(kv.key, v) {
+			return
+		}
+	}
+}
+
+// RangeOldest is like Range but visits the items from the least to the
+// most recently used.
+func (c *Cache) RangeOldest(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	t := now()
+	for e := c.ll.Back(); e != nil; e = e.Prev() {
+		kv := e.Value.(*entry)
+		if kv.expired(t) {
+			continue
+		}
+		if v, err := c.unpack(kv.value); err == nil && !f(kv.key, v) {
+			return
+		}
+	}
+}
+
+// SizeBytes returns an estimate of the memory taken by the items in
+// the cache: their total size as measured by SizeFunc if it is set,
+// and otherwise the total length of the []byte and string values,
+// other values counting for nothing. It includes expired items not
+// removed yet.
+func (c *Cache) SizeBytes() int64 {
+	return c.vbytes
+}
+
+// Keys returns the keys of the unexpired items in the cache, from the
+// most to the least recently used.
+func (c *Cache) Keys() []Key {
+	keys := make([]Key, 0, c.Len())
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Len returns the number of items in the cache, including expired
+// items not removed yet.
 func (c *Cache) Len() int {
 	if c.cache == nil {
 		return 0
@@ -127,15 +1134,111 @@ func (c *Cache) Len() int {
 	return c.ll.Len()
 }
 
-// Clear purges all stored items from the cache.
+// Bytes returns the total size of the items in the cache, as measured
+// by SizeFunc, including expired items not removed yet.
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+// Clear purges all stored items from the cache, and forgets the keys
+// evicted.
 // 清空缓存。
 func (c *Cache) Clear() {
-	if c.OnEvicted != nil {
+	c.removals.Add(int64(c.Len()))
+	if c.OnEvicted != nil || c.OnEvictedReason != nil || c.Observer != nil {
 		for _, e := range c.cache {
-			kv := e.Value.(*entry)
-			c.OnEvicted(kv.key, kv.value)
+			c.evicted(e.Value.(*entry), EvictCleared)
 		}
 	}
 	c.ll = nil
 	c.cache = nil
+	c.nbytes = 0
+	c.vbytes = 0
+	c.ghostLL = nil
+	c.ghosts = nil
+	c.expiry = nil
+	c.ntier = [3]int{}
+	c.storeSize()
+}
+
+// Clone returns an independent copy of the cache, with the same items,
+// recency, expirations, priorities and pins. If copyValue is not nil,
+// the clone holds copyValue(value) instead of each value. The clone has the
+// cache's limits, SizeFunc and KeyFunc, but no eviction callbacks or
+// remembered evicted keys, and its Stats start from zero.
+func (c *Cache) Clone(copyValue func(value interface{}) interface{}) *Cache {
+	d := &Cache{
+		MaxEntries:      c.MaxEntries,
+		MaxBytes:        c.MaxBytes,
+		SizeFunc:        c.SizeFunc,
+		KeyFunc:         c.KeyFunc,
+		Compressor:      c.Compressor,
+		CompressAbove:   c.CompressAbove,
+		Mode:            c.Mode,
+		MaxAge:          c.MaxAge,
+		NegativeTTL:     c.NegativeTTL,
+		JanitorInterval: c.JanitorInterval,
+		OverflowPolicy:  c.OverflowPolicy,
+		LowWatermark:    c.LowWatermark,
+		PromoteEvery:    c.PromoteEvery,
+		PromoteInterval: c.PromoteInterval,
+		ll:              list.New(),
+		cache:           make(map[interface{}]*list.Element, c.Len()),
+		nbytes:          c.nbytes,
+		vbytes:          c.vbytes,
+		ntier:           c.ntier,
+	}
+	for e := c.oldestElement(); e != nil; e = e.Prev() {
+		kv := *e.Value.(*entry)
+		// 压缩后的值和Negative不会被用户修改，不用复制。
+		if _, ok := kv.value.(compressed); !ok && kv.value != Negative && copyValue != nil {
+			kv.value = copyValue(kv.value)
+		}
+		kv.hidx = 0
+		d.setExpires(&kv, kv.expires)
+		d.cache[kv.key] = d.ll.PushFront(&kv)
+	}
+	d.storeSize()
+	return d
+}
+
+// Stats are the counters of a Cache's operations.
+type Stats struct {
+	Hits, Misses  int64 // Gets that found an unexpired entry, or not
+	Adds, Updates int64 // Adds of new keys, and of cached keys
+	Evictions     int64 // entries removed to make room or expired
+	Removals      int64 // entries removed by Remove or Clear
+	Rejected      int64 // Adds of new keys refused by RejectNew
+}
+
+// Stats returns the counters of the cache's operations. Unlike the
+// other methods, it may be called concurrently with them.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Adds:      c.adds.Load(),
+		Updates:   c.updates.Load(),
+		Evictions: c.evictions.Load(),
+		Removals:  c.removals.Load(),
+		Rejected:  c.rejected.Load(),
+	}
+}
+
+// HitRate returns the fraction of Gets that were hits.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Adds += o.Adds
+	s.Updates += o.Updates
+	s.Evictions += o.Evictions
+	s.Removals += o.Removals
+	s.Rejected += o.Rejected
 }
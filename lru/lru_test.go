@@ -18,7 +18,10 @@ package lru
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type simpleStruct struct {
@@ -95,3 +98,994 @@ func TestEvict(t *testing.T) {
 		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey1")
 	}
 }
+
+func TestMaxBytes(t *testing.T) {
+	var evicted []Key
+	lru := &Cache{
+		MaxBytes: 100,
+		SizeFunc: func(key Key, value interface{}) int64 { return int64(len(value.(string))) },
+		OnEvicted: func(key Key, value interface{}) {
+			evicted = append(evicted, key)
+		},
+	}
+	lru.Add("a", strings.Repeat("x", 40))
+	lru.Add("b", strings.Repeat("x", 40))
+	lru.Get("a")
+	lru.Add("c", strings.Repeat("x", 30))
+	if got, want := fmt.Sprint(evicted), "[b]"; got != want {
+		t.Fatalf("evicted %s; want %s", got, want)
+	}
+	if got := lru.Bytes(); got != 70 {
+		t.Fatalf("Bytes = %d; want 70", got)
+	}
+
+	// Replacing a value accounts for the change in size.
+	lru.Add("c", strings.Repeat("x", 61))
+	if got, want := fmt.Sprint(evicted), "[b a]"; got != want {
+		t.Fatalf("evicted %s; want %s", got, want)
+	}
+	if got := lru.Bytes(); got != 61 {
+		t.Fatalf("Bytes = %d; want 61", got)
+	}
+
+	// An entry larger than MaxBytes is not kept.
+	lru.Add("d", strings.Repeat("x", 101))
+	if lru.Len() != 0 || lru.Bytes() != 0 {
+		t.Fatalf("Len, Bytes = %d, %d; want 0, 0", lru.Len(), lru.Bytes())
+	}
+
+	lru.Add("e", "xyz")
+	lru.Remove("e")
+	if lru.Bytes() != 0 {
+		t.Fatalf("Bytes after Remove = %d; want 0", lru.Bytes())
+	}
+	lru.Add("f", "xyz")
+	lru.Clear()
+	if lru.Bytes() != 0 {
+		t.Fatalf("Bytes after Clear = %d; want 0", lru.Bytes())
+	}
+}
+
+func TestPeek(t *testing.T) {
+	lru := New(2)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	if v, ok := lru.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek(a) = %v, %v; want 1, true", v, ok)
+	}
+	// Peek didn't make a more recent than b.
+	lru.Add("c", 3)
+	if _, ok := lru.Get("a"); ok {
+		t.Error("a not evicted after Peek")
+	}
+	if _, ok := lru.Peek("z"); ok {
+		t.Error("Peek of a missing key hit")
+	}
+	if !lru.Contains("b") || lru.Contains("a") {
+		t.Errorf("Contains(b), Contains(a) = %v, %v; want true, false", lru.Contains("b"), lru.Contains("a"))
+	}
+}
+
+func TestRemoveOldestN(t *testing.T) {
+	var evicted []Key
+	lru := New(0)
+	lru.OnEvicted = func(key Key, _ interface{}) { evicted = append(evicted, key) }
+	for i := 0; i < 5; i++ {
+		lru.Add(i, i)
+	}
+	if n := lru.RemoveOldestN(3); n != 3 {
+		t.Errorf("RemoveOldestN(3) = %d; want 3", n)
+	}
+	if n := lru.RemoveOldestN(3); n != 2 {
+		t.Errorf("RemoveOldestN(3) = %d; want 2", n)
+	}
+	if fmt.Sprint(evicted) != "[0 1 2 3 4]" {
+		t.Errorf("evicted %v; want [0 1 2 3 4]", evicted)
+	}
+}
+
+func TestResize(t *testing.T) {
+	var evicted []Key
+	lru := New(5)
+	lru.OnEvicted = func(key Key, _ interface{}) { evicted = append(evicted, key) }
+	for i := 0; i < 5; i++ {
+		lru.Add(i, i)
+	}
+	lru.Get(0)
+	if n := lru.Resize(3); n != 2 || lru.Len() != 3 {
+		t.Fatalf("Resize(3) = %d, Len = %d; want 2, 3", n, lru.Len())
+	}
+	if fmt.Sprint(evicted) != "[1 2]" {
+		t.Errorf("evicted %v; want [1 2]", evicted)
+	}
+	lru.Add(5, 5)
+	if lru.Len() != 3 {
+		t.Errorf("Len after Add = %d; want 3", lru.Len())
+	}
+	if n := lru.Resize(0); n != 0 {
+		t.Errorf("Resize(0) = %d; want 0", n)
+	}
+	lru.Add(6, 6)
+	if lru.Len() != 4 {
+		t.Errorf("Len without a limit = %d; want 4", lru.Len())
+	}
+}
+
+func TestAddNegative(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := &Cache{
+		MaxAge:      time.Hour,
+		NegativeTTL: time.Minute,
+		MaxBytes:    10,
+		SizeFunc:    func(_ Key, v interface{}) int64 { return int64(len(v.(string))) },
+	}
+	lru.AddNegative("missing")
+	lru.Add("present", "value")
+	if v, ok := lru.Get("missing"); !ok || v != Negative {
+		t.Errorf("Get(missing) = %v, %v; want Negative, true", v, ok)
+	}
+	if lru.Bytes() != 6 {
+		t.Errorf("Bytes = %d; want 6", lru.Bytes())
+	}
+	clock = clock.Add(time.Minute)
+	if _, ok := lru.Get("missing"); ok {
+		t.Error("negative entry outlived NegativeTTL")
+	}
+	if v, ok := lru.Get("present"); !ok || v != "value" {
+		t.Errorf("Get(present) = %v, %v; want value, true", v, ok)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := New(0)
+	inc := func(old interface{}) interface{} { return old.(int) + 1 }
+	if lru.Update("a", inc) {
+		t.Error("Update of a missing key reported true")
+	}
+	lru.AddWithTTL("a", 1, time.Minute)
+	lru.Add("b", 1)
+	if !lru.Update("a", inc) {
+		t.Error("Update(a) = false; want true")
+	}
+	if v, exp, _ := lru.GetWithExpiration("a"); v != 2 || !exp.Equal(clock.Add(time.Minute)) {
+		t.Errorf("after Update: a = %v expiring at %v; want 2 at %v", v, exp, clock.Add(time.Minute))
+	}
+	clock = clock.Add(time.Minute)
+	if lru.Update("a", inc) {
+		t.Error("Update of an expired key reported true")
+	}
+
+	// MaxAge只在添加时生效，Update不改变过期时间。
+	lru = &Cache{}
+	lru.AddWithPriority("p", 1, PriorityHigh)
+	lru.Pin("p")
+	lru.MaxAge = time.Minute
+	lru.Update("p", inc)
+	if info, _ := lru.EntryInfo("p"); !info.Expires.IsZero() || info.Priority != PriorityHigh || !info.Pinned {
+		t.Errorf("after Update: %+v; want no expiry, PriorityHigh, pinned", info)
+	}
+	lru.AddWithTTL("t", 1, time.Second)
+	clock = clock.Add(time.Second / 2)
+	lru.Update("t", inc)
+	lru.Touch("t")
+	if _, exp, _ := lru.GetWithExpiration("t"); !exp.Equal(clock.Add(time.Second)) {
+		t.Errorf("after Update and Touch: t expires at %v; want %v", exp, clock.Add(time.Second))
+	}
+}
+
+func TestAddIfAbsent(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := New(0)
+	if v, added := lru.AddIfAbsent("a", 1); !added || v != nil {
+		t.Errorf("AddIfAbsent(a, 1) = %v, %v; want nil, true", v, added)
+	}
+	if v, added := lru.AddIfAbsent("a", 2); added || v != 1 {
+		t.Errorf("AddIfAbsent(a, 2) = %v, %v; want 1, false", v, added)
+	}
+	lru.AddWithTTL("b", 1, time.Second)
+	clock = clock.Add(time.Second)
+	if _, added := lru.AddIfAbsent("b", 2); !added {
+		t.Error("AddIfAbsent did not replace an expired item")
+	}
+	if v, _ := lru.Get("b"); v != 2 {
+		t.Errorf("Get(b) = %v; want 2", v)
+	}
+}
+
+func TestGetOrAdd(t *testing.T) {
+	lru := New(0)
+	calls := 0
+	loader := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+	for i := 0; i < 2; i++ {
+		if v, err := lru.GetOrAdd("k", loader); v != 1 || err != nil {
+			t.Errorf("GetOrAdd = %v, %v; want 1, nil", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader called %d times; want 1", calls)
+	}
+}
+
+func TestStats(t *testing.T) {
+	lru := New(2)
+	lru.Add("a", 1)
+	lru.Add("a", 2)
+	lru.Add("b", 3)
+	lru.Add("c", 4)
+	lru.Get("a")
+	lru.Get("b")
+	lru.Peek("c")
+	lru.Remove("b")
+	lru.Clear()
+	want := Stats{Hits: 1, Misses: 1, Adds: 3, Updates: 1, Evictions: 1, Removals: 2}
+	if got := lru.Stats(); got != want {
+		t.Errorf("Stats = %+v; want %+v", got, want)
+	}
+	if r := want.HitRate(); r != 0.5 {
+		t.Errorf("HitRate = %v; want 0.5", r)
+	}
+}
+
+func TestEvictReason(t *testing.T) {
+	var clock time.Time
+	setNow(t, &clock)
+	var got []string
+	lru := New(2)
+	lru.OnEvictedReason = func(key Key, value interface{}, reason EvictReason) {
+		got = append(got, fmt.Sprintf("%v=%v:%v", key, value, reason))
+	}
+	lru.Add("a", 1)
+	lru.Add("a", 2)
+	lru.AddWithTTL("b", 3, time.Second)
+	lru.Add("c", 4)
+	lru.Remove("b")
+	lru.AddWithTTL("d", 5, time.Second)
+	clock = clock.Add(time.Second)
+	lru.Get("d")
+	lru.Add("e", 6)
+	lru.Clear()
+	want := "[a=1:replaced a=2:capacity b=3:removed d=5:expired"
+	if s := fmt.Sprint(got); !strings.HasPrefix(s, want) || len(got) != 6 {
+		t.Errorf("evictions = %s; want %s and two cleared", s, want)
+	}
+	if s := EvictReason(9).String(); s != "EvictReason(9)" {
+		t.Errorf("String of an unknown reason = %q", s)
+	}
+}
+
+func TestPin(t *testing.T) {
+	lru := New(2)
+	lru.Add("config", 1)
+	if !lru.Pin("config") || lru.Pin("missing") {
+		t.Fatal("Pin reported the wrong keys as cached")
+	}
+	for i := 0; i < 5; i++ {
+		lru.Add(i, i)
+	}
+	if !lru.Contains("config") {
+		t.Fatal("pinned item evicted")
+	}
+	if k, _, _ := lru.PeekOldest(); k != 4 {
+		t.Errorf("PeekOldest = %v; want 4", k)
+	}
+
+	// When every item is pinned, the cache grows beyond its limit.
+	lru.Pin(4)
+	lru.Add(5, 5)
+	lru.Pin(5)
+	lru.Add(6, 6)
+	lru.Pin(6)
+	if lru.Len() != 4 || lru.RemoveOldestN(4) != 0 {
+		t.Errorf("Len = %d; want 4 pinned items", lru.Len())
+	}
+	lru.Unpin("config")
+	lru.RemoveOldest()
+	if lru.Contains("config") {
+		t.Error("unpinned item not evicted")
+	}
+}
+
+func TestPriority(t *testing.T) {
+	var evicted []Key
+	lru := New(3)
+	lru.OnEvicted = func(key Key, _ interface{}) { evicted = append(evicted, key) }
+	lru.AddWithPriority("high", 1, PriorityHigh)
+	lru.Add("normal", 2)
+	lru.AddWithPriority("low", 3, PriorityLow)
+	lru.Get("low")
+	for i := 0; i < 3; i++ {
+		lru.Add(i, i)
+	}
+	if got := fmt.Sprint(evicted); got != "[low normal 0]" {
+		t.Errorf("evicted %s; want [low normal 0]", got)
+	}
+
+	// Replacing a value sets its priority.
+	lru.Add("high", 4)
+	lru.AddWithPriority(1, 1, PriorityHigh)
+	lru.AddWithPriority(2, 2, PriorityHigh)
+	lru.Add(3, 3)
+	if lru.Contains("high") {
+		t.Error("high kept after Add lowered its priority")
+	}
+}
+
+func TestClone(t *testing.T) {
+	lru := New(3)
+	lru.Add("a", []byte("A"))
+	lru.Add("b", []byte("B"))
+	lru.Pin("b")
+	lru.Get("a")
+	clone := lru.Clone(func(v interface{}) interface{} {
+		return append([]byte(nil), v.([]byte)...)
+	})
+	if got := fmt.Sprint(clone.Keys()); got != "[a b]" {
+		t.Errorf("clone Keys = %s; want [a b]", got)
+	}
+	v, _ := lru.Peek("a")
+	v.([]byte)[0] = 'X'
+	if cv, _ := clone.Peek("a"); string(cv.([]byte)) != "A" {
+		t.Errorf("clone shares its values: %s", cv)
+	}
+
+	// The caches change independently.
+	clone.Add("c", nil)
+	clone.Add("d", nil)
+	if lru.Len() != 2 || clone.Len() != 3 {
+		t.Errorf("Len = %d, clone Len = %d; want 2, 3", lru.Len(), clone.Len())
+	}
+	if !clone.Contains("b") {
+		t.Error("clone lost the pin of b")
+	}
+}
+
+func TestOnAddedUpdated(t *testing.T) {
+	var events []string
+	lru := New(1)
+	lru.OnAdded = func(key Key, value interface{}) {
+		events = append(events, fmt.Sprintf("add %v=%v", key, value))
+	}
+	lru.OnUpdated = func(key Key, old, new interface{}) {
+		events = append(events, fmt.Sprintf("update %v=%v->%v", key, old, new))
+	}
+	lru.OnEvicted = func(key Key, value interface{}) {
+		events = append(events, fmt.Sprintf("evict %v", key))
+	}
+	lru.Add("a", 1)
+	lru.AddWithTTL("a", 2, time.Minute)
+	lru.Add("b", 3)
+	want := "[add a=1 update a=1->2 add b=3 evict a]"
+	if got := fmt.Sprint(events); got != want {
+		t.Errorf("events %s; want %s", got, want)
+	}
+}
+
+func TestSizeBytes(t *testing.T) {
+	lru := New(0)
+	lru.Add("a", "12345")
+	lru.Add("b", []byte("123"))
+	lru.Add("c", 42)
+	if n := lru.SizeBytes(); n != 8 {
+		t.Errorf("SizeBytes = %d; want 8", n)
+	}
+	lru.Add("a", "1")
+	lru.Remove("b")
+	if n := lru.SizeBytes(); n != 1 {
+		t.Errorf("SizeBytes = %d; want 1", n)
+	}
+
+	sized := &Cache{SizeFunc: func(Key, interface{}) int64 { return 10 }}
+	sized.Add("a", "1")
+	if n := sized.SizeBytes(); n != 10 {
+		t.Errorf("SizeBytes with a SizeFunc = %d; want 10", n)
+	}
+	sized.Clear()
+	if n := sized.SizeBytes(); n != 0 {
+		t.Errorf("SizeBytes after Clear = %d; want 0", n)
+	}
+}
+
+type mapStore map[Key]interface{}
+
+func (m mapStore) Put(key Key, value interface{}) { m[key] = value }
+func (m mapStore) Delete(key Key)                 { delete(m, key) }
+func (m mapStore) Get(key Key) (interface{}, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+func TestOverflow(t *testing.T) {
+	store := mapStore{}
+	lru := &Cache{MaxEntries: 2, Overflow: store}
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	if v, ok := store["a"]; !ok || v != 1 {
+		t.Fatalf("overflow holds %v; want a=1", store)
+	}
+	// A miss moves the value back, overflowing b.
+	if v, ok := lru.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	if _, ok := store["a"]; ok || len(store) != 1 || store["b"] != 2 {
+		t.Errorf("overflow holds %v; want only b=2", store)
+	}
+	lru.Remove("b")
+	if len(store) != 0 {
+		t.Errorf("overflow holds %v after Remove; want nothing", store)
+	}
+	if _, ok := lru.Get("b"); ok {
+		t.Error("Get(b) hit after Remove")
+	}
+}
+
+func TestOverflowNotReadmitted(t *testing.T) {
+	store := mapStore{}
+	lru := &Cache{MaxEntries: 1, Overflow: store, OverflowPolicy: RejectNew}
+	store["a"] = 1
+	lru.Add("b", 2)
+	// a doesn't fit back, so it must stay in the overflow store.
+	if v, exp, ok := lru.GetWithExpiration("a"); !ok || v != 1 || !exp.IsZero() {
+		t.Errorf("GetWithExpiration(a) = %v, %v, %v; want 1, zero, true", v, exp, ok)
+	}
+	if store["a"] != 1 {
+		t.Errorf("overflow holds %v; want a=1 kept", store)
+	}
+
+	store = mapStore{"big": "0123456789"}
+	lru = &Cache{
+		MaxBytes: 5,
+		SizeFunc: func(_ Key, v interface{}) int64 { return int64(len(v.(string))) },
+		Overflow: store,
+	}
+	if v, ok := lru.Get("big"); !ok || v != "0123456789" {
+		t.Errorf("Get(big) = %v, %v; want 0123456789, true", v, ok)
+	}
+	if _, _, ok := lru.GetWithExpiration("big"); !ok {
+		t.Error("GetWithExpiration(big) missed")
+	}
+	if store["big"] != "0123456789" {
+		t.Errorf("overflow holds %v; want big kept", store)
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	var evicted []Key
+	lru := NewWithOptions(Options{
+		MaxEntries: 2,
+		MaxBytes:   10,
+		SizeFunc:   func(Key, interface{}) int64 { return 4 },
+		OnEvicted:  func(key Key, _ interface{}) { evicted = append(evicted, key) },
+		Capacity:   100,
+	})
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	if fmt.Sprint(evicted) != "[a]" || lru.Bytes() != 8 {
+		t.Errorf("evicted %v, Bytes = %d; want [a], 8", evicted, lru.Bytes())
+	}
+}
+
+func BenchmarkFill(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		new  func() *Cache
+	}{
+		{"New", func() *Cache { return New(10000) }},
+		{"NewWithOptions", func() *Cache { return NewWithOptions(Options{MaxEntries: 10000}) }},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				lru := bb.new()
+				for k := 0; k < 10000; k++ {
+					lru.Add(k, nil)
+				}
+			}
+		})
+	}
+}
+
+type logObserver []string
+
+func (o *logObserver) OnGet(key Key, hit bool, latency time.Duration) {
+	*o = append(*o, fmt.Sprintf("get %v %v %v", key, hit, latency))
+}
+
+func (o *logObserver) OnAdd(key Key, latency time.Duration) {
+	*o = append(*o, fmt.Sprintf("add %v %v", key, latency))
+}
+
+func (o *logObserver) OnEvict(key Key, reason EvictReason) {
+	*o = append(*o, fmt.Sprintf("evict %v %v", key, reason))
+}
+
+func TestObserver(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	var o logObserver
+	lru := &Cache{MaxEntries: 1, Observer: &o}
+	lru.OnAdded = func(Key, interface{}) { clock = clock.Add(time.Millisecond) }
+	lru.Add("a", 1)
+	lru.Add("a", 2)
+	lru.Add("b", 3)
+	lru.Get("a")
+	lru.Get("b")
+	lru.Clear()
+	want := "[add a 1ms evict a replaced add a 0s evict a capacity add b 1ms get a false 0s get b true 0s evict b cleared]"
+	if got := fmt.Sprint(o); got != want {
+		t.Errorf("observed %s;\nwant %s", got, want)
+	}
+}
+
+func TestWasRecentlyEvicted(t *testing.T) {
+	lru := NewWithOptions(Options{MaxEntries: 1, GhostEntries: 2})
+	for _, k := range []string{"a", "b", "c", "d"} {
+		lru.Add(k, nil)
+	}
+	for k, want := range map[string]bool{"a": false, "b": true, "c": true, "d": false} {
+		if got := lru.WasRecentlyEvicted(k); got != want {
+			t.Errorf("WasRecentlyEvicted(%s) = %v; want %v", k, got, want)
+		}
+	}
+	lru.Add("b", nil)
+	lru.Remove("b")
+	if lru.WasRecentlyEvicted("b") || !lru.WasRecentlyEvicted("d") {
+		t.Error("re-added key still remembered, or removed key not remembered")
+	}
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	var maxLen int
+	lru := &Cache{MaxEntries: 2, OverflowPolicy: EvictThenInsert}
+	lru.OnEvicted = func(Key, interface{}) { maxLen = max(maxLen, lru.Len()) }
+	lru.OnAdded = func(Key, interface{}) { maxLen = max(maxLen, lru.Len()) }
+	for i := 0; i < 5; i++ {
+		lru.Add(i, i)
+	}
+	if maxLen > 2 {
+		t.Errorf("EvictThenInsert: Len reached %d; want at most 2", maxLen)
+	}
+	if got := fmt.Sprint(lru.Keys()); got != "[4 3]" {
+		t.Errorf("EvictThenInsert: Keys = %s; want [4 3]", got)
+	}
+
+	lru = &Cache{MaxEntries: 2, OverflowPolicy: RejectNew}
+	for i := 0; i < 5; i++ {
+		lru.Add(i, i)
+	}
+	lru.Add(0, "replaced")
+	if got := fmt.Sprint(lru.Keys()); got != "[0 1]" {
+		t.Errorf("RejectNew: Keys = %s; want [0 1]", got)
+	}
+	if v, _ := lru.Get(0); v != "replaced" {
+		t.Errorf("RejectNew: Get(0) = %v; want replaced", v)
+	}
+	if st := lru.Stats(); st.Rejected != 3 || st.Evictions != 0 {
+		t.Errorf("RejectNew: Rejected = %d, Evictions = %d; want 3, 0", st.Rejected, st.Evictions)
+	}
+}
+
+func TestLazyPromotion(t *testing.T) {
+	lru := NewWithOptions(Options{PromoteEvery: 3})
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Get("a")
+	lru.Get("a")
+	if got := fmt.Sprint(lru.Keys()); got != "[b a]" {
+		t.Errorf("after 2 hits: Keys = %s; want [b a]", got)
+	}
+	lru.Get("a")
+	if got := fmt.Sprint(lru.Keys()); got != "[a b]" {
+		t.Errorf("after 3 hits: Keys = %s; want [a b]", got)
+	}
+
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru = &Cache{PromoteInterval: time.Second}
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Get("a")
+	if got := fmt.Sprint(lru.Keys()); got != "[b a]" {
+		t.Errorf("before PromoteInterval: Keys = %s; want [b a]", got)
+	}
+	clock = clock.Add(time.Second)
+	lru.Get("a")
+	if got := fmt.Sprint(lru.Keys()); got != "[a b]" {
+		t.Errorf("after PromoteInterval: Keys = %s; want [a b]", got)
+	}
+}
+
+func TestLowWatermark(t *testing.T) {
+	var evicted []Key
+	lru := NewWithOptions(Options{MaxEntries: 10, LowWatermark: 0.7, OnEvicted: func(key Key, _ interface{}) {
+		evicted = append(evicted, key)
+	}})
+	for i := 0; i < 10; i++ {
+		lru.Add(i, i)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("evicted %v before reaching MaxEntries", evicted)
+	}
+	// 超过上限后一次淘汰到70%。
+	lru.Add(10, 10)
+	if fmt.Sprint(evicted) != "[0 1 2 3]" || lru.Len() != 7 {
+		t.Errorf("evicted %v, Len %d; want [0 1 2 3], 7", evicted, lru.Len())
+	}
+	for i := 11; i < 14; i++ {
+		lru.Add(i, i)
+	}
+	if len(evicted) != 4 {
+		t.Errorf("evicted %v; want no more evictions below MaxEntries", evicted)
+	}
+
+	lru = &Cache{MaxBytes: 10, LowWatermark: 0.5}
+	for i := 0; i < 11; i++ {
+		lru.Add(i, i)
+	}
+	if lru.Bytes() != 5 {
+		t.Errorf("Bytes = %d; want 5", lru.Bytes())
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	lru := NewWithOptions(Options{KeyFunc: func(key Key) Key {
+		return strings.ToLower(key.(string))
+	}})
+	lru.Add("Foo", 1)
+	lru.AddWithTTL("FOO", 2, time.Hour)
+	if lru.Len() != 1 {
+		t.Errorf("Len = %d; want 1", lru.Len())
+	}
+	if v, ok := lru.Get("fOo"); !ok || v != 2 {
+		t.Errorf("Get(fOo) = %v, %v; want 2, true", v, ok)
+	}
+	if got := fmt.Sprint(lru.Keys()); got != "[foo]" {
+		t.Errorf("Keys = %s; want [foo]", got)
+	}
+	lru.Remove("FOO")
+	if lru.Contains("foo") {
+		t.Error("Remove(FOO) did not remove foo")
+	}
+}
+
+func TestNewWithWarmup(t *testing.T) {
+	lru := NewWithWarmup(2, func(add func(Key, interface{})) {
+		for i, k := range []string{"a", "b", "c"} {
+			add(k, i)
+		}
+	})
+	if got := fmt.Sprint(lru.Keys()); got != "[c b]" {
+		t.Errorf("Keys = %s; want [c b]", got)
+	}
+}
+
+func TestFIFO(t *testing.T) {
+	var evicted []Key
+	lru := NewWithOptions(Options{MaxEntries: 2, Mode: FIFO, OnEvicted: func(key Key, _ interface{}) {
+		evicted = append(evicted, key)
+	}})
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Get("a")
+	lru.Touch("a")
+	lru.Add("a", 3)
+	lru.Add("c", 4)
+	if fmt.Sprint(evicted) != "[a]" {
+		t.Errorf("evicted %v; want [a]", evicted)
+	}
+	if got := fmt.Sprint(lru.Keys()); got != "[c b]" {
+		t.Errorf("Keys = %s; want [c b]", got)
+	}
+}
+
+func TestMRU(t *testing.T) {
+	var evicted []Key
+	lru := NewWithOptions(Options{MaxEntries: 3, Mode: MRU, OnEvicted: func(key Key, _ interface{}) {
+		evicted = append(evicted, key)
+	}})
+	// 循环扫描时，MRU保留了前面的缓存。
+	for i := 0; i < 2; i++ {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			lru.Get(k)
+			lru.Add(k, 1)
+		}
+	}
+	if got := fmt.Sprint(evicted); got != "[c b]" {
+		t.Errorf("evicted %s; want [c b]", got)
+	}
+	if got := fmt.Sprint(lru.Keys()); got != "[d c a]" {
+		t.Errorf("Keys = %s; want [d c a]", got)
+	}
+	if k, _, _ := lru.PeekOldest(); k != "d" {
+		t.Errorf("PeekOldest = %v; want d", k)
+	}
+}
+
+func TestPeekOldest(t *testing.T) {
+	lru := New(0)
+	if _, _, ok := lru.PeekOldest(); ok {
+		t.Error("PeekOldest of an empty cache succeeded")
+	}
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Get("a")
+	if k, v, ok := lru.PeekOldest(); k != "b" || v != 2 || !ok {
+		t.Errorf("PeekOldest = %v, %v, %v; want b, 2, true", k, v, ok)
+	}
+	if lru.Len() != 2 {
+		t.Errorf("Len = %d after PeekOldest; want 2", lru.Len())
+	}
+}
+
+func TestRange(t *testing.T) {
+	lru := New(0)
+	for i := 0; i < 3; i++ {
+		lru.Add(i, i*10)
+	}
+	lru.Get(0)
+	var keys []Key
+	lru.Range(func(key Key, value interface{}) bool {
+		if value.(int) != key.(int)*10 {
+			t.Errorf("Range: key %v has value %v", key, value)
+		}
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if fmt.Sprint(keys) != "[0 2]" {
+		t.Errorf("Range visited %v; want [0 2]", keys)
+	}
+
+	keys = nil
+	lru.RangeOldest(func(key Key, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if fmt.Sprint(keys) != "[1 2 0]" {
+		t.Errorf("RangeOldest visited %v; want [1 2 0]", keys)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	lru := New(0)
+	if keys := lru.Keys(); len(keys) != 0 {
+		t.Errorf("Keys of an empty cache = %v; want none", keys)
+	}
+	for i := 0; i < 3; i++ {
+		lru.Add(i, i)
+	}
+	lru.Get(1)
+	if got := fmt.Sprint(lru.Keys()); got != "[1 2 0]" {
+		t.Errorf("Keys = %s; want [1 2 0]", got)
+	}
+}
+
+// setNow makes the package's clock return *clock for the test's
+// duration.
+func setNow(t *testing.T, clock *time.Time) {
+	now = func() time.Time { return *clock }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestTTL(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	var evicted []Key
+	lru := New(0)
+	lru.OnEvicted = func(key Key, _ interface{}) { evicted = append(evicted, key) }
+	lru.AddWithTTL("short", 1, time.Second)
+	lru.AddWithTTL("long", 2, time.Minute)
+	lru.Add("forever", 3)
+
+	clock = clock.Add(2 * time.Second)
+	if _, ok := lru.Get("short"); ok {
+		t.Error("Get of an expired entry hit")
+	}
+	if v, ok := lru.Get("long"); !ok || v != 2 {
+		t.Errorf("Get(long) = %v, %v; want 2, true", v, ok)
+	}
+	if fmt.Sprint(evicted) != "[short]" || lru.Len() != 2 {
+		t.Errorf("evicted %v, Len %d; want [short], 2", evicted, lru.Len())
+	}
+
+	// Add without a TTL makes the entry permanent.
+	lru.Add("long", 4)
+	lru.AddWithTTL("other", 5, time.Second)
+	clock = clock.Add(time.Hour)
+	if n := lru.RemoveExpired(); n != 1 {
+		t.Errorf("RemoveExpired = %d; want 1", n)
+	}
+	var keys []Key
+	lru.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if fmt.Sprint(keys) != "[long forever]" {
+		t.Errorf("keys after RemoveExpired = %v; want [long forever]", keys)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := &Cache{MaxAge: time.Minute}
+	lru.Add("a", 1)
+	lru.AddWithTTL("b", 2, time.Hour)
+	clock = clock.Add(30 * time.Second)
+	lru.Add("c", 3)
+	// Touch不会延长MaxAge。
+	lru.Touch("a")
+	clock = clock.Add(30 * time.Second)
+	if _, ok := lru.Get("a"); ok {
+		t.Error("a did not expire after MaxAge")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, ok := lru.Get(k); !ok {
+			t.Errorf("%s expired early", k)
+		}
+	}
+}
+
+func TestRemoveExpiredOrder(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := New(0)
+	for i := 1; i <= 10; i++ {
+		lru.AddWithTTL(i, i, time.Duration(11-i)*time.Second)
+	}
+	lru.Add(1, 1)  // 不再过期
+	lru.Remove(10) // 从堆中删除
+	lru.AddWithTTL(2, 2, 20*time.Second)
+	clock = clock.Add(4 * time.Second)
+	if n := lru.RemoveExpired(); n != 3 {
+		t.Errorf("RemoveExpired = %d; want 3", n)
+	}
+	if got := fmt.Sprint(lru.Keys()); got != "[2 1 6 5 4 3]" {
+		t.Errorf("Keys = %s; want [2 1 6 5 4 3]", got)
+	}
+	clock = clock.Add(time.Hour)
+	if n := lru.RemoveExpired(); n != 5 || lru.Len() != 1 {
+		t.Errorf("RemoveExpired = %d, Len = %d; want 5, 1", n, lru.Len())
+	}
+}
+
+func TestGetWithExpiration(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := New(0)
+	lru.AddWithTTL("a", 1, time.Minute)
+	lru.Add("b", 2)
+	if v, exp, ok := lru.GetWithExpiration("a"); v != 1 || !exp.Equal(clock.Add(time.Minute)) || !ok {
+		t.Errorf("GetWithExpiration(a) = %v, %v, %v; want 1, %v, true", v, exp, ok, clock.Add(time.Minute))
+	}
+	if v, exp, ok := lru.GetWithExpiration("b"); v != 2 || !exp.IsZero() || !ok {
+		t.Errorf("GetWithExpiration(b) = %v, %v, %v; want 2, zero time, true", v, exp, ok)
+	}
+	if _, _, ok := lru.GetWithExpiration("c"); ok {
+		t.Error("GetWithExpiration of a missing key hit")
+	}
+}
+
+func TestEntryInfo(t *testing.T) {
+	start := time.Unix(1e9, 0)
+	clock := start
+	setNow(t, &clock)
+	lru := New(0)
+	lru.AddWithPriority("a", 1, PriorityHigh)
+	clock = clock.Add(time.Second)
+	lru.Get("a")
+	lru.Get("a")
+	clock = clock.Add(time.Second)
+	lru.Add("a", 2)
+	lru.Pin("a")
+	info, ok := lru.EntryInfo("a")
+	want := EntryInfo{Created: start, LastAccess: start.Add(time.Second), Hits: 2, Size: 1, Pinned: true}
+	if !ok || info != want {
+		t.Errorf("EntryInfo(a) = %+v, %v; want %+v, true", info, ok, want)
+	}
+	if _, ok := lru.EntryInfo("b"); ok {
+		t.Error("EntryInfo of a missing key succeeded")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := New(2)
+	lru.AddWithTTL("a", 1, 2*time.Second)
+	lru.Add("b", 2)
+	clock = clock.Add(time.Second)
+	if !lru.Touch("a") || lru.Touch("c") {
+		t.Fatal("Touch reported the wrong keys as cached")
+	}
+	// a is now the most recently used, and expires 2s from now.
+	lru.Add("c", 3)
+	if lru.Contains("b") {
+		t.Error("b kept; Touch did not mark a as used")
+	}
+	clock = clock.Add(1500 * time.Millisecond)
+	if !lru.Contains("a") {
+		t.Error("a expired; Touch did not restart its TTL")
+	}
+	clock = clock.Add(time.Second)
+	if lru.Touch("a") {
+		t.Error("Touch of an expired item succeeded")
+	}
+	if lru.Len() != 1 {
+		t.Errorf("Len = %d; want the expired item removed", lru.Len())
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	var mu sync.Mutex
+	lru := New(0)
+	lru.AddWithTTL("k", 1, time.Millisecond)
+	stop := lru.StartJanitor(5*time.Millisecond, &mu)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := lru.Len()
+		mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("janitor didn't remove the expired entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop()
+}
+
+func TestPurgeExpired(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := New(0)
+	lru.AddWithTTL("a", 1, time.Second)
+	lru.AddWithTTL("b", 2, time.Minute)
+	lru.Add("c", 3)
+	clock = clock.Add(time.Second)
+	if n := lru.PurgeExpired(); n != 1 || lru.Len() != 2 {
+		t.Errorf("PurgeExpired = %d, Len = %d; want 1, 2", n, lru.Len())
+	}
+}
+
+func TestJanitorInterval(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	lru := &Cache{JanitorInterval: time.Minute}
+	lru.AddWithTTL("a", 1, time.Second)
+	lru.AddWithTTL("b", 2, time.Second)
+	lru.Add("c", 3)
+	clock = clock.Add(time.Second)
+	// 间隔未到时，过期的缓存还在。
+	lru.Get("c")
+	if lru.Len() != 3 {
+		t.Errorf("Len = %d before JanitorInterval; want 3", lru.Len())
+	}
+	clock = clock.Add(time.Minute)
+	lru.Get("c")
+	if lru.Len() != 1 {
+		t.Errorf("Len = %d after JanitorInterval; want 1", lru.Len())
+	}
+	if st := lru.Stats(); st.Evictions != 2 {
+		t.Errorf("Evictions = %d; want 2", st.Evictions)
+	}
+}
+
+func BenchmarkAddEvict(b *testing.B) {
+	lru := New(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lru.Add(i, i)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "math/rand"
+
+// defaultSamples is the default number of entries a Sampled cache
+// compares to pick one to evict, as in Redis.
+const defaultSamples = 5
+
+// Sampled is an approximate LRU cache, as in Redis: Get only records
+// the time of the access, and eviction samples a few random entries
+// and evicts the one accessed least recently. Hits are cheaper than in
+// Cache and the entries take less memory, at the cost of sometimes
+// evicting an entry that is not the oldest. Like Cache it is not safe
+// for concurrent access, and its zero value is ready to use.
+type Sampled struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	MaxEntries int
+
+	// Samples is the number of entries compared to pick one to
+	// evict. More samples approximate LRU better. If zero, it
+	// defaults to 5.
+	Samples int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	entries []sampledEntry
+	cache   map[interface{}]int // key to index in entries
+	clock   uint64              // 逻辑时钟，每次访问加一
+	rand    *rand.Rand
+}
+
+var _ Interface = (*Sampled)(nil)
+
+type sampledEntry struct {
+	key    Key
+	value  interface{}
+	access uint64 // clock at the last access
+}
+
+func (c *Sampled) tick() uint64 {
+	c.clock++
+	return c.clock
+}
+
+// Add adds a value to the cache.
+func (c *Sampled) Add(key Key, value interface{}) {
+	if c.cache == nil {
+		c.cache = make(map[interface{}]int)
+	}
+	if i, ok := c.cache[key]; ok {
+		c.entries[i].value = value
+		c.entries[i].access = c.tick()
+		return
+	}
+	if c.MaxEntries != 0 && len(c.entries) >= c.MaxEntries {
+		c.RemoveOldest()
+	}
+	c.cache[key] = len(c.entries)
+	c.entries = append(c.entries, sampledEntry{key, value, c.tick()})
+}
+
+// Get looks up a key's value from the cache.
+func (c *Sampled) Get(key Key) (value interface{}, ok bool) {
+	i, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	c.entries[i].access = c.tick()
+	return c.entries[i].value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *Sampled) Remove(key Key) {
+	if i, hit := c.cache[key]; hit {
+		c.remove(i)
+	}
+}
+
+// RemoveOldest removes the least recently used of Samples random
+// items, or the least recently used item if there are no more.
+func (c *Sampled) RemoveOldest() {
+	n := len(c.entries)
+	if n == 0 {
+		return
+	}
+	k := c.Samples
+	if k <= 0 {
+		k = defaultSamples
+	}
+	victim := 0
+	if k >= n {
+		for i := range c.entries {
+			if c.entries[i].access < c.entries[victim].access {
+				victim = i
+			}
+		}
+	} else {
+		if c.rand == nil {
+			c.rand = rand.New(rand.NewSource(rand.Int63()))
+		}
+		victim = c.rand.Intn(n)
+		for j := 1; j < k; j++ {
+			if i := c.rand.Intn(n); c.entries[i].access < c.entries[victim].access {
+				victim = i
+			}
+		}
+	}
+	c.remove(victim)
+}
+
+// remove removes entries[i], moving the last entry in its place.
+func (c *Sampled) remove(i int) {
+	e := c.entries[i]
+	last := len(c.entries) - 1
+	c.entries[i] = c.entries[last]
+	c.cache[c.entries[i].key] = i
+	c.entries[last] = sampledEntry{}
+	c.entries = c.entries[:last]
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *Sampled) Len() int {
+	return len(c.entries)
+}
+
+// Clear purges all stored items from the cache.
+func (c *Sampled) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.entries {
+			c.OnEvicted(e.key, e.value)
+		}
+	}
+	c.entries = nil
+	c.cache = nil
+}
+
+// Range calls f for each item in the cache, in no particular order,
+// until f returns false. It does not record accesses; f must not
+// modify the cache.
+func (c *Sampled) Range(f func(key Key, value interface{}) bool) {
+	for _, e := range c.entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
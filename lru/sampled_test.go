@@ -0,0 +1,79 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSampled(t *testing.T) {
+	var evicted []Key
+	// With as many samples as entries, eviction is exact LRU.
+	c := &Sampled{MaxEntries: 3, Samples: 3, OnEvicted: func(key Key, _ interface{}) {
+		evicted = append(evicted, key)
+	}}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	c.Get("a")
+	c.Add("d", 4)
+	c.Add("b", 5)
+	if got := fmt.Sprint(evicted); got != "[b c]" {
+		t.Errorf("evicted %s; want [b c]", got)
+	}
+	if v, ok := c.Get("b"); !ok || v != 5 {
+		t.Errorf("Get(b) = %v, %v; want 5, true", v, ok)
+	}
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 2 {
+		t.Errorf("Len = %d after Remove; want 2", c.Len())
+	}
+	n := 0
+	c.Range(func(Key, interface{}) bool { n++; return true })
+	if n != 2 {
+		t.Errorf("Range visited %d items; want 2", n)
+	}
+	c.Clear()
+	if c.Len() != 0 || len(evicted) != 5 {
+		t.Errorf("Len, evictions = %d, %d; want 0, 5", c.Len(), len(evicted))
+	}
+}
+
+func TestSampledApproximatesLRU(t *testing.T) {
+	c := &Sampled{MaxEntries: 100, rand: rand.New(rand.NewSource(1))}
+	for i := 0; i < 100; i++ {
+		c.Add(i, i)
+	}
+	// Keep the first half recently used while adding new keys.
+	for i := 100; i < 200; i++ {
+		for j := 0; j < 50; j++ {
+			c.Get(j)
+		}
+		c.Add(i, i)
+	}
+	kept := 0
+	for j := 0; j < 50; j++ {
+		if _, ok := c.Get(j); ok {
+			kept++
+		}
+	}
+	if kept < 45 {
+		t.Errorf("kept %d of 50 recently used keys; want at least 45", kept)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// defaultProtectedRatio is the default share of a Segmented cache's
+// entries kept in its protected segment.
+const defaultProtectedRatio = 0.8
+
+// Segmented is a segmented LRU cache. New entries enter a probation
+// segment and move to a protected segment when they are hit again, so
+// that a burst of one-off keys only evicts other one-off keys. Like
+// Cache it is not safe for concurrent access, and its zero value is
+// ready to use.
+type Segmented struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	MaxEntries int
+
+	// ProtectedRatio is the largest share of MaxEntries, or of the
+	// current entries if there is no limit, kept in the protected
+	// segment; entries pushed out of it go back to probation.
+	// If zero, it defaults to 0.8.
+	ProtectedRatio float64
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	probation, protected *list.List
+	cache                map[interface{}]*list.Element
+}
+
+var _ Interface = (*Segmented)(nil)
+
+type segEntry struct {
+	key       Key
+	value     interface{}
+	protected bool
+}
+
+func (c *Segmented) init() {
+	if c.cache == nil {
+		c.cache = make(map[interface{}]*list.Element)
+		c.probation = list.New()
+		c.protected = list.New()
+	}
+}
+
+// Add adds a value to the cache's probation segment, or updates the
+// value of an existing entry.
+func (c *Segmented) Add(key Key, value interface{}) {
+	c.init()
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*segEntry).value = value
+		c.hit(ele)
+		return
+	}
+	c.cache[key] = c.probation.PushFront(&segEntry{key: key, value: value})
+	if c.MaxEntries != 0 && len(c.cache) > c.MaxEntries {
+		c.RemoveOldest()
+	}
+}
+
+// Get looks up a key's value from the cache, protecting it.
+func (c *Segmented) Get(key Key) (value interface{}, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.hit(ele)
+		return ele.Value.(*segEntry).value, true
+	}
+	return
+}
+
+// hit moves ele to the front of the protected segment, demoting the
+// protected segment's oldest entries if it grows too large.
+func (c *Segmented) hit(ele *list.Element) {
+	e := ele.Value.(*segEntry)
+	if e.protected {
+		c.protected.MoveToFront(ele)
+		return
+	}
+	c.probation.Remove(ele)
+	e.protected = true
+	c.cache[e.key] = c.protected.PushFront(e)
+
+	ratio := c.ProtectedRatio
+	if ratio == 0 {
+		ratio = defaultProtectedRatio
+	}
+	size := c.MaxEntries
+	if size == 0 {
+		size = len(c.cache)
+	}
+	limit := int(ratio * float64(size))
+	if limit < 1 {
+		limit = 1
+	}
+	for c.protected.Len() > limit {
+		old := c.protected.Back()
+		c.protected.Remove(old)
+		oe := old.Value.(*segEntry)
+		oe.protected = false
+		c.cache[oe.key] = c.probation.PushFront(oe)
+	}
+}
+
+// Remove removes the provided key from the cache.
+func (c *Segmented) Remove(key Key) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveOldest removes the oldest item of the probation segment, or of
+// the protected segment if probation is empty.
+func (c *Segmented) RemoveOldest() {
+	if c.cache == nil {
+		return
+	}
+	ele := c.probation.Back()
+	if ele == nil {
+		ele = c.protected.Back()
+	}
+	if ele != nil {
+		c.removeElement(ele)
+	}
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or changing its recency.
+func (c *Segmented) PeekOldest() (key Key, value interface{}, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	ele := c.probation.Back()
+	if ele == nil {
+		ele = c.protected.Back()
+	}
+	if ele != nil {
+		e := ele.Value.(*segEntry)
+		return e.key, e.value, true
+	}
+	return
+}
+
+func (c *Segmented) removeElement(ele *list.Element) {
+	e := ele.Value.(*segEntry)
+	if e.protected {
+		c.protected.Remove(ele)
+	} else {
+		c.probation.Remove(ele)
+	}
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *Segmented) Len() int {
+	return len(c.cache)
+}
+
+// Segments returns the number of entries in each segment.
+func (c *Segmented) Segments() (probation, protected int) {
+	if c.cache == nil {
+		return 0, 0
+	}
+	return c.probation.Len(), c.protected.Len()
+}
+
+// Clear purges all stored items from the cache.
+func (c *Segmented) Clear() {
+	if c.OnEvicted != nil {
+		for _, ele := range c.cache {
+			e := ele.Value.(*segEntry)
+			c.OnEvicted(e.key, e.value)
+		}
+	}
+	c.probation = nil
+	c.protected = nil
+	c.cache = nil
+}
+
+// Range calls f for each item in the cache, protected entries first,
+// each segment from the most to the least recently used, until f
+// returns false. f must not modify the cache.
+func (c *Segmented) Range(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for _, l := range []*list.List{c.protected, c.probation} {
+		for ele := l.Front(); ele != nil; ele = ele.Next() {
+			e := ele.Value.(*segEntry)
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
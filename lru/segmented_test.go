@@ -0,0 +1,86 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSegmentedScanResistance(t *testing.T) {
+	c := &Segmented{MaxEntries: 10}
+	for i := 0; i < 5; i++ {
+		c.Add(fmt.Sprint("hot", i), i)
+		c.Get(fmt.Sprint("hot", i))
+	}
+	// A scan of one-off keys only evicts other one-off keys.
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprint("scan", i), i)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(fmt.Sprint("hot", i)); !ok {
+			t.Errorf("hot%d was evicted by a scan", i)
+		}
+	}
+	if c.Len() != 10 {
+		t.Errorf("Len = %d; want 10", c.Len())
+	}
+}
+
+func TestSegmentedEvict(t *testing.T) {
+	var evicted []Key
+	c := &Segmented{OnEvicted: func(key Key, _ interface{}) { evicted = append(evicted, key) }}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	if k, v, ok := c.PeekOldest(); k != "b" || v != 2 || !ok {
+		t.Errorf("PeekOldest = %v, %v, %v; want b, 2, true", k, v, ok)
+	}
+	c.RemoveOldest()
+	c.RemoveOldest()
+	c.RemoveOldest()
+	if fmt.Sprint(evicted) != "[b a]" {
+		t.Errorf("evicted %v; want [b a]", evicted)
+	}
+
+	c.Add("c", 3)
+	c.Remove("c")
+	c.Add("d", 4)
+	c.Clear()
+	if fmt.Sprint(evicted) != "[b a c d]" || c.Len() != 0 {
+		t.Errorf("evicted %v, Len %d; want [b a c d], 0", evicted, c.Len())
+	}
+}
+
+func TestSegmentedDemotion(t *testing.T) {
+	c := &Segmented{ProtectedRatio: 0.5}
+	for i := 0; i < 4; i++ {
+		c.Add(i, i)
+		c.Get(i)
+	}
+	if c.protected.Len() != 2 || c.probation.Len() != 2 {
+		t.Errorf("segments hold %d protected, %d probation; want 2, 2", c.protected.Len(), c.probation.Len())
+	}
+	var keys []Key
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if fmt.Sprint(keys) != "[3 2 1 0]" {
+		t.Errorf("Range visited %v; want [3 2 1 0]", keys)
+	}
+}
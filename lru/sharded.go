@@ -0,0 +1,146 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+// Sharded is a cache that partitions its keys by hash across
+// independent SyncCaches, so that goroutines using different keys
+// rarely wait for the same lock. It is safe for concurrent access.
+// Recency is tracked per shard: RemoveOldest evicts from the fullest
+// shard, and Range visits the shards in turn.
+type Sharded struct {
+	shards []*SyncCache
+}
+
+var _ Interface = (*Sharded)(nil)
+
+// NewSharded returns a Sharded cache of the given number of shards,
+// each holding up to maxEntriesPerShard entries. If maxEntriesPerShard
+// is zero, the shards have no limit.
+func NewSharded(shards, maxEntriesPerShard int) *Sharded {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &Sharded{shards: make([]*SyncCache, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewSync(New(maxEntriesPerShard))
+	}
+	return s
+}
+
+// shard returns the shard holding key.
+func (s *Sharded) shard(key Key) *SyncCache {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	return s.shards[hashKey(key)%uint64(len(s.shards))]
+}
+
+// Add adds a value to the cache.
+func (s *Sharded) Add(key Key, value interface{}) {
+	s.shard(key).Add(key, value)
+}
+
+// Get looks up a key's value from the cache.
+func (s *Sharded) Get(key Key) (value interface{}, ok bool) {
+	return s.shard(key).Get(key)
+}
+
+// Remove removes the provided key from the cache.
+func (s *Sharded) Remove(key Key) {
+	s.shard(key).Remove(key)
+}
+
+// RemoveOldest removes the oldest item of the shard holding the most
+// items.
+func (s *Sharded) RemoveOldest() {
+	if c := s.fullest(); c != nil {
+		c.RemoveOldest()
+	}
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or changing its recency.
+func (s *Sharded) PeekOldest() (key Key, value interface{}, ok bool) {
+	if c := s.fullest(); c != nil {
+		return c.PeekOldest()
+	}
+	return
+}
+
+// fullest returns the shard holding the most items, nil if all are
+// empty.
+func (s *Sharded) fullest() *SyncCache {
+	var fullest *SyncCache
+	max := 0
+	for _, c := range s.shards {
+		if n := c.Len(); n > max {
+			fullest, max = c, n
+		}
+	}
+	return fullest
+}
+
+// Len returns the number of items in the cache.
+func (s *Sharded) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// ShardLens returns the number of items in each shard, to check that
+// keys are spread evenly.
+func (s *Sharded) ShardLens() []int {
+	lens := make([]int, len(s.shards))
+	for i, c := range s.shards {
+		lens[i] = c.Len()
+	}
+	return lens
+}
+
+// Stats returns the counters of the operations of all the shards.
+func (s *Sharded) Stats() Stats {
+	var st Stats
+	for _, c := range s.shards {
+		st.add(c.Stats())
+	}
+	return st
+}
+
+// Clear purges all stored items from the cache.
+func (s *Sharded) Clear() {
+	for _, c := range s.shards {
+		c.Clear()
+	}
+}
+
+// Range calls f for each unexpired item in the cache, shard by shard
+// and from the most to the least recently used within a shard, until
+// f returns false. f must not call the cache.
+func (s *Sharded) Range(f func(key Key, value interface{}) bool) {
+	more := true
+	for _, c := range s.shards {
+		c.Range(func(key Key, value interface{}) bool {
+			more = f(key, value)
+			return more
+		})
+		if !more {
+			return
+		}
+	}
+}
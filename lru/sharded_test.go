@@ -0,0 +1,82 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSharded(t *testing.T) {
+	c := NewSharded(8, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(fmt.Sprintf("key%d-%d", i, j), j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lens := c.ShardLens()
+	total := 0
+	for i, n := range lens {
+		if n == 0 || n > 10 {
+			t.Errorf("shard %d holds %d items; want 1 to 10", i, n)
+		}
+		total += n
+	}
+	if c.Len() != total {
+		t.Errorf("Len = %d; want %d", c.Len(), total)
+	}
+
+	c.Add(42, "answer")
+	if v, ok := c.Get(42); !ok || v != "answer" {
+		t.Errorf("Get(42) = %v, %v; want answer, true", v, ok)
+	}
+	c.Remove(42)
+	if _, ok := c.Get(42); ok {
+		t.Error("Get(42) hit after Remove")
+	}
+
+	n := 0
+	c.Range(func(Key, interface{}) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("Range visited %d items; want 5", n)
+	}
+
+	before := c.Len()
+	k, _, _ := c.PeekOldest()
+	c.RemoveOldest()
+	if _, ok := c.Get(k); ok {
+		t.Errorf("RemoveOldest kept %v, the item PeekOldest returned", k)
+	}
+	if c.Len() != before-1 {
+		t.Errorf("Len after RemoveOldest = %d; want %d", c.Len(), before-1)
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Errorf("Len after Clear = %d; want 0", c.Len())
+	}
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// A Codec converts the keys and values of a Cache to bytes and back,
+// for SaveTo and LoadFrom. Entries added by AddNegative are marshaled
+// with a nil value, which codecs must accept.
+type Codec interface {
+	Marshal(key Key, value interface{}) (k, v []byte, err error)
+	Unmarshal(k, v []byte) (key Key, value interface{}, err error)
+}
+
+// GobCodec is a Codec using encoding/gob. Keys and values of types
+// other than the basic ones must be registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(key Key, value interface{}) (k, v []byte, err error) {
+	ki := interface{}(key)
+	if k, err = gobEncode(&ki); err != nil {
+		return nil, nil, err
+	}
+	v, err = gobEncode(&value)
+	return k, v, err
+}
+
+func (GobCodec) Unmarshal(k, v []byte) (key Key, value interface{}, err error) {
+	var ki interface{}
+	if err = gob.NewDecoder(bytes.NewReader(k)).Decode(&ki); err != nil {
+		return nil, nil, err
+	}
+	key = ki
+	err = gob.NewDecoder(bytes.NewReader(v)).Decode(&value)
+	return key, value, err
+}
+
+func gobEncode(p *interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(p)
+	return b.Bytes(), err
+}
+
+// snapshotMagic starts the snapshots written by SaveTo.
+const snapshotMagic = "lru snapshot 1\n"
+
+// The bits of the flags byte of a snapshot record.
+const (
+	flagPinned   = 1 << iota
+	flagNegative // added by AddNegative; the value is nil
+)
+
+// SaveTo writes the unexpired items of the cache to w, with their
+// recency, expiration, priority and pins, encoding keys and values
+// with codec. LoadFrom reads them back.
+func (c *Cache) SaveTo(w io.Writer, codec Codec) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(snapshotMagic)
+	var err error
+	var buf [binary.MaxVarintLen64]byte
+	writeBytes := func(p []byte) {
+		bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(p)))])
+		bw.Write(p)
+	}
+	// 从最早的缓存开始写，加载时按顺序添加即可恢复链表顺序。
+	t := now()
+	for e := c.oldestElement(); e != nil && err == nil; e = e.Prev() {
+		kv := e.Value.(*entry)
+		if kv.expired(t) {
+			continue
+		}
+		var k, v []byte
+		var value interface{}
+		var flags byte
+		if kv.pinned {
+			flags |= flagPinned
+		}
+		if kv.value == Negative {
+			// 哨兵值无法编码，用标志位记录。
+			flags |= flagNegative
+		} else if value, err = c.unpack(kv.value); err != nil {
+			err = fmt.Errorf("lru: decompressing %v: %v", kv.key, err)
+			break
+		}
+		if k, v, err = codec.Marshal(kv.key, value); err != nil {
+			err = fmt.Errorf("lru: encoding %v: %v", kv.key, err)
+			break
+		}
+		writeBytes(k)
+		writeBytes(v)
+		var expires int64
+		if !kv.expires.IsZero() {
+			expires = kv.expires.UnixNano()
+		}
+		bw.Write(buf[:binary.PutVarint(buf[:], expires)])
+		bw.Write([]byte{byte(kv.prio + 1), flags})
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// oldestElement returns the back of the list, nil for an empty cache.
+func (c *Cache) oldestElement() *list.Element {
+	if c.cache == nil {
+		return nil
+	}
+	return c.ll.Back()
+}
+
+// LoadFrom reads a cache written by SaveTo, decoding keys and values
+// with codec. Items that expired since are skipped. The cache has no
+// limits; set them, or call Resize, before adding to it.
+func LoadFrom(r io.Reader, codec Codec) (*Cache, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, errors.New("lru: not a cache snapshot")
+	}
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		// 长度来自文件，不能直接按它分配，读多少分配多少。
+		p, err := io.ReadAll(io.LimitReader(br, int64(min(n, math.MaxInt64))))
+		if err == nil && uint64(len(p)) < n {
+			err = io.ErrUnexpectedEOF
+		}
+		return p, err
+	}
+	c := New(0)
+	t := now()
+	for {
+		k, err := readBytes()
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lru: reading snapshot: %v", err)
+		}
+		v, err := readBytes()
+		if err != nil {
+			return nil, fmt.Errorf("lru: reading snapshot: %v", io.ErrUnexpectedEOF)
+		}
+		expires, err := binary.ReadVarint(br)
+		var flags [2]byte
+		if err == nil {
+			_, err = io.ReadFull(br, flags[:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lru: reading snapshot: %v", io.ErrUnexpectedEOF)
+		}
+		key, value, err := codec.Unmarshal(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("lru: decoding snapshot: %v", err)
+		}
+		var exp time.Time
+		if expires != 0 {
+			if exp = time.Unix(0, expires); !t.Before(exp) {
+				continue
+			}
+		}
+		prio := Priority(flags[0]) - 1
+		if prio < PriorityLow || prio > PriorityHigh {
+			return nil, fmt.Errorf("lru: bad priority %d in snapshot", prio)
+		}
+		if flags[1]&flagNegative != 0 {
+			value = Negative
+		}
+		c.add(key, value, exp, prio)
+		c.cache[key].Value.(*entry).pinned = flags[1]&flagPinned != 0
+	}
+}
+
+// An Iterator iterates over the items a Cache held when
+// SnapshotIterator was called. It doesn't refer to the cache, and may
+// be used, by a single goroutine, while the cache changes.
+type Iterator struct {
+	items      []iterItem
+	i          int
+	compressor Compressor
+}
+
+type iterItem struct {
+	key   Key
+	value interface{} // as stored, decompressed by Value
+}
+
+// SnapshotIterator returns an Iterator over the unexpired items of the
+// cache, from the most to the least recently used. It only copies the
+// keys and references to the values, so it is quick to take under a
+// lock; values are decompressed by Iterator.Value.
+func (c *Cache) SnapshotIterator() *Iterator {
+	it := &Iterator{i: -1, compressor: c.Compressor}
+	if c.cache == nil {
+		return it
+	}
+	it.items = make([]iterItem, 0, c.ll.Len())
+	t := now()
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		if kv := e.Value.(*entry); !kv.expired(t) {
+			it.items = append(it.items, iterItem{kv.key, kv.value})
+		}
+	}
+	return it
+}
+
+// Next advances to the next item, and reports whether there is one.
+func (it *Iterator) Next() bool {
+	if it.i < len(it.items) {
+		it.i++
+	}
+	return it.i < len(it.items)
+}
+
+// Key returns the key of the current item.
+func (it *Iterator) Key() Key {
+	return it.items[it.i].key
+}
+
+// Value returns the value of the current item, or nil if it doesn't
+// decompress.
+func (it *Iterator) Value() interface{} {
+	v := it.items[it.i].value
+	if z, ok := v.(compressed); ok {
+		b, err := it.compressor.Decompress(z)
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+	return v
+}
+
+// Len returns the number of items of the snapshot.
+func (it *Iterator) Len() int {
+	return len(it.items)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	clock := time.Unix(1e9, 0)
+	setNow(t, &clock)
+	c := New(0)
+	c.Add("a", []byte("A"))
+	c.AddWithTTL("b", 2, time.Minute)
+	c.AddWithTTL("gone", 3, time.Second)
+	c.AddWithPriority(4, "four", PriorityHigh)
+	c.Pin(4)
+	c.Get("a")
+	clock = clock.Add(time.Second)
+
+	var buf bytes.Buffer
+	if err := c.SaveTo(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(30 * time.Second)
+	d, err := LoadFrom(&buf, GobCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(d.Keys()); got != "[a 4 b]" {
+		t.Errorf("Keys = %s; want [a 4 b]", got)
+	}
+	if v, _ := d.Peek("a"); string(v.([]byte)) != "A" {
+		t.Errorf("Peek(a) = %v; want A", v)
+	}
+	// b keeps its expiration, and 4 its priority and pin.
+	clock = clock.Add(30 * time.Second)
+	if d.Contains("b") {
+		t.Error("b did not expire")
+	}
+	if d.RemoveOldest(); !d.Contains(4) {
+		t.Error("pinned item evicted")
+	}
+
+	if _, err := LoadFrom(bytes.NewReader([]byte("junk")), GobCodec{}); err == nil {
+		t.Error("LoadFrom of junk succeeded")
+	}
+	buf.Reset()
+	c.SaveTo(&buf, GobCodec{})
+	if _, err := LoadFrom(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), GobCodec{}); err == nil {
+		t.Error("LoadFrom of a truncated snapshot succeeded")
+	}
+}
+
+func TestSnapshotNegative(t *testing.T) {
+	c := &Cache{NegativeTTL: time.Hour}
+	c.AddNegative("missing")
+	c.Add("present", 1)
+	c.Pin("missing")
+	var buf bytes.Buffer
+	if err := c.SaveTo(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadFrom(&buf, GobCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.Get("missing"); !ok || v != Negative {
+		t.Errorf("Get(missing) = %v, %v; want Negative, true", v, ok)
+	}
+	if info, _ := d.EntryInfo("missing"); !info.Pinned || info.Expires.IsZero() {
+		t.Errorf("missing: %+v; want pinned and expiring", info)
+	}
+	if v, ok := d.Get("present"); !ok || v != 1 {
+		t.Errorf("Get(present) = %v, %v; want 1, true", v, ok)
+	}
+
+	e := c.Clone(func(v interface{}) interface{} { return v.(int) * 10 })
+	if v, _ := e.Get("missing"); v != Negative {
+		t.Errorf("clone: Get(missing) = %v; want Negative", v)
+	}
+	if v, _ := e.Get("present"); v != 10 {
+		t.Errorf("clone: Get(present) = %v; want 10", v)
+	}
+}
+
+func TestLoadFromCorrupt(t *testing.T) {
+	c := New(0)
+	c.Add("a", "A")
+	var buf bytes.Buffer
+	if err := c.SaveTo(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+	var n [binary.MaxVarintLen64]byte
+	huge := string(n[:binary.PutUvarint(n[:], 1<<62)])
+	for name, b := range map[string]string{
+		"truncated":  string(good[:len(good)-3]),
+		"huge key":   snapshotMagic + huge,
+		"huge value": snapshotMagic + "\x01k" + huge + "v",
+		"max length": snapshotMagic + string(n[:binary.PutUvarint(n[:], math.MaxUint64)]),
+	} {
+		if _, err := LoadFrom(strings.NewReader(b), GobCodec{}); err == nil {
+			t.Errorf("LoadFrom of a %s snapshot succeeded", name)
+		}
+	}
+}
+
+func TestSnapshotIterator(t *testing.T) {
+	c := &Cache{Compressor: GzipCompressor{}}
+	c.Add("a", bytes.Repeat([]byte("a"), 100))
+	c.Add("b", 2)
+	it := c.SnapshotIterator()
+	// 快照之后的修改不影响遍历。
+	c.Remove("b")
+	c.Add("c", 3)
+	var got []string
+	for it.Next() {
+		v := it.Value()
+		if b, ok := v.([]byte); ok {
+			v = len(b)
+		}
+		got = append(got, fmt.Sprintf("%v=%v", it.Key(), v))
+	}
+	if fmt.Sprint(got) != "[b=2 a=100]" || it.Len() != 2 {
+		t.Errorf("iterated %v, Len %d; want [b=2 a=100], 2", got, it.Len())
+	}
+	if it.Next() {
+		t.Error("Next after the end = true")
+	}
+}
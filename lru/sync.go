@@ -0,0 +1,384 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// SyncCache is a Cache that is safe for concurrent access. Gets of
+// missing keys only take a read lock, so that misses don't wait for
+// each other; hits take the write lock, since they change the recency
+// of the entry, and so do misses of caches with an Overflow store.
+type SyncCache struct {
+	mu    sync.RWMutex
+	c     *Cache
+	loads map[interface{}]*syncLoad // GetOrAdd的loader，按key去重
+}
+
+// syncLoad is a GetOrAdd loader in progress.
+type syncLoad struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+var _ Interface = (*SyncCache)(nil)
+
+// errLoaderPanic is returned by GetOrAdd to the callers waiting for a
+// loader that panicked.
+var errLoaderPanic = errors.New("lru: GetOrAdd loader panicked")
+
+// NewSync returns a SyncCache wrapping c, which must not be used
+// directly afterwards. If c is nil, the cache has no limit. c's
+// OnEvicted and SizeFunc are called with the lock held, and must not
+// call the SyncCache.
+func NewSync(c *Cache) *SyncCache {
+	if c == nil {
+		c = New(0)
+	}
+	return &SyncCache{c: c}
+}
+
+// NewSyncWithWarmup returns a SyncCache of maxEntries entries, which it
+// fills in the background by calling loader in a goroutine, like
+// NewWithWarmup. The cache may be used meanwhile; the values loader
+// adds don't replace those added by other means. The returned channel
+// is closed once loader returns.
+func NewSyncWithWarmup(maxEntries int, loader func(add func(key Key, value interface{}))) (s *SyncCache, done <-chan struct{}) {
+	s = NewSync(New(maxEntries))
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		// 预热期间写入的新值优先于预热的值。
+		loader(func(key Key, value interface{}) { s.AddIfAbsent(key, value) })
+	}()
+	return s, ch
+}
+
+// Add adds a value to the cache.
+func (s *SyncCache) Add(key Key, value interface{}) {
+	s.mu.Lock()
+	s.c.Add(key, value)
+	s.mu.Unlock()
+}
+
+// AddMulti adds each of the values to the cache under a single
+// acquisition of the lock.
+func (s *SyncCache) AddMulti(values map[Key]interface{}) {
+	s.mu.Lock()
+	s.c.AddMulti(values)
+	s.mu.Unlock()
+}
+
+// Update replaces the value of key, if it is cached, by fn of its old
+// value, and reports whether it did. fn runs with the lock held, so
+// concurrent Updates of a key don't lose each other's changes; it must
+// not call the SyncCache.
+func (s *SyncCache) Update(key Key, fn func(old interface{}) interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Update(key, fn)
+}
+
+// AddIfAbsent adds a value to the cache if key is not cached, and
+// reports whether it did. Otherwise it returns the cached value.
+func (s *SyncCache) AddIfAbsent(key Key, value interface{}) (existing interface{}, added bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.AddIfAbsent(key, value)
+}
+
+// AddNegative records that key has no value upstream. See
+// Cache.AddNegative.
+func (s *SyncCache) AddNegative(key Key) {
+	s.mu.Lock()
+	s.c.AddNegative(key)
+	s.mu.Unlock()
+}
+
+// AddWithTTL adds a value to the cache that expires after ttl.
+func (s *SyncCache) AddWithTTL(key Key, value interface{}, ttl time.Duration) {
+	s.mu.Lock()
+	s.c.AddWithTTL(key, value, ttl)
+	s.mu.Unlock()
+}
+
+// Get looks up a key's value from the cache.
+func (s *SyncCache) Get(key Key) (value interface{}, ok bool) {
+	// 未命中时只需要读锁。
+	s.mu.RLock()
+	var start time.Time
+	if s.c.Observer != nil {
+		start = now()
+	}
+	key = s.c.canon(key)
+	_, hit := s.c.cache[key]
+	if !hit && s.c.Overflow == nil {
+		s.c.misses.Add(1)
+		if s.c.Observer != nil {
+			s.c.Observer.OnGet(key, false, now().Sub(start))
+		}
+		s.mu.RUnlock()
+		return nil, false
+	}
+	s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Get(key)
+}
+
+// GetMulti looks up the values of keys under a single acquisition of
+// the lock, and returns those of the keys that are cached.
+func (s *SyncCache) GetMulti(keys []Key) map[Key]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.GetMulti(keys)
+}
+
+// GetWithExpiration is like Get, but also returns when the item
+// expires, the zero Time if it doesn't.
+func (s *SyncCache) GetWithExpiration(key Key) (value interface{}, expiresAt time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.GetWithExpiration(key)
+}
+
+// Peek looks up a key's value from the cache without updating its
+// recency. It only takes a read lock.
+func (s *SyncCache) Peek(key Key) (value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Peek(key)
+}
+
+// Touch marks key's item as just used and restarts its TTL, and
+// reports whether key is cached.
+func (s *SyncCache) Touch(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Touch(key)
+}
+
+// EntryInfo describes the unexpired item of key, and reports whether
+// there is one.
+func (s *SyncCache) EntryInfo(key Key) (info EntryInfo, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.EntryInfo(key)
+}
+
+// Contains reports whether key is cached and unexpired, without
+// updating its recency.
+func (s *SyncCache) Contains(key Key) bool {
+	_, ok := s.Peek(key)
+	return ok
+}
+
+// GetOrAdd returns key's value if it is cached, and otherwise calls
+// loader and adds the value it returns. Concurrent calls for the same
+// key wait for a single call of loader and share its result; loader
+// runs without the lock held. Errors of loader are returned and
+// nothing is added. If loader panics, the callers waiting for it get
+// an error.
+func (s *SyncCache) GetOrAdd(key Key, loader func() (interface{}, error)) (value interface{}, err error) {
+	key = s.c.canon(key)
+	s.mu.Lock()
+	if v, ok := s.c.Get(key); ok {
+		s.mu.Unlock()
+		return v, nil
+	}
+	if l, ok := s.loads[key]; ok {
+		s.mu.Unlock()
+		l.wg.Wait()
+		return l.value, l.err
+	}
+	l := new(syncLoad)
+	l.wg.Add(1)
+	if s.loads == nil {
+		s.loads = make(map[interface{}]*syncLoad)
+	}
+	s.loads[key] = l
+	s.mu.Unlock()
+
+	returned := false
+	defer func() {
+		// loader panic时也要唤醒等待的调用者，否则它们会一直阻塞。
+		if !returned {
+			l.value, l.err = nil, errLoaderPanic
+		}
+		s.mu.Lock()
+		if l.err == nil {
+			s.c.Add(key, l.value)
+		}
+		delete(s.loads, key)
+		s.mu.Unlock()
+		l.wg.Done()
+	}()
+	l.value, l.err = loader()
+	returned = true
+	return l.value, l.err
+}
+
+// Remove removes the provided key from the cache.
+func (s *SyncCache) Remove(key Key) {
+	s.mu.Lock()
+	s.c.Remove(key)
+	s.mu.Unlock()
+}
+
+// RemoveOldest removes the oldest item from the cache.
+func (s *SyncCache) RemoveOldest() {
+	s.mu.Lock()
+	s.c.RemoveOldest()
+	s.mu.Unlock()
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or changing its recency.
+func (s *SyncCache) PeekOldest() (key Key, value interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.PeekOldest()
+}
+
+// RemoveOldestN removes up to n of the oldest items from the cache,
+// holding the lock once, and returns how many it removed.
+func (s *SyncCache) RemoveOldestN(n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.RemoveOldestN(n)
+}
+
+// Resize sets the cache's MaxEntries to newMax and removes the oldest
+// items until the cache fits, returning how many it removed.
+func (s *SyncCache) Resize(newMax int) (evicted int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Resize(newMax)
+}
+
+// Pin keeps the cached item of key from being evicted, and reports
+// whether key is cached.
+func (s *SyncCache) Pin(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Pin(key)
+}
+
+// Unpin undoes Pin, and reports whether key is cached.
+func (s *SyncCache) Unpin(key Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Unpin(key)
+}
+
+// PurgeExpired removes the expired entries from the cache and returns
+// how many there were.
+func (s *SyncCache) PurgeExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.PurgeExpired()
+}
+
+// RemoveExpired is PurgeExpired.
+func (s *SyncCache) RemoveExpired() int {
+	return s.PurgeExpired()
+}
+
+// StartJanitor calls PurgeExpired every interval until the returned
+// function is called.
+func (s *SyncCache) StartJanitor(interval time.Duration) (stop func()) {
+	return s.c.StartJanitor(interval, &s.mu)
+}
+
+// Keys returns the keys of the unexpired items in the cache, from the
+// most to the least recently used.
+func (s *SyncCache) Keys() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Keys()
+}
+
+// Len returns the number of items in the cache.
+func (s *SyncCache) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Len()
+}
+
+// Bytes returns the total size of the items in the cache.
+func (s *SyncCache) Bytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.Bytes()
+}
+
+// SizeBytes returns an estimate of the memory taken by the items in
+// the cache.
+func (s *SyncCache) SizeBytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.SizeBytes()
+}
+
+// Clear purges all stored items from the cache.
+func (s *SyncCache) Clear() {
+	s.mu.Lock()
+	s.c.Clear()
+	s.mu.Unlock()
+}
+
+// Range calls f for each unexpired item in the cache, from the most to
+// the least recently used, until f returns false. It holds a read lock
+// while it runs, so f must not call the SyncCache.
+func (s *SyncCache) Range(f func(key Key, value interface{}) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.c.Range(f)
+}
+
+// RangeOldest is like Range but visits the items from the least to the
+// most recently used.
+func (s *SyncCache) RangeOldest(f func(key Key, value interface{}) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.c.RangeOldest(f)
+}
+
+// Stats returns the counters of the cache's operations, without taking
+// the lock.
+func (s *SyncCache) Stats() Stats {
+	return s.c.Stats()
+}
+
+// PublishExpvar publishes the cache's statistics as the expvar
+// variable name. See Cache.PublishExpvar.
+func (s *SyncCache) PublishExpvar(name string) {
+	s.c.PublishExpvar(name)
+}
+
+// SnapshotIterator returns an Iterator over the items of the cache,
+// taking only a read lock while it copies their keys. The Iterator is
+// used without the lock.
+func (s *SyncCache) SnapshotIterator() *Iterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.c.SnapshotIterator()
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSyncCache(t *testing.T) {
+	c := NewSync(New(2))
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b not evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d; want 2", c.Len())
+	}
+	if k, v, ok := c.PeekOldest(); k != "c" || v != 3 || !ok {
+		t.Errorf("PeekOldest = %v, %v, %v; want c, 3, true", k, v, ok)
+	}
+	c.Remove("a")
+	c.RemoveOldest()
+	if c.Len() != 0 {
+		t.Errorf("Len = %d; want 0", c.Len())
+	}
+}
+
+func TestSyncCacheStats(t *testing.T) {
+	var o logObserver
+	c := NewSync(&Cache{Observer: &o})
+	c.Add("a", 1)
+	c.Get("a")
+	c.Get("b")
+	c.GetOrAdd("c", func() (interface{}, error) { return 3, nil })
+	want := Stats{Hits: 1, Misses: 2, Adds: 2}
+	if st := c.Stats(); st != want {
+		t.Errorf("Stats = %+v; want %+v", st, want)
+	}
+	if len(o) != 5 || !strings.HasPrefix(o[2], "get b false") {
+		t.Errorf("observed %v; want the miss of b", o)
+	}
+}
+
+func TestSyncCacheMulti(t *testing.T) {
+	c := NewSync(New(0))
+	c.AddMulti(map[Key]interface{}{"a": 1, "b": 2})
+	got := c.GetMulti([]Key{"a", "b", "c"})
+	if fmt.Sprint(got) != "map[a:1 b:2]" {
+		t.Errorf("GetMulti = %v; want map[a:1 b:2]", got)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d; want 2", c.Len())
+	}
+}
+
+func TestNewSyncWithWarmup(t *testing.T) {
+	release := make(chan struct{})
+	c, done := NewSyncWithWarmup(0, func(add func(Key, interface{})) {
+		<-release
+		add("a", "warm")
+		add("b", "warm")
+	})
+	c.Add("a", "fresh")
+	close(release)
+	<-done
+	if v, _ := c.Get("a"); v != "fresh" {
+		t.Errorf("Get(a) = %v; want fresh", v)
+	}
+	if v, _ := c.Get("b"); v != "warm" {
+		t.Errorf("Get(b) = %v; want warm", v)
+	}
+}
+
+func TestSyncCacheGetOrAddPanic(t *testing.T) {
+	c := NewSync(&Cache{KeyFunc: func(key Key) Key { return strings.ToLower(key.(string)) }})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("GetOrAdd did not propagate the loader's panic")
+			}
+		}()
+		c.GetOrAdd("k", func() (interface{}, error) { panic("boom") })
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, err := c.GetOrAdd("k", func() (interface{}, error) { return "v", nil }); v != "v" || err != nil {
+			t.Errorf("GetOrAdd after a panic = %v, %v; want v, nil", v, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetOrAdd blocked after a loader panicked")
+	}
+
+	// 等价的key共享一次加载。
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "w", nil
+	}
+	var wg sync.WaitGroup
+	for _, k := range []string{"X", "x"} {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			c.GetOrAdd(k, loader)
+		}(k)
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	// 给第二个调用者时间加入等待。
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times for equivalent keys; want 1", n)
+	}
+}
+
+func TestSyncCacheUpdate(t *testing.T) {
+	c := NewSync(nil)
+	c.Add("n", 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Update("n", func(old interface{}) interface{} { return old.(int) + 1 })
+		}()
+	}
+	wg.Wait()
+	if v, _ := c.Get("n"); v != 100 {
+		t.Errorf("n = %v; want 100", v)
+	}
+}
+
+func TestSyncCacheConcurrent(t *testing.T) {
+	c := NewSync(New(100))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprint((i * j) % 150)
+				if _, ok := c.Get(key); !ok {
+					c.Add(key, j)
+				}
+				if j%100 == 0 {
+					c.Range(func(Key, interface{}) bool { return true })
+					c.Len()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 100 {
+		t.Errorf("Len = %d; want at most 100", n)
+	}
+}
+
+func TestSyncCacheGetOrAdd(t *testing.T) {
+	c := NewSync(nil)
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "v", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrAdd("k", loader); v != "v" || err != nil {
+				t.Errorf("GetOrAdd = %v, %v; want v, nil", v, err)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times; want 1", n)
+	}
+
+	errLoad := errors.New("load failed")
+	if _, err := c.GetOrAdd("bad", func() (interface{}, error) { return nil, errLoad }); err != errLoad {
+		t.Errorf("GetOrAdd error = %v; want %v", err, errLoad)
+	}
+	if _, ok := c.Get("bad"); ok {
+		t.Error("failed load was cached")
+	}
+}
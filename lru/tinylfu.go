@@ -0,0 +1,203 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+// hashKey returns a hash of key.
+func hashKey(key Key) uint64 {
+	h := fnv.New64a()
+	switch k := key.(type) {
+	case string:
+		h.Write([]byte(k))
+	case int:
+		h.Write(strconv.AppendInt(nil, int64(k), 10))
+	case int64:
+		h.Write(strconv.AppendInt(nil, k, 10))
+	case uint64:
+		h.Write(strconv.AppendUint(nil, k, 10))
+	default:
+		// 其他类型的key按其字符串形式计算。
+		fmt.Fprintf(h, "%T:%v", key, key)
+	}
+	return h.Sum64()
+}
+
+// sketchRows is the number of counter rows of a CountMinSketch.
+const sketchRows = 4
+
+// CountMinSketch estimates how often keys were added recently, in a
+// fixed amount of memory. Counts are halved every 10 additions per
+// counter of a row, so that keys popular long ago fade out. It is safe
+// for concurrent use.
+type CountMinSketch struct {
+	mu        sync.Mutex
+	rows      [sketchRows][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// NewCountMinSketch returns a sketch of at least the given number of
+// counters per row. A few times the number of distinct keys expected
+// among recent additions is enough.
+func NewCountMinSketch(counters int) *CountMinSketch {
+	width := 1
+	for width < counters {
+		width *= 2
+	}
+	s := &CountMinSketch{mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes returns the counter of key in each row.
+func (s *CountMinSketch) indexes(key Key) (idx [sketchRows]uint64) {
+	sum := hashKey(key)
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return
+}
+
+// Add counts an occurrence of key.
+func (s *CountMinSketch) Add(key Key) {
+	idx := s.indexes(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, j := range idx {
+		if s.rows[i][j] < 255 {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		for _, row := range s.rows {
+			for j := range row {
+				row[j] /= 2
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+// Estimate returns the smallest count of key's counters, which is at
+// least the number of recent additions of key.
+func (s *CountMinSketch) Estimate(key Key) int {
+	idx := s.indexes(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 255
+	for i, j := range idx {
+		if c := int(s.rows[i][j]); c < n {
+			n = c
+		}
+	}
+	return n
+}
+
+// TinyLFU is a Cache with a TinyLFU admission filter: it counts the
+// Gets of each key in a CountMinSketch, and when the cache is full
+// only adds an entry if its key was requested more often than the
+// entry it would evict. Under heavy churn this keeps one-off keys from
+// evicting popular ones. Like Cache it is not safe for concurrent
+// access.
+type TinyLFU struct {
+	c        *Cache
+	sketch   *CountMinSketch
+	rejected int64
+}
+
+var _ Interface = (*TinyLFU)(nil)
+
+// NewTinyLFU returns a TinyLFU filtering additions to c, which should
+// have a MaxEntries or MaxBytes limit and must not be used directly
+// afterwards. counters sizes the sketch; see NewCountMinSketch.
+func NewTinyLFU(c *Cache, counters int) *TinyLFU {
+	return &TinyLFU{c: c, sketch: NewCountMinSketch(counters)}
+}
+
+// Add adds a value to the cache, unless the cache is full and key was
+// requested less often than the oldest entry. Values of keys already
+// cached are always replaced.
+func (t *TinyLFU) Add(key Key, value interface{}) {
+	key = t.c.canon(key)
+	if _, ok := t.c.cache[key]; !ok && !t.admit(key, value) {
+		t.rejected++
+		return
+	}
+	t.c.Add(key, value)
+}
+
+// admit reports whether a new entry should be added.
+func (t *TinyLFU) admit(key Key, value interface{}) bool {
+	c := t.c
+	full := c.MaxEntries != 0 && c.Len() >= c.MaxEntries
+	if c.MaxBytes > 0 && c.Bytes()+c.size(key, value) > c.MaxBytes {
+		full = true
+	}
+	if !full {
+		return true
+	}
+	vk, _, ok := c.PeekOldest()
+	if !ok {
+		return true
+	}
+	// 新key的访问频率高于被淘汰的key时才加入缓存。
+	return t.sketch.Estimate(key) > t.sketch.Estimate(vk)
+}
+
+// Get looks up a key's value from the cache, and counts the request.
+func (t *TinyLFU) Get(key Key) (value interface{}, ok bool) {
+	key = t.c.canon(key)
+	t.sketch.Add(key)
+	return t.c.Get(key)
+}
+
+// Rejected returns the number of entries Add refused.
+func (t *TinyLFU) Rejected() int64 {
+	return t.rejected
+}
+
+// Remove removes the provided key from the cache.
+func (t *TinyLFU) Remove(key Key) { t.c.Remove(key) }
+
+// RemoveOldest removes the oldest item from the cache.
+func (t *TinyLFU) RemoveOldest() { t.c.RemoveOldest() }
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it or changing its recency.
+func (t *TinyLFU) PeekOldest() (key Key, value interface{}, ok bool) { return t.c.PeekOldest() }
+
+// Len returns the number of items in the cache.
+func (t *TinyLFU) Len() int { return t.c.Len() }
+
+// Clear purges all stored items from the cache. Request counts are
+// kept.
+func (t *TinyLFU) Clear() { t.c.Clear() }
+
+// Range calls f for each unexpired item in the cache, from the most to
+// the least recently used, until f returns false.
+func (t *TinyLFU) Range(f func(key Key, value interface{}) bool) { t.c.Range(f) }
@@ -0,0 +1,95 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	s := NewCountMinSketch(64)
+	for i := 0; i < 5; i++ {
+		s.Add("hot")
+	}
+	s.Add("cold")
+	if h, c := s.Estimate("hot"), s.Estimate("cold"); h < 5 || c < 1 || h <= c {
+		t.Errorf("estimates hot %d, cold %d; want at least 5 and 1", h, c)
+	}
+
+	// Counts are halved after 10 additions per counter.
+	for i := 0; i < 10*64; i++ {
+		s.Add("other")
+	}
+	if h := s.Estimate("hot"); h >= 5 {
+		t.Errorf("estimate of hot = %d after aging; want below 5", h)
+	}
+}
+
+func TestTinyLFU(t *testing.T) {
+	c := NewTinyLFU(New(10), 1024)
+	get := func(key string) {
+		if _, ok := c.Get(key); !ok {
+			c.Add(key, key)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 8; k++ {
+			get(fmt.Sprint("hot", k))
+		}
+	}
+	for k := 0; k < 100; k++ {
+		get(fmt.Sprint("scan", k))
+	}
+	for k := 0; k < 8; k++ {
+		if _, ok := c.Get(fmt.Sprint("hot", k)); !ok {
+			t.Errorf("hot%d was evicted by a scan", k)
+		}
+	}
+	if c.Rejected() == 0 {
+		t.Error("no scanned keys were rejected")
+	}
+	if c.Len() != 10 {
+		t.Errorf("Len = %d; want 10", c.Len())
+	}
+
+	// Keys already cached are always updated.
+	c.Add("hot0", "new")
+	if v, _ := c.Get("hot0"); v != "new" {
+		t.Errorf("Get(hot0) = %v; want new", v)
+	}
+}
+
+func TestTinyLFUMaxBytes(t *testing.T) {
+	c := NewTinyLFU(&Cache{
+		MaxBytes: 10,
+		SizeFunc: func(_ Key, value interface{}) int64 { return int64(len(value.(string))) },
+	}, 64)
+	c.Get("a")
+	c.Get("a")
+	c.Add("a", "12345678")
+	// b would evict a, which was requested more often.
+	c.Get("b")
+	c.Add("b", "123")
+	if _, ok := c.Get("b"); ok || c.Rejected() != 1 {
+		t.Errorf("b admitted, rejected = %d; want b refused", c.Rejected())
+	}
+	c.Add("c", "12")
+	if c.Len() != 2 {
+		t.Errorf("Len = %d; want 2", c.Len())
+	}
+}
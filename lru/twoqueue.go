@@ -0,0 +1,243 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// Default queue ratios of a TwoQueue cache, from the 2Q paper.
+const (
+	defaultInRatio  = 0.25
+	defaultOutRatio = 0.5
+)
+
+// TwoQueue is a 2Q cache. New entries enter a FIFO queue, A1in, and
+// the keys it evicts are remembered in A1out; only keys added again
+// while remembered enter the LRU queue Am. A scan of one-off keys
+// therefore only evicts entries of A1in, and keeps the working set in
+// Am. Like Cache it is not safe for concurrent access, and its zero
+// value is ready to use.
+type TwoQueue struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit, in which case the
+	// ratios apply to the cache's current length.
+	MaxEntries int
+
+	// InRatio is the share of the entries A1in may hold before it
+	// gives up entries rather than Am. If zero, it defaults to 0.25.
+	InRatio float64
+
+	// OutRatio is the number of keys remembered in A1out, as a share
+	// of the entries. If zero, it defaults to 0.5.
+	OutRatio float64
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	// lists are A1in, Am and A1out, each from the newest entry to the
+	// oldest.
+	lists [3]*list.List
+	cache map[interface{}]*list.Element
+}
+
+var _ Interface = (*TwoQueue)(nil)
+
+// The queues of a TwoQueue.
+const (
+	qIn  = iota // 新加入的缓存，先进先出
+	qM          // 再次加入的缓存，LRU
+	qOut        // 从A1in淘汰的key
+)
+
+type qEntry struct {
+	key   Key
+	value interface{} // nil in A1out
+	queue int
+}
+
+func (c *TwoQueue) init() {
+	if c.cache == nil {
+		c.cache = make(map[interface{}]*list.Element)
+		for i := range c.lists {
+			c.lists[i] = list.New()
+		}
+	}
+}
+
+// size returns ratio of the capacity, at least 1.
+func (c *TwoQueue) size(ratio, def float64) int {
+	if ratio <= 0 {
+		ratio = def
+	}
+	n := c.MaxEntries
+	if n <= 0 {
+		n = c.Len()
+	}
+	if k := int(ratio * float64(n)); k > 1 {
+		return k
+	}
+	return 1
+}
+
+func (c *TwoQueue) push(key Key, value interface{}, q int) {
+	c.cache[key] = c.lists[q].PushFront(&qEntry{key, value, q})
+}
+
+// Add adds a value to the cache.
+func (c *TwoQueue) Add(key Key, value interface{}) {
+	c.init()
+	if ele, ok := c.cache[key]; ok {
+		e := ele.Value.(*qEntry)
+		switch e.queue {
+		case qM:
+			e.value = value
+			c.lists[qM].MoveToFront(ele)
+			return
+		case qIn:
+			// A1in是先进先出的，不改变顺序。
+			e.value = value
+			return
+		}
+		// 刚被淘汰的key再次加入，进入Am。
+		c.lists[qOut].Remove(ele)
+		delete(c.cache, key)
+		c.makeRoom()
+		c.push(key, value, qM)
+		return
+	}
+	c.makeRoom()
+	c.push(key, value, qIn)
+}
+
+// makeRoom evicts an entry if the cache is full.
+func (c *TwoQueue) makeRoom() {
+	if c.MaxEntries != 0 && c.Len() >= c.MaxEntries {
+		c.RemoveOldest()
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *TwoQueue) Get(key Key) (value interface{}, ok bool) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	e := ele.Value.(*qEntry)
+	switch e.queue {
+	case qM:
+		c.lists[qM].MoveToFront(ele)
+	case qOut:
+		return nil, false
+	}
+	return e.value, true
+}
+
+// victim returns the entry RemoveOldest evicts: the oldest of A1in if
+// it is over its share or Am is empty, else the oldest of Am.
+func (c *TwoQueue) victim() *list.Element {
+	in := c.lists[qIn]
+	if in.Len() > 0 && (in.Len() > c.size(c.InRatio, defaultInRatio) || c.lists[qM].Len() == 0) {
+		return in.Back()
+	}
+	return c.lists[qM].Back()
+}
+
+// RemoveOldest removes the oldest item of A1in or Am. Keys removed
+// from A1in are remembered in A1out.
+func (c *TwoQueue) RemoveOldest() {
+	if c.Len() == 0 {
+		return
+	}
+	ele := c.victim()
+	e := ele.Value.(*qEntry)
+	value := e.value
+	c.lists[e.queue].Remove(ele)
+	if e.queue == qIn {
+		e.value = nil
+		c.push(e.key, nil, qOut)
+		for out := c.lists[qOut]; out.Len() > c.size(c.OutRatio, defaultOutRatio); {
+			delete(c.cache, out.Remove(out.Back()).(*qEntry).key)
+		}
+	} else {
+		delete(c.cache, e.key)
+	}
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, value)
+	}
+}
+
+// PeekOldest returns the item RemoveOldest would remove, without
+// removing it.
+func (c *TwoQueue) PeekOldest() (key Key, value interface{}, ok bool) {
+	if c.Len() == 0 {
+		return
+	}
+	e := c.victim().Value.(*qEntry)
+	return e.key, e.value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *TwoQueue) Remove(key Key) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	e := ele.Value.(*qEntry)
+	c.lists[e.queue].Remove(ele)
+	delete(c.cache, key)
+	if c.OnEvicted != nil && e.queue != qOut {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *TwoQueue) Len() int {
+	if c.cache == nil {
+		return 0
+	}
+	return c.lists[qIn].Len() + c.lists[qM].Len()
+}
+
+// Clear purges all stored items from the cache, and forgets the keys
+// remembered in A1out.
+func (c *TwoQueue) Clear() {
+	if c.OnEvicted != nil {
+		c.Range(func(key Key, value interface{}) bool {
+			c.OnEvicted(key, value)
+			return true
+		})
+	}
+	c.cache = nil
+}
+
+// Range calls f for each item in the cache, first those of Am from the
+// most to the least recently used, then those of A1in from the newest
+// to the oldest, until f returns false. It does not change the order
+// of the items; f must not modify the cache.
+func (c *TwoQueue) Range(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for _, q := range []int{qM, qIn} {
+		for ele := c.lists[q].Front(); ele != nil; ele = ele.Next() {
+			e := ele.Value.(*qEntry)
+			if !f(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
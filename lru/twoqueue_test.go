@@ -0,0 +1,95 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTwoQueue(t *testing.T) {
+	var evicted []Key
+	c := &TwoQueue{MaxEntries: 4, InRatio: 0.5, OutRatio: 0.5, OnEvicted: func(key Key, _ interface{}) {
+		evicted = append(evicted, key)
+	}}
+	for i, k := range []string{"a", "b", "c", "d"} {
+		c.Add(k, i)
+	}
+	// Hits in A1in don't change its order.
+	c.Get("a")
+	c.Add("e", 4)
+	c.Add("f", 5)
+	c.Add("g", 6)
+	if got, want := fmt.Sprint(evicted), "[a b c]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+	// A1out remembers b and c; a, the oldest, is forgotten.
+	if _, ok := c.Get("b"); ok {
+		t.Error("Get(b) hit an evicted key")
+	}
+	c.Add("b", 10)
+	c.Add("a", 11)
+	if got, want := fmt.Sprint(evicted), "[a b c d e]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+	var keys []Key
+	c.Range(func(key Key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := fmt.Sprint(keys), "[b a g f]"; got != want {
+		t.Errorf("Range visited %s; want %s", got, want)
+	}
+	if k, _, ok := c.PeekOldest(); k != "f" || !ok {
+		t.Errorf("PeekOldest = %v, %v; want f, true", k, ok)
+	}
+
+	c.Remove("b")
+	if c.Len() != 3 {
+		t.Errorf("Len = %d; want 3", c.Len())
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Errorf("Len after Clear = %d; want 0", c.Len())
+	}
+}
+
+func TestTwoQueueScanResistance(t *testing.T) {
+	c := &TwoQueue{MaxEntries: 10}
+	// Hot keys are added, evicted by other keys and added again.
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 5; i++ {
+			if _, ok := c.Get(fmt.Sprint("hot", i)); !ok {
+				c.Add(fmt.Sprint("hot", i), i)
+			}
+		}
+		for i := 0; i < 10; i++ {
+			c.Add(fmt.Sprint("warmup", round, i), i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprint("scan", i), i)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := c.Get(fmt.Sprint("hot", i)); !ok {
+			t.Errorf("hot%d was evicted by a scan", i)
+		}
+	}
+	if c.Len() != 10 {
+		t.Errorf("Len = %d; want 10", c.Len())
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package memcachestore provides a groupcache.BackingStore over a
+// memcached cluster, so that values a group evicted or has not loaded
+// yet can be fetched from a shared second-level cache before the
+// origin:
+//
+//	store := memcachestore.New([]string{"10.0.0.5:11211", "10.0.0.6:11211"},
+//		&memcachestore.Options{KeyPrefix: "thumbs:", Expiration: time.Hour})
+//	group := groupcache.NewGroupOpts("thumbs", 64<<20, getter, &groupcache.GroupOptions{
+//		Middleware: []groupcache.GetterMiddleware{groupcache.WithBackingStore(store, nil)},
+//	})
+//
+// Keys are spread over the servers with a consistent hash and spoken
+// to with memcached's text protocol.
+package memcachestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/groupcache/consistenthash"
+)
+
+// Options configure a Store.
+type Options struct {
+	// KeyPrefix is prepended to every key, keeping the keys of
+	// groups sharing a cluster apart.
+	KeyPrefix string
+
+	// Expiration is how long memcached keeps values.
+	// If blank, values are kept until memcached evicts them.
+	Expiration time.Duration
+
+	// Timeout bounds each operation, on top of its context.
+	// If blank, it defaults to 500ms.
+	Timeout time.Duration
+
+	// MaxIdleConns is the number of idle connections kept to each
+	// server. If blank, it defaults to 2.
+	MaxIdleConns int
+}
+
+// A Store is a groupcache.BackingStore over memcached. It is safe for
+// concurrent use.
+type Store struct {
+	opts    Options
+	ring    *consistenthash.Map
+	servers map[string]*server
+}
+
+// New returns a store spreading keys over the memcached servers, given
+// as host:port addresses.
+func New(servers []string, o *Options) *Store {
+	s := &Store{
+		ring:    consistenthash.New(50, nil),
+		servers: make(map[string]*server),
+	}
+	if o != nil {
+		s.opts = *o
+	}
+	if s.opts.Timeout <= 0 {
+		s.opts.Timeout = 500 * time.Millisecond
+	}
+	if s.opts.MaxIdleConns <= 0 {
+		s.opts.MaxIdleConns = 2
+	}
+	for _, addr := range servers {
+		s.servers[addr] = &server{addr: addr, idle: make(chan *conn, s.opts.MaxIdleConns)}
+	}
+	s.ring.Add(servers...)
+	return s
+}
+
+// errNotStored is returned for values memcached refused to store.
+var errNotStored = errors.New("memcachestore: value not stored")
+
+// Get returns the value stored under key.
+func (s *Store) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	k := s.key(key)
+	err = s.do(ctx, k, func(c *conn) error {
+		fmt.Fprintf(c.rw, "get %s\r\n", k)
+		if err := c.rw.Flush(); err != nil {
+			return err
+		}
+		value, ok, err = c.readValue(k)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, ok, nil
+}
+
+// Set stores value under key.
+func (s *Store) Set(ctx context.Context, key string, value []byte) error {
+	k := s.key(key)
+	return s.do(ctx, k, func(c *conn) error {
+		fmt.Fprintf(c.rw, "set %s 0 %d %d\r\n", k, s.exptime(), len(value))
+		c.rw.Write(value)
+		c.rw.WriteString("\r\n")
+		if err := c.rw.Flush(); err != nil {
+			return err
+		}
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "STORED":
+			return nil
+		case line == "NOT_STORED", strings.HasPrefix(line, "SERVER_ERROR"):
+			// For example a value over memcached's item size limit.
+			return fmt.Errorf("%w: %s", errNotStored, line)
+		}
+		return protocolError(line)
+	})
+}
+
+// Close closes the idle connections.
+func (s *Store) Close() error {
+	for _, srv := range s.servers {
+		srv.closeIdle()
+	}
+	return nil
+}
+
+// maxKeyLen is the longest key memcached accepts.
+const maxKeyLen = 250
+
+// key returns the memcached key of key: the prefixed key, or a hash of
+// it if memcached would not accept it.
+func (s *Store) key(key string) string {
+	k := s.opts.KeyPrefix + key
+	if len(k) <= maxKeyLen && !strings.ContainsAny(k, " \t\r\n\x00\x7f") {
+		return k
+	}
+	sum := sha256.Sum256([]byte(k))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// exptime returns the expiration time of set commands. Memcached takes
+// durations over 30 days as unix times.
+func (s *Store) exptime() int64 {
+	d := s.opts.Expiration
+	if d <= 0 {
+		return 0
+	}
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs > 30*24*3600 {
+		return time.Now().Unix() + secs
+	}
+	return secs
+}
+
+// do runs f on a connection to key's server. Connections are only
+// reused after f succeeds, so that no reply is left unread.
+func (s *Store) do(ctx context.Context, key string, f func(c *conn) error) error {
+	srv := s.servers[s.ring.Get(key)]
+	if srv == nil {
+		return errors.New("memcachestore: no servers")
+	}
+	c, err := srv.get(ctx, s.opts.Timeout)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(s.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.nc.SetDeadline(deadline)
+	if err := f(c); err != nil {
+		if errors.Is(err, errNotStored) {
+			srv.put(c)
+		} else {
+			c.nc.Close()
+		}
+		return err
+	}
+	srv.put(c)
+	return nil
+}
+
+type server struct {
+	addr string
+	idle chan *conn
+}
+
+type conn struct {
+	nc net.Conn
+	rw *bufio.ReadWriter
+}
+
+func (srv *server) get(ctx context.Context, timeout time.Duration) (*conn, error) {
+	select {
+	case c := <-srv.idle:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: timeout}
+	nc, err := d.DialContext(ctx, "tcp", srv.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{nc: nc, rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}, nil
+}
+
+func (srv *server) put(c *conn) {
+	select {
+	case srv.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+func (srv *server) closeIdle() {
+	for {
+		select {
+		case c := <-srv.idle:
+			c.nc.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// readValue reads the reply to a get of key.
+func (c *conn) readValue(key string) (value []byte, ok bool, err error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, false, err
+		}
+		if line == "END" {
+			return value, ok, nil
+		}
+		// VALUE <key> <flags> <bytes>
+		f := strings.Fields(line)
+		if len(f) < 4 || f[0] != "VALUE" || f[1] != key {
+			return nil, false, protocolError(line)
+		}
+		n, err := strconv.Atoi(f[3])
+		if err != nil || n < 0 {
+			return nil, false, protocolError(line)
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rw, b); err != nil {
+			return nil, false, err
+		}
+		if !bytes.HasSuffix(b, []byte("\r\n")) {
+			return nil, false, protocolError("value not terminated")
+		}
+		value, ok = b[:n], true
+	}
+}
+
+func protocolError(line string) error {
+	return fmt.Errorf("memcachestore: unexpected reply %q", line)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memcachestore
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMemcached serves get and set commands from a map.
+type fakeMemcached struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	m        map[string][]byte
+	exptimes map[string]string
+	maxItem  int
+}
+
+func startFakeMemcached(t *testing.T) *fakeMemcached {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMemcached{ln: ln, m: make(map[string][]byte), exptimes: make(map[string]string), maxItem: 1 << 20}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeMemcached) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		switch {
+		case len(args) == 2 && args[0] == "get":
+			f.mu.Lock()
+			v, ok := f.m[args[1]]
+			f.mu.Unlock()
+			if ok {
+				fmt.Fprintf(c, "VALUE %s 0 %d\r\n%s\r\n", args[1], len(v), v)
+			}
+			io.WriteString(c, "END\r\n")
+		case len(args) == 5 && args[0] == "set":
+			n, _ := strconv.Atoi(args[4])
+			b := make([]byte, n+2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+			if n > f.maxItem {
+				io.WriteString(c, "SERVER_ERROR object too large for cache\r\n")
+				continue
+			}
+			f.mu.Lock()
+			f.m[args[1]] = b[:n]
+			f.exptimes[args[1]] = args[3]
+			f.mu.Unlock()
+			io.WriteString(c, "STORED\r\n")
+		default:
+			io.WriteString(c, "ERROR\r\n")
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	f := startFakeMemcached(t)
+	defer f.ln.Close()
+	f.maxItem = 100
+	s := New([]string{f.ln.Addr().String()}, &Options{KeyPrefix: "g:", Expiration: time.Minute})
+	defer s.Close()
+	ctx := context.Background()
+
+	if _, ok, err := s.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("Get of a missing key = %v, %v; want a miss", ok, err)
+	}
+	if err := s.Set(ctx, "k", []byte("v\r\nEND")); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := s.Get(ctx, "k"); string(v) != "v\r\nEND" || !ok || err != nil {
+		t.Errorf("Get = %q, %v, %v; want the value set", v, ok, err)
+	}
+	if got := f.exptimes["g:k"]; got != "60" {
+		t.Errorf("set with exptime %s; want 60", got)
+	}
+
+	long := strings.Repeat("x", 300) + " with spaces"
+	if err := s.Set(ctx, long, []byte("long")); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := s.Get(ctx, long); string(v) != "long" {
+		t.Errorf("Get of a long key = %q; want long", v)
+	}
+
+	if err := s.Set(ctx, "big", make([]byte, 200)); err == nil {
+		t.Errorf("Set of an oversized value succeeded")
+	}
+	// The connection survives the refusal.
+	if v, _, err := s.Get(ctx, "k"); string(v) != "v\r\nEND" || err != nil {
+		t.Errorf("Get after a refused Set = %q, %v", v, err)
+	}
+}
+
+func TestStoreDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	s := New([]string{addr}, nil)
+	if _, ok, err := s.Get(context.Background(), "k"); ok || err == nil {
+		t.Errorf("Get from a down server = %v, %v; want an error", ok, err)
+	}
+}
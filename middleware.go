@@ -0,0 +1,98 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// middleware.go defines Getter middlewares, which compose cross-cutting
+// load behavior around a group's Getter.
+
+package groupcache
+
+import (
+	"context"
+	"time"
+)
+
+// A GetterMiddleware wraps a Getter with additional behavior, such as
+// logging, metrics, retries, timeouts or value transformation.
+type GetterMiddleware func(Getter) Getter
+
+// Chain wraps getter with mws. The first middleware is the outermost:
+// it sees each load first and its result last.
+func Chain(getter Getter, mws ...GetterMiddleware) Getter {
+	for i := len(mws) - 1; i >= 0; i-- {
+		getter = mws[i](getter)
+	}
+	return getter
+}
+
+// Use wraps the group's Getter with mws, outside any middlewares
+// already installed. It must be called before the group's first Get.
+func (g *Group) Use(mws ...GetterMiddleware) {
+	g.getter = Chain(g.getter, mws...)
+}
+
+// WithTimeout returns a middleware bounding each load by d.
+func WithTimeout(d time.Duration) GetterMiddleware {
+	return func(next Getter) Getter {
+		return GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Get(ctx, key, dest)
+		})
+	}
+}
+
+// WithRetry returns a middleware making up to attempts tries of each
+// load, sleeping backoff before the second try and doubling it before
+// each try after that. Loads are not retried once their context is
+// done. Attempts below 1 count as 1.
+func WithRetry(attempts int, backoff time.Duration) GetterMiddleware {
+	attempts = max(attempts, 1)
+	return func(next Getter) Getter {
+		return GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			var err error
+			wait := backoff
+			for i := 0; i < attempts; i++ {
+				if i > 0 {
+					t := time.NewTimer(wait)
+					select {
+					case <-t.C:
+					case <-ctx.Done():
+						t.Stop()
+						return err
+					}
+					wait *= 2
+				}
+				if err = next.Get(ctx, key, dest); err == nil || ctx.Err() != nil {
+					return err
+				}
+			}
+			return err
+		})
+	}
+}
+
+// WithLogging returns a middleware reporting each load's key,
+// duration and error to logf.
+func WithLogging(logf func(format string, args ...interface{})) GetterMiddleware {
+	return func(next Getter) Getter {
+		return GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+			start := time.Now()
+			err := next.Get(ctx, key, dest)
+			logf("groupcache: load %q took %v, err=%v", key, time.Since(start), err)
+			return err
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+	mw := func(name string) GetterMiddleware {
+		return func(next Getter) Getter {
+			return GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+				trace = append(trace, name)
+				return next.Get(ctx, key, dest)
+			})
+		}
+	}
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		trace = append(trace, "getter")
+		return dest.SetString(key)
+	})
+	g := NewGroupOpts("middleware-order", 0, getter, &GroupOptions{
+		Peers:        NoPeers{},
+		Unregistered: true,
+		Middleware:   []GetterMiddleware{mw("a"), mw("b")},
+	})
+	g.Use(mw("c"))
+
+	var s string
+	if err := g.Get(dummyCtx, "k", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(trace, ","), "c,a,b,getter"; got != want {
+		t.Errorf("call order = %s; want %s", got, want)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	calls := 0
+	flaky := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return dest.SetString("ok")
+	})
+	var s string
+	if err := WithRetry(3, time.Millisecond)(flaky).Get(dummyCtx, "k", StringSink(&s)); err != nil {
+		t.Fatalf("Get = %v; want success on third try", err)
+	}
+	if s != "ok" || calls != 3 {
+		t.Errorf("got %q after %d calls; want \"ok\" after 3", s, calls)
+	}
+
+	calls = 0
+	if err := WithRetry(2, time.Millisecond)(flaky).Get(dummyCtx, "k", StringSink(&s)); err == nil {
+		t.Error("Get succeeded; want the last error after 2 tries")
+	}
+
+	calls = 0
+	if err := WithRetry(0, time.Millisecond)(flaky).Get(dummyCtx, "k", StringSink(&s)); err == nil || calls != 1 {
+		t.Errorf("Get with 0 attempts = %v after %d calls; want the error after 1", err, calls)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	slow := GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var s string
+	err := WithTimeout(10*time.Millisecond)(slow).Get(dummyCtx, "k", StringSink(&s))
+	if err != context.DeadlineExceeded {
+		t.Errorf("Get = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithLogging(t *testing.T) {
+	var logged string
+	logf := func(format string, args ...interface{}) { logged = fmt.Sprintf(format, args...) }
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	})
+	var s string
+	if err := WithLogging(logf)(getter).Get(dummyCtx, "logged-key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged, `"logged-key"`) {
+		t.Errorf("log line %q does not mention the key", logged)
+	}
+}
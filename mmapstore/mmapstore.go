@@ -0,0 +1,167 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mmapstore implements a groupcache.ValueStore keeping values
+// in a memory-mapped file, so that a node can cache far more than RAM
+// comfortably allows while the operating system keeps the hot subset
+// paged in. Only the index of keys lives on the Go heap.
+//
+// The file is used as a ring: values are appended at a write head that
+// wraps around, and values it overwrites are dropped from the store.
+// The groupcache cache treats a dropped value as a miss.
+package mmapstore
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrTooLarge is returned by Put for values larger than the store.
+var ErrTooLarge = errors.New("mmapstore: value larger than store")
+
+type location struct {
+	off, n int
+	seq    uint64 // identifies the Put that wrote the value
+}
+
+type record struct {
+	key string
+	location
+}
+
+// A Store is a value store backed by a memory-mapped file. It is safe
+// for concurrent use.
+type Store struct {
+	f *os.File
+
+	mu    sync.RWMutex
+	data  []byte
+	head  int                 // next write offset
+	seq   uint64              // sequence number of the last Put
+	index map[string]location // live values
+	log   []record            // values written, oldest first
+}
+
+// Open creates or truncates the file at path to size bytes and maps it
+// into memory.
+func Open(path string, size int) (*Store, error) {
+	if size <= 0 {
+		return nil, errors.New("mmapstore: size must be positive")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	data, err := mmap(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Store{f: f, data: data, index: make(map[string]location)}, nil
+}
+
+// Put stores a copy of value under key, dropping the oldest values if
+// the file is full.
+func (s *Store) Put(key string, value []byte) error {
+	n := len(value)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		return errors.New("mmapstore: store is closed")
+	}
+	if n > len(s.data) {
+		return ErrTooLarge
+	}
+	if s.head+n > len(s.data) {
+		// Wrap around. Values between the old head and the end of
+		// the file are the oldest; drop them with the rest.
+		for len(s.log) > 0 && s.log[0].off >= s.head {
+			s.dropOldest()
+		}
+		s.head = 0
+	}
+	// Drop the values of the previous lap that are about to be
+	// overwritten; they are the oldest.
+	for len(s.log) > 0 && s.log[0].off >= s.head && s.log[0].off < s.head+n {
+		s.dropOldest()
+	}
+	copy(s.data[s.head:], value)
+	s.seq++
+	loc := location{off: s.head, n: n, seq: s.seq}
+	s.index[key] = loc
+	s.log = append(s.log, record{key, loc})
+	s.head += n
+	return nil
+}
+
+// dropOldest removes the oldest written value.
+func (s *Store) dropOldest() {
+	r := s.log[0]
+	s.log = s.log[1:]
+	if loc, ok := s.index[r.key]; ok && loc.seq == r.seq {
+		delete(s.index, r.key)
+	}
+}
+
+// Get returns a copy of the value stored under key.
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	loc, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	b := make([]byte, loc.n)
+	copy(b, s.data[loc.off:loc.off+loc.n])
+	return b, true
+}
+
+// Remove drops the value stored under key, if any.
+func (s *Store) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.index, key)
+}
+
+// Len returns the number of values in the store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.index)
+}
+
+// Close unmaps and closes the file. The store must not be used after
+// Close.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		return nil
+	}
+	err := munmap(s.data)
+	s.data = nil
+	s.index = nil
+	s.log = nil
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
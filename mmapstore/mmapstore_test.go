@@ -0,0 +1,121 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mmapstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/groupcache"
+)
+
+var _ groupcache.ValueStore = (*Store)(nil)
+
+func open(t *testing.T, size int) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "values"), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPutGet(t *testing.T) {
+	s := open(t, 1<<10)
+	if err := s.Put("a", []byte("alpha")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("b", []byte("beta")); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"a": "alpha", "b": "beta"} {
+		if got, ok := s.Get(key); !ok || string(got) != want {
+			t.Errorf("Get(%q) = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+	s.Remove("a")
+	if _, ok := s.Get("a"); ok {
+		t.Error("removed key still present")
+	}
+	if err := s.Put("big", make([]byte, 1<<11)); err != ErrTooLarge {
+		t.Errorf("Put of oversized value = %v; want ErrTooLarge", err)
+	}
+}
+
+func TestWrapDropsOldest(t *testing.T) {
+	s := open(t, 100)
+	val := bytes.Repeat([]byte("x"), 30)
+	for i := 0; i < 3; i++ {
+		s.Put(fmt.Sprint(i), val)
+	}
+	// The fourth value wraps around and overwrites the first.
+	s.Put("3", val)
+	if _, ok := s.Get("0"); ok {
+		t.Error("overwritten value still present")
+	}
+	for _, key := range []string{"1", "2", "3"} {
+		if got, ok := s.Get(key); !ok || !bytes.Equal(got, val) {
+			t.Errorf("Get(%q) = %q, %v", key, got, ok)
+		}
+	}
+	// Rewriting a key keeps only the new copy live.
+	s.Put("1", []byte("new"))
+	s.Put("4", val)
+	s.Put("5", val)
+	if got, ok := s.Get("1"); !ok || string(got) != "new" {
+		t.Errorf("Get(1) = %q, %v; want \"new\"", got, ok)
+	}
+}
+
+func TestGroupMainStore(t *testing.T) {
+	s := open(t, 1<<16)
+	loads := 0
+	g := groupcache.NewGroupOpts("mmap", 1<<30, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		loads++
+		return dest.SetString("value-of-" + key)
+	}), &groupcache.GroupOptions{Peers: groupcache.NoPeers{}, Unregistered: true, MainStore: s})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		var v string
+		if err := g.Get(ctx, "k", groupcache.StringSink(&v)); err != nil {
+			t.Fatal(err)
+		}
+		if v != "value-of-k" {
+			t.Errorf("Get = %q", v)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loads = %d; want 1", loads)
+	}
+	if s.Len() != 1 {
+		t.Errorf("store holds %d values; want 1", s.Len())
+	}
+
+	// A value dropped by the store is reloaded.
+	s.Remove("k")
+	var v string
+	if err := g.Get(ctx, "k", groupcache.StringSink(&v)); err != nil || v != "value-of-k" {
+		t.Errorf("Get after drop = %q, %v", v, err)
+	}
+	if loads != 2 {
+		t.Errorf("loads = %d; want 2", loads)
+	}
+}
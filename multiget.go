@@ -0,0 +1,328 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
+)
+
+// multiPath is the path, below the pool's BasePath, of the multi-get
+// endpoint. Requests carry a GetMultiRequest body; the response is a
+// stream of length-prefixed GetMultiResponses, one per key.
+const multiPath = "_multi"
+
+// maxRequestBytes bounds the protobuf bodies of peer requests and the
+// frames of streams between peers. Keys whose values don't fit a frame
+// are fetched by Get.
+const maxRequestBytes = 8 << 20
+
+// MultiError maps the keys that GetMulti failed to load to their errors.
+type MultiError map[string]error
+
+func (e MultiError) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 1 {
+		return fmt.Sprintf("groupcache: loading %q: %v", keys[0], e[keys[0]])
+	}
+	return fmt.Sprintf("groupcache: loading %d keys failed; %q: %v", len(keys), keys[0], e[keys[0]])
+}
+
+// multiLoadParallelism bounds the loads GetMulti runs at once for the
+// keys it cannot fetch in batches from peers.
+const multiLoadParallelism = 16
+
+// GetMulti gets the values of keys. Keys missing from the caches and
+// owned by the same peer are fetched with one request if the peer is a
+// MultiGetter; the others are loaded as by Get.
+//
+// It returns the values it got. If any key failed, the error is a
+// MultiError of the failed keys. Each key counts as a Get in the
+// group's statistics and is told to its Recorder.
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	if g.isClosed() {
+		return nil, ErrGroupClosed
+	}
+	g.peersOnce.Do(g.initPeers)
+	g.sampleRates()
+	var start time.Time
+	if g.opts.Recorder != nil {
+		start = time.Now()
+	}
+	var (
+		mu      sync.Mutex
+		values  = make(map[string]ByteView, len(keys))
+		errs    = MultiError{}
+		batches = make(map[MultiGetter][]string)
+		local   []string
+		seen    = make(map[string]bool, len(keys))
+	)
+	// got stores the result of a key. mu must be held.
+	got := func(key string, value ByteView, src GetSource, err error) {
+		if err != nil {
+			errs[key] = err
+			g.record(start, key, 0, SourceError)
+			return
+		}
+		values[key] = value
+		g.Stats.ServedBytes.Add(int64(value.Len()))
+		g.record(start, key, value.Len(), src)
+	}
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.Stats.Gets.Add(1)
+		ck := g.cacheKey(key)
+		if value, src, ok := g.findCached(ck, getOptions{}); ok {
+			g.Stats.CacheHits.Add(1)
+			got(key, value, src, nil)
+			continue
+		}
+		if peer, ok := g.pickPeer(ctx, ck); ok {
+			if mg, ok := peer.(MultiGetter); ok {
+				batches[mg] = append(batches[mg], key)
+				continue
+			}
+		}
+		local = append(local, key)
+	}
+
+	// load loads the keys as Get does, several at a time, so that
+	// Getters batching concurrent loads see them together.
+	load := func(keys []string) {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, multiLoadParallelism)
+		for _, key := range keys {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(key string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				var dst ByteView
+				value, src, _, err := g.load(ctx, key, g.cacheKey(key), ByteViewSink(&dst), getOptions{})
+				mu.Lock()
+				got(key, value, src, err)
+				mu.Unlock()
+			}(key)
+		}
+		wg.Wait()
+	}
+
+	var wg sync.WaitGroup
+	for peer, keys := range batches {
+		wg.Add(1)
+		go func(peer MultiGetter, keys []string) {
+			defer wg.Done()
+			// 批量请求失败或者没有返回的key，退回到逐个加载。
+			left := g.getMultiFromPeer(ctx, peer, keys, func(key string, value ByteView, err error) {
+				mu.Lock()
+				got(key, value, SourcePeer, err)
+				mu.Unlock()
+			})
+			load(left)
+		}(peer, keys)
+	}
+	load(local)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return values, errs
+	}
+	return values, nil
+}
+
+// getMultiFromPeer fetches keys from peer in one request, calling got
+// for each value it sends and each key it reports not found. It
+// returns the other keys.
+func (g *Group) getMultiFromPeer(ctx context.Context, peer MultiGetter, keys []string, got func(key string, value ByteView, err error)) (left []string) {
+	pending := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		pending[key] = true
+	}
+	req := &pb.GetMultiRequest{Group: &g.name, Key: keys}
+	err := peer.GetMulti(ctx, req, func(res *pb.GetMultiResponse) error {
+		key := res.GetKey()
+		if !pending[key] || (res.Error != nil && !res.GetNotFound()) {
+			return nil
+		}
+		delete(pending, key)
+		g.Stats.Loads.Add(1)
+		if res.GetNotFound() {
+			got(key, ByteView{}, fmt.Errorf("%w: %s", ErrNotFound, res.GetError()))
+			return nil
+		}
+		g.Stats.PeerLoads.Add(1)
+		got(key, g.peerValue(g.cacheKey(key), res.Value, res.Expire), nil)
+		return nil
+	})
+	if err != errProtocol && (err != nil || len(pending) > 0) {
+		g.Stats.PeerErrors.Add(1)
+	}
+	for _, key := range keys {
+		if pending[key] {
+			left = append(left, key)
+		}
+	}
+	return left
+}
+
+// readFrame reads a length-prefixed frame of at most maxRequestBytes.
+// It returns io.EOF only at the end of the stream, between frames.
+func readFrame(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRequestBytes {
+		return nil, fmt.Errorf("frame of %d bytes exceeds %d", n, maxRequestBytes)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// readProto decodes the body of r, of at most maxRequestBytes, into m.
+func readProto(w http.ResponseWriter, r *http.Request, m proto.Message) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(body, m)
+}
+
+func (p *HTTPPool) serveMulti(w http.ResponseWriter, r *http.Request) {
+	var req pb.GetMultiRequest
+	if err := readProto(w, r, &req); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	group := GetGroup(req.GetGroup())
+	if group == nil {
+		http.Error(w, "no such group: "+req.GetGroup(), http.StatusNotFound)
+		return
+	}
+	ctx := p.requestContext(r)
+	p.negotiate(w, r)
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	flusher, _ := w.(http.Flusher)
+	var n [binary.MaxVarintLen64]byte
+	for _, key := range req.Key {
+		group.Stats.ServerRequests.Add(1)
+		res := &pb.GetMultiResponse{Key: proto.String(key)}
+		var value ByteView
+		if err := group.Get(ctx, key, ByteViewSink(&value)); err != nil {
+			res.Error = proto.String(err.Error())
+			if errors.Is(err, ErrNotFound) {
+				res.NotFound = proto.Bool(true)
+			}
+		} else {
+			res.Value = value.ByteSlice()
+			if e := value.Expire(); !e.IsZero() {
+				res.Expire = proto.Int64(e.UnixNano())
+			}
+		}
+		b, err := proto.Marshal(res)
+		if err != nil {
+			// Headers are out already; the client falls back to
+			// loading the keys it didn't get.
+			return
+		}
+		if _, err := w.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))]); err != nil {
+			return
+		}
+		if _, err := w.Write(b); err != nil {
+			return
+		}
+		// 每个key的结果立即发送，客户端不必等待最慢的key。
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+var _ MultiGetter = (*httpGetter)(nil)
+
+// GetMulti implements MultiGetter.
+func (h *httpGetter) GetMulti(ctx context.Context, in *pb.GetMultiRequest, fn func(*pb.GetMultiResponse) error) error {
+	if h.peerProtocol() < 2 {
+		return errProtocol
+	}
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", h.baseURL+multiPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if err := h.faults.beforeRequest(ctx); err != nil {
+		return err
+	}
+	res, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return peerError(ctx, err)
+	}
+	defer res.Body.Close()
+	h.observeProtocol(res)
+	if res.StatusCode != http.StatusOK {
+		return statusError(res)
+	}
+	br := bufio.NewReader(res.Body)
+	for {
+		b, err := readFrame(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading response body: %v", err)
+		}
+		out := new(pb.GetMultiResponse)
+		if err := proto.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("decoding response body: %v", err)
+		}
+		if err := fn(out); err != nil {
+			return err
+		}
+	}
+}
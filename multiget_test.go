@@ -0,0 +1,168 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestGetMulti(t *testing.T) {
+	const name = "TestGetMulti-group"
+	errBad := errors.New("bad key")
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "bad" {
+			return errBad
+		}
+		return dest.SetString("v:" + key)
+	})
+	newGroup(name, 1<<20, getter, NoPeers{})
+
+	// The owner serves the registered group; every key hashes to it.
+	var requests int32
+	owner := newHTTPPool("http://owner", nil)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		owner.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	client := newHTTPPool("http://client", nil)
+	client.Set(ts.URL)
+	var records sync.Map
+	rec := recorderFunc(func(r GetRecord) { records.Store(r.Key, r) })
+	g := NewGroupOpts(name, 1<<20, getter, &GroupOptions{Peers: client, Unregistered: true, Recorder: rec})
+
+	keys := []string{"a", "b", "c", "a", "bad"}
+	values, err := g.GetMulti(dummyCtx, keys)
+	var merr MultiError
+	if !errors.As(err, &merr) || len(merr) != 1 || merr["bad"] != errBad {
+		t.Errorf("GetMulti error = %v; want a MultiError for bad", err)
+	}
+	if len(values) != 3 {
+		t.Errorf("got %d values; want 3", len(values))
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if got := values[key].String(); got != "v:"+key {
+			t.Errorf("values[%q] = %q; want %q", key, got, "v:"+key)
+		}
+	}
+	// One batch, then one retry of the failed key.
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("owner got %d requests; want 2", n)
+	}
+	if n := g.Stats.PeerLoads.Get(); n != 3 {
+		t.Errorf("PeerLoads = %d; want 3", n)
+	}
+	// The keys are counted and recorded as Gets are.
+	if n := g.Stats.ServedBytes.Get(); n != 9 {
+		t.Errorf("ServedBytes = %d; want 9", n)
+	}
+	for key, src := range map[string]GetSource{"a": SourcePeer, "b": SourcePeer, "c": SourcePeer, "bad": SourceError} {
+		if r, ok := records.Load(key); !ok || r.(GetRecord).Source != src {
+			t.Errorf("record of %q = %+v; want source %v", key, r, src)
+		}
+	}
+}
+
+type recorderFunc func(GetRecord)
+
+func (f recorderFunc) RecordGet(r GetRecord) { f(r) }
+
+func TestGetMultiHotCache(t *testing.T) {
+	const name = "TestGetMultiHotCache-group"
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("v:" + key)
+	})
+	newGroup(name, 1<<20, getter, NoPeers{})
+	_, ts := startPool(t, nil)
+	client := newHTTPPool("http://client", nil)
+	client.Set(ts.URL)
+	g := NewGroupOpts(name, 1<<20, getter, &GroupOptions{Peers: client, Unregistered: true})
+
+	// Batch-fetched values go to the hot cache as fetched ones do.
+	keys := testKeys(200)
+	if _, err := g.GetMulti(dummyCtx, keys); err != nil {
+		t.Fatal(err)
+	}
+	hot := g.hotCache.items()
+	if hot == 0 {
+		t.Fatal("no batch-fetched value was put in the hot cache")
+	}
+	if _, err := g.GetMulti(dummyCtx, keys); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.Stats.CacheHits.Get(); n != hot {
+		t.Errorf("CacheHits = %d; want the %d hot values", n, hot)
+	}
+}
+
+func TestServeMultiBodyLimit(t *testing.T) {
+	p := newHTTPPool("http://owner", nil)
+	body := bytes.NewReader(make([]byte, maxRequestBytes+1))
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest("POST", defaultBasePath+multiPath, body))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetMultiBadFrames(t *testing.T) {
+	var n [binary.MaxVarintLen64]byte
+	for _, tt := range []struct {
+		name, body, want string
+	}{
+		{"truncated", string(n[:binary.PutUvarint(n[:], 10)]) + "abc", "unexpected EOF"},
+		{"oversized", string(n[:binary.PutUvarint(n[:], 1<<40)]), "exceeds"},
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(protocolHeader, strconv.Itoa(ProtocolVersion))
+			io.WriteString(w, tt.body)
+		}))
+		client := newHTTPPool("http://client", nil)
+		client.Set(ts.URL)
+		peer, _ := client.PickPeer("k")
+		err := peer.(MultiGetter).GetMulti(dummyCtx, &pb.GetMultiRequest{Group: proto.String("g"), Key: []string{"k"}}, func(*pb.GetMultiResponse) error {
+			t.Errorf("%s frame: got a response", tt.name)
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s frame: GetMulti = %v; want an error containing %q", tt.name, err, tt.want)
+		}
+		ts.Close()
+	}
+}
+
+func TestMultiErrorString(t *testing.T) {
+	e := MultiError{"b": errors.New("x"), "a": errors.New("y")}
+	if got, want := e.Error(), fmt.Sprintf("groupcache: loading 2 keys failed; %q: y", "a"); got != want {
+		t.Errorf("Error() = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// negative.go keeps Bloom filters of keys the origin doesn't have, so
+// that Gets of them fail without asking a peer or the origin. Peers
+// exchange their filters, letting a storm of missing keys be stopped
+// by every process after each key was looked up once.
+
+package groupcache
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// negativeHashes is the number of bits set for each key of a filter.
+const negativeHashes = 4
+
+// defaultNegativeTTL is the NegativeFilterTTL of groups setting none.
+const defaultNegativeTTL = time.Minute
+
+// bloom is a Bloom filter.
+type bloom []uint64
+
+func newBloom(bits int) bloom {
+	return make(bloom, (bits+63)/64)
+}
+
+// positions calls f with the bits of key, found by double hashing.
+func (b bloom) positions(key string, f func(word int, mask uint64)) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	n := uint64(len(b)) * 64
+	for i := uint64(0); i < negativeHashes; i++ {
+		bit := (h1 + i*h2) % n
+		f(int(bit/64), 1<<(bit%64))
+	}
+}
+
+func (b bloom) add(key string) {
+	b.positions(key, func(w int, m uint64) { b[w] |= m })
+}
+
+func (b bloom) has(key string) bool {
+	if len(b) == 0 {
+		return false
+	}
+	ok := true
+	b.positions(key, func(w int, m uint64) { ok = ok && b[w]&m != 0 })
+	return ok
+}
+
+func (b bloom) or(o bloom) {
+	for i := range b {
+		b[i] |= o[i]
+	}
+}
+
+// negativeFilter remembers the keys a group found missing for between
+// one and two ttls, by rotating two filters, and holds the filters last
+// fetched from peers.
+type negativeFilter struct {
+	bits int
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	cur, prev bloom
+	rotated   time.Time
+	remote    bloom // union of the peers' filters, if fetched
+	fetched   time.Time
+}
+
+func newNegativeFilter(bits int, ttl time.Duration) *negativeFilter {
+	if ttl <= 0 {
+		ttl = defaultNegativeTTL
+	}
+	return &negativeFilter{
+		bits:    bits,
+		ttl:     ttl,
+		cur:     newBloom(bits),
+		prev:    newBloom(bits),
+		rotated: time.Now(),
+	}
+}
+
+// rotateLocked starts a new filter each ttl, forgetting the keys added
+// before the last rotation.
+func (f *negativeFilter) rotateLocked(now time.Time) {
+	switch age := now.Sub(f.rotated); {
+	case age >= 2*f.ttl:
+		f.cur, f.prev = newBloom(f.bits), newBloom(f.bits)
+	case age >= f.ttl:
+		f.cur, f.prev = newBloom(f.bits), f.cur
+	default:
+		return
+	}
+	f.rotated = now
+	if now.Sub(f.fetched) >= 2*f.ttl {
+		// Peers stopped sending their filters.
+		f.remote = nil
+	}
+}
+
+func (f *negativeFilter) add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rotateLocked(time.Now())
+	f.cur.add(key)
+}
+
+func (f *negativeFilter) has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rotateLocked(time.Now())
+	return f.cur.has(key) || f.prev.has(key) || f.remote.has(key)
+}
+
+// local returns the keys this process found missing, as sent to peers.
+// The filters fetched from peers are left out, so that peers don't
+// keep each other's keys alive past their ttl.
+func (f *negativeFilter) local() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rotateLocked(time.Now())
+	b := make([]byte, 8*len(f.cur))
+	for i := range f.cur {
+		binary.LittleEndian.PutUint64(b[8*i:], f.cur[i]|f.prev[i])
+	}
+	return b
+}
+
+// setRemote replaces the peers' filters by the union of filters, which
+// are in the format returned by local. Filters of another size are
+// skipped.
+func (f *negativeFilter) setRemote(filters [][]byte) {
+	remote := newBloom(f.bits)
+	for _, b := range filters {
+		if len(b) != 8*len(remote) {
+			continue
+		}
+		for i := range remote {
+			remote[i] |= binary.LittleEndian.Uint64(b[8*i:])
+		}
+	}
+	f.mu.Lock()
+	f.remote, f.fetched = remote, time.Now()
+	f.mu.Unlock()
+}
+
+// negativePath is the name, under the pool's BasePath, of the endpoint
+// serving a group's negative filter to peers.
+const negativePath = "_negative"
+
+func (p *HTTPPool) serveNegative(w http.ResponseWriter, r *http.Request) {
+	g := GetGroup(r.FormValue("group"))
+	if g == nil || g.negative == nil {
+		http.Error(w, "no negative filter for group: "+r.FormValue("group"), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(g.negative.local())
+}
+
+// negativeFilter fetches the peer's negative filter of group.
+func (h *httpGetter) negativeFilter(ctx context.Context, group string) ([]byte, error) {
+	u := h.baseURL + negativePath + "?" + url.Values{"group": {group}}.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned: %v", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// ExchangeNegativeFilters fetches the negative filters of the groups
+// with a NegativeFilterBits option from every peer, so that the keys
+// the peers found missing fail here without a load too. Peers that
+// could not be reached are listed in the returned error; their keys are
+// forgotten until a later exchange reaches them.
+func (p *HTTPPool) ExchangeNegativeFilters(ctx context.Context) error {
+	p.mu.Lock()
+	getters := make(map[string]*httpGetter, len(p.httpGetters))
+	for peer, g := range p.httpGetters {
+		getters[peer] = g
+	}
+	p.mu.Unlock()
+
+	var failed []string
+	for _, g := range registeredGroups() {
+		if g.negative == nil {
+			continue
+		}
+		var (
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			filters [][]byte
+		)
+		for peer, h := range getters {
+			wg.Add(1)
+			go func(peer string, h *httpGetter) {
+				defer wg.Done()
+				b, err := h.negativeFilter(ctx, g.name)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed = append(failed, g.name+" from "+peer+": "+err.Error())
+					return
+				}
+				filters = append(filters, b)
+			}(peer, h)
+		}
+		wg.Wait()
+		g.negative.setRemote(filters)
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("groupcache: fetching negative filters: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// SyncNegativeFilters calls ExchangeNegativeFilters every interval
+// until ctx is done, passing its errors to onError if it is not nil.
+// The interval should be well below the groups' NegativeFilterTTL.
+func (p *HTTPPool) SyncNegativeFilters(ctx context.Context, interval time.Duration, onError func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := p.ExchangeNegativeFilters(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
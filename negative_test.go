@@ -0,0 +1,119 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBloom(t *testing.T) {
+	b := newBloom(10 * 1000)
+	for i := 0; i < 1000; i++ {
+		b.add(fmt.Sprint("in", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !b.has(fmt.Sprint("in", i)) {
+			t.Fatalf("has(in%d) = false after add", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if b.has(fmt.Sprint("out", i)) {
+			fp++
+		}
+	}
+	if fp > 300 {
+		t.Errorf("%d false positives in 10000; want about 1%%", fp)
+	}
+}
+
+func TestNegativeFilter(t *testing.T) {
+	var loads int
+	g := NewGroupOpts("negative", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		if key == "missing" {
+			return ErrNotFound
+		}
+		return dest.SetString(key)
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, NegativeFilterBits: 1024, NegativeFilterTTL: 20 * time.Millisecond})
+
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(dummyCtx, "missing", StringSink(&s)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(missing) error = %v; want ErrNotFound", err)
+		}
+	}
+	if loads != 1 || g.Stats.NegativeHits.Get() != 2 {
+		t.Errorf("loads = %d, negative hits = %d; want 1, 2", loads, g.Stats.NegativeHits.Get())
+	}
+	if err := g.Get(dummyCtx, "missing", StringSink(&s), ForceRefresh()); !errors.Is(err, ErrNotFound) || loads != 2 {
+		t.Errorf("ForceRefresh Get = %v after %d loads; want a second load", err, loads)
+	}
+
+	// Missing keys are forgotten after two TTLs.
+	time.Sleep(45 * time.Millisecond)
+	g.Get(dummyCtx, "missing", StringSink(&s))
+	if loads != 3 {
+		t.Errorf("loads = %d after the filter's TTL; want 3", loads)
+	}
+}
+
+func TestExchangeNegativeFilters(t *testing.T) {
+	const name = "TestExchangeNegativeFilters-group"
+	g := NewGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return ErrNotFound
+	}), &GroupOptions{Peers: NoPeers{}, NegativeFilterBits: 1024})
+	var s string
+	g.Get(dummyCtx, "missing", StringSink(&s))
+
+	// Both pools serve the process-wide group, so the filter fetched
+	// from b is g's own; forgetting the local keys shows that it is
+	// used.
+	a, _ := startPool(t, nil)
+	_, tsb := startPool(t, nil)
+	const down = "http://127.0.0.1:1"
+	a.Set(a.self, tsb.URL, down)
+	if err := a.ExchangeNegativeFilters(dummyCtx); err == nil {
+		t.Error("ExchangeNegativeFilters succeeded with an unreachable peer")
+	}
+	g.negative.mu.Lock()
+	g.negative.cur, g.negative.prev = newBloom(1024), newBloom(1024)
+	g.negative.mu.Unlock()
+	if !g.negative.has("missing") {
+		t.Error("key missing at a peer is not in the filter after an exchange")
+	}
+	if g.negative.has("other") {
+		t.Error("filter has a key no peer found missing")
+	}
+	// Peers' keys are not sent on.
+	if b := g.negative.local(); !bloomEmpty(b) {
+		t.Error("local filter includes the peers' keys")
+	}
+}
+
+func bloomEmpty(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
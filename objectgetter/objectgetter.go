@@ -0,0 +1,253 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package objectgetter provides a groupcache Getter loading keys as
+// objects from an object store such as S3 or GCS, over their HTTP
+// APIs:
+//
+//	getter, err := objectgetter.New(objectgetter.Options{
+//		URL:  "https://my-bucket.s3.amazonaws.com/{key}",
+//		Sign: signV4, // adds the request's credentials
+//	})
+//	blobs := groupcache.NewGroup("blobs", 1<<30, getter)
+//
+// Keys made with RangeKey read part of an object. Failed fetches are
+// retried, and whole objects are checked against the hashes the store
+// reports.
+package objectgetter
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// Options configure the Getter made by New.
+type Options struct {
+	// URL is the template of object URLs. The string "{key}" is
+	// replaced by the object name, path-escaped except for slashes.
+	URL string
+
+	// Client makes the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Sign, if not nil, adds credentials to each request, for
+	// example an AWS Signature V4 or an OAuth bearer token.
+	Sign func(*http.Request) error
+
+	// Attempts is the number of tries of each fetch, retrying
+	// network errors, throttling and server errors.
+	// If blank, it defaults to 3.
+	Attempts int
+
+	// Backoff is the wait before the second try, doubled before each
+	// later one. If blank, it defaults to 100ms.
+	Backoff time.Duration
+
+	// MaxBytes rejects objects larger than this with
+	// groupcache.ErrValueTooLarge. If blank, it defaults to 64MB.
+	MaxBytes int64
+}
+
+// rangeSep separates an object name from its byte range in keys made
+// by RangeKey.
+const rangeSep = "#bytes="
+
+// RangeKey returns the key of length bytes of object starting at
+// offset. A negative length reads to the end of the object.
+func RangeKey(object string, offset, length int64) string {
+	end := ""
+	if length >= 0 {
+		end = strconv.FormatInt(offset+length-1, 10)
+	}
+	return object + rangeSep + strconv.FormatInt(offset, 10) + "-" + end
+}
+
+// parseKey splits a key into its object name and byte range, which is
+// empty for whole objects.
+func parseKey(key string) (object, byteRange string) {
+	i := strings.LastIndex(key, rangeSep)
+	if i < 0 {
+		return key, ""
+	}
+	return key[:i], "bytes=" + key[i+len(rangeSep):]
+}
+
+// errRetry marks failures worth another try.
+type errRetry struct{ error }
+
+func (e errRetry) Unwrap() error { return e.error }
+
+// New returns a Getter fetching objects as configured by o.
+func New(o Options) (groupcache.Getter, error) {
+	if !strings.Contains(o.URL, "{key}") {
+		return nil, fmt.Errorf("objectgetter: URL %q has no {key} placeholder", o.URL)
+	}
+	if _, err := url.Parse(strings.Replace(o.URL, "{key}", "k", -1)); err != nil {
+		return nil, fmt.Errorf("objectgetter: URL: %v", err)
+	}
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	if o.Attempts <= 0 {
+		o.Attempts = 3
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 100 * time.Millisecond
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = 64 << 20
+	}
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		var b []byte
+		var err error
+		wait := o.Backoff
+		for i := 0; i < o.Attempts; i++ {
+			if i > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return err
+				}
+				wait *= 2
+			}
+			b, err = o.fetch(ctx, key)
+			var retry errRetry
+			if err == nil || !errors.As(err, &retry) || ctx.Err() != nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return dest.SetBytes(b)
+	}), nil
+}
+
+// fetch makes one attempt at fetching key.
+func (o *Options) fetch(ctx context.Context, key string) ([]byte, error) {
+	object, byteRange := parseKey(key)
+	u := strings.Replace(o.URL, "{key}", escapeObject(object), -1)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	if o.Sign != nil {
+		if err := o.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return nil, errRetry{err}
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: object %q", groupcache.ErrNotFound, object)
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return nil, errRetry{fmt.Errorf("objectgetter: %s: %v", object, res.Status)}
+	case byteRange != "" && res.StatusCode != http.StatusPartialContent:
+		return nil, fmt.Errorf("objectgetter: %s: range read returned %v", object, res.Status)
+	case byteRange == "" && res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("objectgetter: %s: %v", object, res.Status)
+	}
+	// Read one byte past the limit to detect oversized objects.
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, o.MaxBytes+1))
+	if err != nil {
+		return nil, errRetry{err}
+	}
+	if int64(len(b)) > o.MaxBytes {
+		return nil, fmt.Errorf("%w: object %q is over %d bytes", groupcache.ErrValueTooLarge, object, o.MaxBytes)
+	}
+	if byteRange == "" {
+		// 只有整个对象才能和存储给出的哈希比较。
+		if err := verify(res.Header, b); err != nil {
+			return nil, errRetry{fmt.Errorf("objectgetter: %s: %v", object, err)}
+		}
+	}
+	return b, nil
+}
+
+// escapeObject path-escapes an object name, keeping its slashes.
+func escapeObject(object string) string {
+	parts := strings.Split(object, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// verify checks b against the hashes of an object named in h: S3's
+// SHA-256 checksum and single-part ETag, GCS's x-goog-hash and
+// Content-MD5.
+func verify(h http.Header, b []byte) error {
+	md5sum := md5.Sum(b)
+	if want := h.Get("X-Amz-Checksum-Sha256"); want != "" {
+		sum := sha256.Sum256(b)
+		if got := base64.StdEncoding.EncodeToString(sum[:]); got != want {
+			return fmt.Errorf("sha256 %s; want %s", got, want)
+		}
+	}
+	var md5s []string
+	for _, v := range h[http.CanonicalHeaderKey("X-Goog-Hash")] {
+		for _, f := range strings.Split(v, ",") {
+			if s := strings.TrimSpace(f); strings.HasPrefix(s, "md5=") {
+				md5s = append(md5s, s[len("md5="):])
+			}
+		}
+	}
+	if v := h.Get("Content-Md5"); v != "" {
+		md5s = append(md5s, v)
+	}
+	for _, want := range md5s {
+		if got := base64.StdEncoding.EncodeToString(md5sum[:]); got != want {
+			return fmt.Errorf("md5 %s; want %s", got, want)
+		}
+	}
+	// Objects uploaded in one part have the hex MD5 as their ETag;
+	// multipart ones contain a dash and can't be checked.
+	if etag := strings.Trim(h.Get("ETag"), `"`); isMD5Hex(etag) {
+		if got := hex.EncodeToString(md5sum[:]); got != etag {
+			return fmt.Errorf("md5 %s; want ETag %s", got, etag)
+		}
+	}
+	return nil
+}
+
+func isMD5Hex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return len(s) == 2*md5.Size && err == nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectgetter
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// fakeStore serves objects like S3: 206 for range reads and the MD5
+// of whole objects as their ETag.
+type fakeStore struct {
+	objects  map[string]string
+	failures int32 // requests to fail with 503 before serving
+	corrupt  bool  // serve a wrong ETag
+	requests int32
+}
+
+func (s *fakeStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.requests, 1)
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	if atomic.AddInt32(&s.failures, -1) >= 0 {
+		http.Error(w, "slow down", http.StatusServiceUnavailable)
+		return
+	}
+	obj, ok := s.objects[strings.TrimPrefix(r.URL.Path, "/bucket/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if rg := r.Header.Get("Range"); rg != "" {
+		// http.ServeContent handles ranges.
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(obj))
+		return
+	}
+	sum := md5.Sum([]byte(obj))
+	if s.corrupt {
+		sum[0]++
+	}
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Write([]byte(obj))
+}
+
+func newTestGetter(t *testing.T, s *fakeStore, o Options) groupcache.Getter {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	o.URL = srv.URL + "/bucket/{key}"
+	o.Backoff = time.Millisecond
+	o.Sign = func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer token")
+		return nil
+	}
+	g, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func get(g groupcache.Getter, key string) (string, error) {
+	var s string
+	err := g.Get(context.Background(), key, groupcache.StringSink(&s))
+	return s, err
+}
+
+func TestGetter(t *testing.T) {
+	s := &fakeStore{objects: map[string]string{
+		"a/b c.txt": "hello, world",
+	}}
+	g := newTestGetter(t, s, Options{})
+	tests := []struct {
+		key, want string
+	}{
+		{"a/b c.txt", "hello, world"},
+		{RangeKey("a/b c.txt", 7, 5), "world"},
+		{RangeKey("a/b c.txt", 7, -1), "world"},
+	}
+	for _, tt := range tests {
+		if got, err := get(g, tt.key); err != nil || got != tt.want {
+			t.Errorf("Get(%q) = %q, %v; want %q", tt.key, got, err, tt.want)
+		}
+	}
+	if _, err := get(g, "missing"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v; want ErrNotFound", err)
+	}
+}
+
+func TestGetterRetries(t *testing.T) {
+	s := &fakeStore{objects: map[string]string{"k": "v"}, failures: 2}
+	g := newTestGetter(t, s, Options{})
+	if got, err := get(g, "k"); err != nil || got != "v" {
+		t.Fatalf("Get = %q, %v; want v after retries", got, err)
+	}
+	if n := atomic.LoadInt32(&s.requests); n != 3 {
+		t.Errorf("requests = %d; want 3", n)
+	}
+
+	s = &fakeStore{objects: map[string]string{"k": "v"}, failures: 5}
+	g = newTestGetter(t, s, Options{Attempts: 2})
+	if _, err := get(g, "k"); err == nil {
+		t.Error("Get succeeded after exhausting attempts")
+	}
+	if n := atomic.LoadInt32(&s.requests); n != 2 {
+		t.Errorf("requests = %d; want 2", n)
+	}
+}
+
+func TestGetterHashAndSize(t *testing.T) {
+	s := &fakeStore{objects: map[string]string{"k": "value"}, corrupt: true}
+	g := newTestGetter(t, s, Options{})
+	if _, err := get(g, "k"); err == nil || !strings.Contains(err.Error(), "md5") {
+		t.Errorf("Get of corrupt object error = %v; want md5 mismatch", err)
+	}
+
+	s = &fakeStore{objects: map[string]string{"k": "value"}}
+	g = newTestGetter(t, s, Options{MaxBytes: 4})
+	if _, err := get(g, "k"); !errors.Is(err, groupcache.ErrValueTooLarge) {
+		t.Errorf("Get of large object error = %v; want ErrValueTooLarge", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	b := []byte("data")
+	sum := md5.Sum(b)
+	good := base64.StdEncoding.EncodeToString(sum[:])
+	tests := []struct {
+		h  http.Header
+		ok bool
+	}{
+		{http.Header{}, true},
+		{http.Header{"X-Goog-Hash": {"crc32c=AAAAAA==,md5=" + good}}, true},
+		{http.Header{"X-Goog-Hash": {"crc32c=AAAAAA==", "md5=AAAA"}}, false},
+		{http.Header{"Content-Md5": {good}}, true},
+		{http.Header{"Etag": {`"abc-3"`}}, true}, // multipart
+		{http.Header{"X-Amz-Checksum-Sha256": {"AAAA"}}, false},
+	}
+	for i, tt := range tests {
+		if err := verify(tt.h, b); (err == nil) != tt.ok {
+			t.Errorf("%d. verify(%v) = %v; want ok %v", i, tt.h, err, tt.ok)
+		}
+	}
+}
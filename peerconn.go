@@ -0,0 +1,135 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+)
+
+// PeerStats are statistics on the requests a pool sent to one peer.
+type PeerStats struct {
+	Peer        string
+	Requests    int64  // requests sent, including failed ones
+	InFlight    int64  // requests awaiting a response
+	ConnsReused int64  // requests sent on an existing connection
+	ConnsNew    int64  // requests that had to dial a new connection
+	DialErrors  int64  // failed dials, including TLS handshakes
+	Latency     string // moving average response latency
+}
+
+// peerMetrics counts the requests of an httpGetter.
+type peerMetrics struct {
+	requests    AtomicInt
+	inFlight    AtomicInt
+	connsReused AtomicInt
+	connsNew    AtomicInt
+	dialErrors  AtomicInt
+}
+
+// trace returns ctx with a trace that records how the request's
+// connection was obtained.
+func (m *peerMetrics) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				m.connsReused.Add(1)
+			} else {
+				m.connsNew.Add(1)
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err != nil {
+				m.dialErrors.Add(1)
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err != nil {
+				m.dialErrors.Add(1)
+			}
+		},
+	})
+}
+
+// roundTrip sends req to the peer, recording it in h.metrics.
+func (h *httpGetter) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+	tr := http.DefaultTransport
+	if h.transport != nil {
+		tr = h.transport(ctx)
+	}
+	if h.maxProtocol > 1 {
+		req.Header.Set(protocolHeader, strconv.Itoa(h.maxProtocol))
+	}
+	h.metrics.requests.Add(1)
+	h.metrics.inFlight.Add(1)
+	defer h.metrics.inFlight.Add(-1)
+	return tr.RoundTrip(req.WithContext(h.metrics.trace(ctx)))
+}
+
+// PeerStats returns statistics on the requests sent to each of the
+// pool's peers, sorted by peer.
+func (p *HTTPPool) PeerStats() []PeerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]PeerStats, 0, len(p.httpGetters))
+	for peer, h := range p.httpGetters {
+		stats = append(stats, PeerStats{
+			Peer:        peer,
+			Requests:    h.metrics.requests.Get(),
+			InFlight:    h.metrics.inFlight.Get(),
+			ConnsReused: h.metrics.connsReused.Get(),
+			ConnsNew:    h.metrics.connsNew.Get(),
+			DialErrors:  h.metrics.dialErrors.Get(),
+			Latency:     h.latency.value().String(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Peer < stats[j].Peer })
+	return stats
+}
+
+// newPeerTransport returns the transport of a pool whose options tune
+// its peer connections, or nil if they don't.
+func newPeerTransport(o *HTTPPoolOptions) *http.Transport {
+	if o.MaxConnsPerPeer == 0 && o.MaxIdleConnsPerPeer == 0 && o.IdleConnTimeout == 0 {
+		return nil
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxConnsPerHost = o.MaxConnsPerPeer
+	if o.MaxIdleConnsPerPeer > 0 {
+		tr.MaxIdleConnsPerHost = o.MaxIdleConnsPerPeer
+		// MaxIdleConns caps all peers together; don't let it undercut
+		// the per-peer setting.
+		tr.MaxIdleConns = 0
+	}
+	if o.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = o.IdleConnTimeout
+	}
+	return tr
+}
+
+// peerTransport returns the transport factory given to new peers.
+func (p *HTTPPool) peerTransport() func(context.Context) http.RoundTripper {
+	if p.Transport != nil || p.transport == nil {
+		return p.Transport
+	}
+	tr := p.transport
+	return func(context.Context) http.RoundTripper { return tr }
+}
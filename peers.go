@@ -35,6 +35,13 @@ type ProtoGetter interface {
 	Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error
 }
 
+// MultiGetter is optionally implemented by a ProtoGetter that can
+// fetch many keys of a group in one request. fn is called with the
+// response for each key as it arrives.
+type MultiGetter interface {
+	GetMulti(ctx context.Context, in *pb.GetMultiRequest, fn func(*pb.GetMultiResponse) error) error
+}
+
 // PeerPicker is the interface that must be implemented to locate
 // the peer that owns a specific key.
 type PeerPicker interface {
@@ -45,6 +52,20 @@ type PeerPicker interface {
 	PickPeer(key string) (peer ProtoGetter, ok bool)
 }
 
+// clientOnlyPicker is implemented by PeerPickers whose process only
+// routes requests to its peers and must not hold any cached data.
+type clientOnlyPicker interface {
+	clientOnly() bool
+}
+
+// ownerPicker is implemented by PeerPickers that may pick a replica
+// of a key rather than its owner. Requests served for a peer are sent
+// on to the owner, so that replicas don't forward them among
+// themselves.
+type ownerPicker interface {
+	pickOwner(key string) (peer ProtoGetter, ok bool)
+}
+
 // NoPeers is an implementation of PeerPicker that never finds a peer.
 type NoPeers struct{}
 
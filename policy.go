@@ -0,0 +1,103 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import "github.com/golang/groupcache/lru"
+
+// A CachePolicy creates the index of a group's main or hot cache, and
+// so decides which entries are evicted when the group is over its
+// cacheBytes. The returned cache must have no entry limit of its own
+// and must call onEvicted for every entry it drops.
+type CachePolicy func(onEvicted func(key lru.Key, value interface{})) lru.Interface
+
+// LRUPolicy evicts the least recently used entry. It is the default.
+func LRUPolicy() CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.Cache{OnEvicted: onEvicted}
+	}
+}
+
+// SegmentedLRUPolicy evicts entries hit only once before those hit
+// again, which keeps a group's working set through scans of one-off
+// keys. protectedRatio is the largest share of entries treated as hit
+// again; zero means 0.8.
+func SegmentedLRUPolicy(protectedRatio float64) CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.Segmented{ProtectedRatio: protectedRatio, OnEvicted: onEvicted}
+	}
+}
+
+// LFUPolicy evicts the least frequently used entry, which suits
+// workloads whose popular keys stay popular. Unlike the LRU policies,
+// it keeps keys that were popular once until others are hit as often.
+func LFUPolicy() CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.LFU{OnEvicted: onEvicted}
+	}
+}
+
+// ARCPolicy balances recency and frequency, adapting the balance to
+// the keys the group evicted and then loaded again.
+func ARCPolicy() CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.ARC{OnEvicted: onEvicted}
+	}
+}
+
+// TwoQueuePolicy admits keys to the main LRU list only when they are
+// loaded again soon after being evicted, which keeps a group's working
+// set through scans. inRatio and outRatio set the sizes of the queue
+// of new entries and of the remembered keys as shares of the entries;
+// zero means 0.25 and 0.5.
+func TwoQueuePolicy(inRatio, outRatio float64) CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.TwoQueue{InRatio: inRatio, OutRatio: outRatio, OnEvicted: onEvicted}
+	}
+}
+
+// ClockPolicy approximates LRUPolicy with the clock algorithm, which
+// makes cache hits cheaper at the cost of a coarser eviction order.
+func ClockPolicy() CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.Clock{OnEvicted: onEvicted}
+	}
+}
+
+// SampledLRUPolicy approximates LRUPolicy by evicting the least
+// recently used of samples random entries, which makes cache hits
+// cheaper. Zero samples means 5.
+func SampledLRUPolicy(samples int) CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.Sampled{Samples: samples, OnEvicted: onEvicted}
+	}
+}
+
+// FIFOPolicy evicts the entry loaded first, whatever its hits, which
+// suits groups whose keys are accessed uniformly.
+func FIFOPolicy() CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.Cache{Mode: lru.FIFO, OnEvicted: onEvicted}
+	}
+}
+
+// MRUPolicy evicts the most recently used entry, which keeps more of
+// a group cached when its keys are read in large cycles.
+func MRUPolicy() CachePolicy {
+	return func(onEvicted func(lru.Key, interface{})) lru.Interface {
+		return &lru.Cache{Mode: lru.MRU, OnEvicted: onEvicted}
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCachePolicy(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  CachePolicy
+		hotKept bool
+	}{
+		{"lru", nil, false},
+		{"segmented", SegmentedLRUPolicy(0), true},
+		{"lfu", LFUPolicy(), true},
+		{"arc", ARCPolicy(), true},
+	} {
+		loads := 0
+		g := NewGroupOpts("policy-"+tt.name, 200, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			loads++
+			return dest.SetString("0123456789")
+		}), &GroupOptions{Peers: NoPeers{}, Unregistered: true, MainCachePolicy: tt.policy})
+
+		get := func(key string) {
+			var s string
+			if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		get("hot")
+		get("hot")
+		for i := 0; i < 50; i++ {
+			get(fmt.Sprint("scan-", i))
+		}
+		loads = 0
+		get("hot")
+		if kept := loads == 0; kept != tt.hotKept {
+			t.Errorf("%s: hot key kept through a scan = %v; want %v", tt.name, kept, tt.hotKept)
+		}
+		if st := g.CacheStats(MainCache); st.Bytes > 200 || st.Evictions == 0 {
+			t.Errorf("%s: stats = %+v; want evictions within 200 bytes", tt.name, st)
+		}
+	}
+}
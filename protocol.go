@@ -0,0 +1,93 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// ProtocolVersion is the newest version of the HTTP peer protocol
+// spoken by this package.
+//
+// Version 1 is the original protocol, whose requests and responses
+// carry no version. Version 2 adds value expiration, conditional
+// fetches and multi-gets. Version 3 sends long keys in the body of
+// POST requests instead of in URLs.
+//
+// Peers negotiate the version per request: a client sends the newest
+// version it speaks, and the server answers in the older of that and
+// its own, naming the version in its response. Clusters can therefore
+// be upgraded one node at a time.
+const ProtocolVersion = 3
+
+// protocolHeader carries the protocol version of peer requests and
+// responses.
+const protocolHeader = "X-Groupcache-Protocol"
+
+// errProtocol is returned for requests a peer's protocol version
+// cannot express.
+var errProtocol = errors.New("groupcache: peer protocol version too old")
+
+// protocolVersion returns the version named by h, 1 if none is.
+func protocolVersion(h http.Header) int {
+	v, err := strconv.Atoi(h.Get(protocolHeader))
+	if err != nil || v < 1 {
+		return 1
+	}
+	return v
+}
+
+// maxProtocol returns the newest version the pool speaks.
+func (p *HTTPPool) maxProtocol() int {
+	if v := p.opts.ProtocolVersion; v > 0 && v < ProtocolVersion {
+		return v
+	}
+	return ProtocolVersion
+}
+
+// negotiate returns the version to answer the request in, and names it
+// in the response.
+func (p *HTTPPool) negotiate(w http.ResponseWriter, r *http.Request) int {
+	v := protocolVersion(r.Header)
+	if max := p.maxProtocol(); v > max {
+		v = max
+	}
+	w.Header().Set(protocolHeader, strconv.Itoa(v))
+	return v
+}
+
+// peerProtocol returns the version to speak to the peer: the version
+// of its last response, or the newest version before it answered.
+func (h *httpGetter) peerProtocol() int {
+	if v := atomic.LoadInt32(&h.protocol); v > 0 {
+		return int(v)
+	}
+	return h.maxProtocol
+}
+
+// observeProtocol records the version of the peer's response. Error
+// responses without a version may come from a proxy or a failing
+// handler rather than an old peer, so they are not taken as version 1.
+func (h *httpGetter) observeProtocol(res *http.Response) {
+	if res.Header.Get(protocolHeader) == "" && res.StatusCode != http.StatusOK {
+		return
+	}
+	atomic.StoreInt32(&h.protocol, int32(protocolVersion(res.Header)))
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// rates.go derives per-second rates of a group's counters over recent
+// windows, from samples of the counters kept in a ring buffer.
+
+package groupcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateWindows are the windows of the rates reported by Group.Rates.
+var RateWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+const (
+	// rateInterval is the time between samples of the counters.
+	rateInterval = 10 * time.Second
+
+	// rateSlots holds samples covering the longest window.
+	rateSlots = int(15*time.Minute/rateInterval) + 1
+)
+
+// RateStats are a group's rates averaged over a window.
+type RateStats struct {
+	Window      time.Duration
+	GetsPerSec  float64
+	HitsPerSec  float64 // gets answered from a local cache
+	BytesPerSec float64 // bytes of the values returned by Get
+	HitRatio    float64 // HitsPerSec / GetsPerSec
+}
+
+func (r *RateStats) add(o RateStats) {
+	r.GetsPerSec += o.GetsPerSec
+	r.HitsPerSec += o.HitsPerSec
+	r.BytesPerSec += o.BytesPerSec
+	r.HitRatio = 0
+	if r.GetsPerSec > 0 {
+		r.HitRatio = r.HitsPerSec / r.GetsPerSec
+	}
+}
+
+// rateSample is a reading of a group's counters.
+type rateSample struct {
+	t                 time.Time
+	gets, hits, bytes int64
+}
+
+// rateRing keeps a sample of the counters every rateInterval, taken by
+// the first Get or Rates call after the interval. Groups without Gets
+// take no samples, which is harmless: their counters don't change.
+type rateRing struct {
+	next int64 // unix nanoseconds of the next sample; accessed atomically
+
+	mu      sync.Mutex
+	samples [rateSlots]rateSample
+	head    int // index of the newest sample
+	n       int // number of samples
+}
+
+// due reports whether a sample should be recorded at now.
+func (r *rateRing) due(now time.Time) bool {
+	return now.UnixNano() >= atomic.LoadInt64(&r.next)
+}
+
+// record adds s, taken at s.t, unless another sample was recorded
+// since the ring was last due.
+func (r *rateRing) record(s rateSample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.due(s.t) {
+		return
+	}
+	r.head = (r.head + 1) % rateSlots
+	r.samples[r.head] = s
+	if r.n < rateSlots {
+		r.n++
+	}
+	atomic.StoreInt64(&r.next, s.t.Add(rateInterval).UnixNano())
+}
+
+// rates returns the rates from the samples to cur, for each of
+// RateWindows. Each window starts at the newest sample taken before it,
+// or at the oldest sample if the ring doesn't reach back that far.
+func (r *rateRing) rates(cur rateSample) []RateStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rs := make([]RateStats, len(RateWindows))
+	for i, w := range RateWindows {
+		rs[i].Window = w
+		if r.n == 0 {
+			continue
+		}
+		start := cur.t.Add(-w)
+		// 从最新的样本往前找窗口开始之前的样本。
+		from := r.samples[(r.head-r.n+1+rateSlots)%rateSlots]
+		for j := 0; j < r.n; j++ {
+			s := r.samples[(r.head-j+rateSlots)%rateSlots]
+			if !s.t.After(start) {
+				from = s
+				break
+			}
+		}
+		secs := cur.t.Sub(from.t).Seconds()
+		if secs <= 0 {
+			continue
+		}
+		rs[i].add(RateStats{
+			GetsPerSec:  float64(cur.gets-from.gets) / secs,
+			HitsPerSec:  float64(cur.hits-from.hits) / secs,
+			BytesPerSec: float64(cur.bytes-from.bytes) / secs,
+		})
+	}
+	return rs
+}
+
+// counters reads the group's counters at now.
+func (g *Group) counters(now time.Time) rateSample {
+	return rateSample{
+		t:     now,
+		gets:  g.Stats.Gets.Get(),
+		hits:  g.Stats.CacheHits.Get(),
+		bytes: g.Stats.ServedBytes.Get(),
+	}
+}
+
+// sampleRates records a sample of the counters if one is due.
+func (g *Group) sampleRates() {
+	if now := time.Now(); g.rates.due(now) {
+		g.rates.record(g.counters(now))
+	}
+}
+
+// Rates returns the group's rates averaged over each of RateWindows.
+// They cover less than their window while the group is younger.
+func (g *Group) Rates() []RateStats {
+	g.sampleRates()
+	return g.rates.rates(g.counters(time.Now()))
+}
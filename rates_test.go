@@ -0,0 +1,81 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateRing(t *testing.T) {
+	var r rateRing
+	start := time.Unix(1e9, 0)
+	// 10 gets a second, half of them hits, for 20 minutes, and
+	// 30 gets a second over the last minute.
+	var gets, hits int64
+	for sec := 0; sec <= 20*60; sec++ {
+		now := start.Add(time.Duration(sec) * time.Second)
+		if r.due(now) {
+			r.record(rateSample{t: now, gets: gets, hits: hits, bytes: 100 * gets})
+		}
+		if sec >= 19*60 {
+			gets += 30
+		} else {
+			gets += 10
+			hits += 5
+		}
+	}
+	now := start.Add(20*time.Minute + time.Second)
+	rs := r.rates(rateSample{t: now, gets: gets, hits: hits, bytes: 100 * gets})
+	if len(rs) != len(RateWindows) {
+		t.Fatalf("got %d rates; want %d", len(rs), len(RateWindows))
+	}
+	want := []struct{ gets, ratio float64 }{
+		{30, 0},
+		{(4*60*10 + 60*30) / 300.0, 4 * 60 * 5 / (4*60*10 + 60*30.0)},
+		{(14*60*10 + 60*30) / 900.0, 14 * 60 * 5 / (14*60*10 + 60*30.0)},
+	}
+	for i, w := range want {
+		r := rs[i]
+		if r.Window != RateWindows[i] || !near(r.GetsPerSec, w.gets) || !near(r.HitRatio, w.ratio) || !near(r.BytesPerSec, 100*w.gets) {
+			t.Errorf("rates over %v = %+v; want %v gets/s, hit ratio %v", RateWindows[i], r, w.gets, w.ratio)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 0.01*b+1e-9 && b-a < 0.01*b+1e-9
+}
+
+func TestGroupRates(t *testing.T) {
+	g := NewGroupOpts("rates", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value")
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true})
+	var s string
+	for i := 0; i < 3; i++ {
+		g.Get(dummyCtx, "k", StringSink(&s))
+	}
+	if n := g.Stats.ServedBytes.Get(); n != 15 {
+		t.Errorf("ServedBytes = %d; want 15", n)
+	}
+	time.Sleep(10 * time.Millisecond)
+	rs := g.Rates()
+	if len(rs) != 3 || rs[0].GetsPerSec <= 0 || !near(rs[0].HitRatio, 2.0/3) {
+		t.Errorf("Rates = %+v; want 3 windows with 2/3 hits", rs)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import "time"
+
+// A GetSource tells where the value of a Get came from.
+type GetSource int
+
+const (
+	SourceMainCache GetSource = iota + 1
+	SourceHotCache
+	SourcePeer   // fetched from the key's owner
+	SourceOrigin // loaded by the group's Getter
+	SourceShared // from the load of a concurrent Get of the key
+	SourceError  // the Get failed
+)
+
+var sourceNames = map[GetSource]string{
+	SourceMainCache: "main",
+	SourceHotCache:  "hot",
+	SourcePeer:      "peer",
+	SourceOrigin:    "origin",
+	SourceShared:    "shared",
+	SourceError:     "error",
+}
+
+func (s GetSource) String() string {
+	if n, ok := sourceNames[s]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// ParseGetSource returns the GetSource whose String is s.
+func ParseGetSource(s string) (GetSource, bool) {
+	for src, n := range sourceNames {
+		if n == s {
+			return src, true
+		}
+	}
+	return 0, false
+}
+
+// A GetRecord describes a Get served by a group.
+type GetRecord struct {
+	Time   time.Time // when the Get started
+	Group  string
+	Key    string
+	Size   int // of the value; zero if the Get failed
+	Source GetSource
+}
+
+// A GetRecorder is told of the Gets served by the groups naming it in
+// their options; see the trace package. RecordGet is called by the
+// goroutine of each Get, so it must be fast and safe for concurrent
+// use.
+type GetRecorder interface {
+	RecordGet(r GetRecord)
+}
+
+// record tells the group's recorder, if any, of a Get of key started
+// at start.
+func (g *Group) record(start time.Time, key string, size int, src GetSource) {
+	if g.opts.Recorder != nil {
+		g.opts.Recorder.RecordGet(GetRecord{Time: start, Group: g.name, Key: key, Size: size, Source: src})
+	}
+}
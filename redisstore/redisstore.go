@@ -0,0 +1,409 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package redisstore adapts a Redis server to groupcache, so that a
+// group can serve as an in-process cache in front of it. A Store is
+// both a groupcache.BackingStore, for Redis used as a shared
+// second-level cache, and, through its Getter, the origin of a group
+// whose values live in Redis:
+//
+//	store := redisstore.New("10.0.0.7:6379", &redisstore.Options{KeyPrefix: "users:"})
+//	group := groupcache.NewGroup("users", 64<<20, store.Getter())
+//
+// The Getter sends the loads that arrive while a request is in flight
+// together in one pipeline, which makes Group.GetMulti of many missing
+// keys cost about one round trip.
+//
+// Stores speak the Redis protocol (RESP) themselves, to a single server
+// or proxy; Redis Cluster redirections are not followed.
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// Options configure a Store.
+type Options struct {
+	// KeyPrefix is prepended to every key.
+	KeyPrefix string
+
+	// Expiration is how long values set by the store are kept.
+	// If blank, they don't expire.
+	Expiration time.Duration
+
+	// Password, if set, authenticates new connections.
+	Password string
+
+	// DB selects the database of new connections.
+	DB int
+
+	// Timeout bounds each request, on top of its context.
+	// If blank, it defaults to 1s.
+	Timeout time.Duration
+
+	// MaxIdleConns is the number of idle connections kept.
+	// If blank, it defaults to 4.
+	MaxIdleConns int
+}
+
+// A Store reads and writes values in Redis. It is safe for concurrent
+// use.
+type Store struct {
+	addr string
+	opts Options
+	idle chan *conn
+
+	mu       sync.Mutex
+	pending  []*call // Getter loads waiting for the next pipeline
+	flushing bool    // whether a goroutine is sending pipelines
+
+	pipelines int64 // pipelines sent for Getter loads, for tests
+}
+
+// New returns a store using the Redis server at addr, a host:port.
+func New(addr string, o *Options) *Store {
+	s := &Store{addr: addr}
+	if o != nil {
+		s.opts = *o
+	}
+	if s.opts.Timeout <= 0 {
+		s.opts.Timeout = time.Second
+	}
+	if s.opts.MaxIdleConns <= 0 {
+		s.opts.MaxIdleConns = 4
+	}
+	s.idle = make(chan *conn, s.opts.MaxIdleConns)
+	return s
+}
+
+// Get returns the value stored under key.
+func (s *Store) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	values, errs, err := s.getMulti(ctx, []string{key})
+	if err == nil {
+		err = errs[key]
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok = values[key]
+	return value, ok, nil
+}
+
+// GetMulti returns the values stored under keys, fetched with one
+// pipeline. Keys with no value are missing from the result. If the
+// server answered some keys with an error, the values of the others
+// are returned with a groupcache.MultiError of those keys.
+func (s *Store) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, errs, err := s.getMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return values, groupcache.MultiError(errs)
+	}
+	return values, nil
+}
+
+// getMulti is GetMulti with the error replies of keys apart from the
+// error of the whole pipeline.
+func (s *Store) getMulti(ctx context.Context, keys []string) (values map[string][]byte, errs map[string]error, err error) {
+	values = make(map[string][]byte, len(keys))
+	errs = make(map[string]error)
+	if len(keys) == 0 {
+		return values, errs, nil
+	}
+	err = s.do(ctx, func(c *conn) error {
+		for _, key := range keys {
+			c.writeCommand("GET", s.opts.KeyPrefix+key)
+		}
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+		// 所有回复都要读完，连接才能复用。
+		for _, key := range keys {
+			v, err := c.readReply()
+			switch {
+			case err != nil && !isRedisError(err):
+				return err
+			case err != nil:
+				errs[key] = err
+			case v != nil:
+				b, ok := v.([]byte)
+				if !ok {
+					return fmt.Errorf("redisstore: unexpected reply %v to GET", v)
+				}
+				values[key] = b
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return values, errs, nil
+}
+
+// Set stores value under key.
+func (s *Store) Set(ctx context.Context, key string, value []byte) error {
+	return s.do(ctx, func(c *conn) error {
+		args := []string{"SET", s.opts.KeyPrefix + key, string(value)}
+		if d := s.opts.Expiration; d > 0 {
+			args = append(args, "PX", strconv.FormatInt(int64(d/time.Millisecond), 10))
+		}
+		c.writeCommand(args...)
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+		_, err := c.readReply()
+		return err
+	})
+}
+
+// Close closes the idle connections.
+func (s *Store) Close() error {
+	for {
+		select {
+		case c := <-s.idle:
+			c.nc.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+var _ groupcache.BackingStore = (*Store)(nil)
+
+// Getter returns a Getter loading keys from Redis. Keys with no value
+// fail with groupcache.ErrNotFound.
+func (s *Store) Getter() groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		c := &call{key: key, done: make(chan struct{})}
+		s.mu.Lock()
+		s.pending = append(s.pending, c)
+		if !s.flushing {
+			s.flushing = true
+			go s.flush()
+		}
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if c.err != nil {
+			return c.err
+		}
+		if !c.ok {
+			return fmt.Errorf("%w: no redis value for %q", groupcache.ErrNotFound, key)
+		}
+		return dest.SetBytes(c.value)
+	})
+}
+
+// A call is a Getter load waiting for its pipeline.
+type call struct {
+	key   string
+	done  chan struct{}
+	value []byte
+	ok    bool
+	err   error
+}
+
+// flush sends the pending loads in pipelines, one at a time, until
+// none are left. Loads arriving meanwhile wait for the next pipeline.
+func (s *Store) flush() {
+	for {
+		s.mu.Lock()
+		calls := s.pending
+		s.pending = nil
+		if len(calls) == 0 {
+			s.flushing = false
+			s.mu.Unlock()
+			return
+		}
+		s.pipelines++
+		s.mu.Unlock()
+
+		keys := make([]string, 0, len(calls))
+		seen := make(map[string]bool, len(calls))
+		for _, c := range calls {
+			if !seen[c.key] {
+				seen[c.key] = true
+				keys = append(keys, c.key)
+			}
+		}
+		// The callers may give up, but the pipeline is finished so
+		// the others get their values.
+		values, errs, err := s.getMulti(context.Background(), keys)
+		for _, c := range calls {
+			c.value, c.ok = values[c.key]
+			c.err = err
+			if c.err == nil {
+				c.err = errs[c.key]
+			}
+			close(c.done)
+		}
+	}
+}
+
+// do runs f on a connection. Connections are only reused when every
+// reply was read.
+func (s *Store) do(ctx context.Context, f func(c *conn) error) error {
+	c, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(s.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.nc.SetDeadline(deadline)
+	err = f(c)
+	if err != nil && !isRedisError(err) {
+		c.nc.Close()
+		return err
+	}
+	s.put(c)
+	return err
+}
+
+func (s *Store) conn(ctx context.Context) (*conn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: s.opts.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	nc.SetDeadline(time.Now().Add(s.opts.Timeout))
+	var setup [][]string
+	if s.opts.Password != "" {
+		setup = append(setup, []string{"AUTH", s.opts.Password})
+	}
+	if s.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.opts.DB)})
+	}
+	for _, args := range setup {
+		c.writeCommand(args...)
+		if err := c.w.Flush(); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		if _, err := c.readReply(); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redisstore: %s: %v", args[0], err)
+		}
+	}
+	return c, nil
+}
+
+func (s *Store) put(c *conn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// writeCommand buffers a command as a RESP array of bulk strings.
+func (c *conn) writeCommand(args ...string) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+}
+
+// A redisError is an error reply of the server. The connection stays
+// usable after one.
+type redisError string
+
+func (e redisError) Error() string { return "redisstore: " + string(e) }
+
+func isRedisError(err error) bool {
+	var re redisError
+	return errors.As(err, &re)
+}
+
+// readReply reads a reply: a string for simple strings, an int64 for
+// integers, a []byte or nil for bulk strings and an []interface{} for
+// arrays.
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil && !isRedisError(err) {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+}
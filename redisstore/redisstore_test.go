@@ -0,0 +1,223 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// fakeRedis serves GET, SET and AUTH from a map.
+type fakeRedis struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	m    map[string]string
+	cmds []string
+	gate chan bool // if non-nil, each GET waits for a value
+}
+
+func startFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, m: make(map[string]string)}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		l, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		b := make([]byte, l+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:l])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.cmds = append(f.cmds, strings.Join(args, " "))
+		gate := f.gate
+		f.mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[1] != "secret" {
+				io.WriteString(c, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			io.WriteString(c, "+OK\r\n")
+		case "GET":
+			if gate != nil {
+				<-gate
+			}
+			if strings.HasSuffix(args[1], "bad") {
+				io.WriteString(c, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+				continue
+			}
+			f.mu.Lock()
+			v, ok := f.m[args[1]]
+			f.mu.Unlock()
+			if !ok {
+				io.WriteString(c, "$-1\r\n")
+				continue
+			}
+			fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+		case "SET":
+			f.mu.Lock()
+			f.m[args[1]] = args[2]
+			f.mu.Unlock()
+			io.WriteString(c, "+OK\r\n")
+		default:
+			io.WriteString(c, "-ERR unknown command\r\n")
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	f := startFakeRedis(t)
+	defer f.ln.Close()
+	s := New(f.ln.Addr().String(), &Options{KeyPrefix: "p:", Password: "secret"})
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.Set(ctx, "a", []byte("1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if f.m["p:a"] != "1\r\n" {
+		t.Errorf("stored %q; want the prefixed key set", f.m)
+	}
+	if v, ok, err := s.Get(ctx, "a"); string(v) != "1\r\n" || !ok || err != nil {
+		t.Errorf("Get = %q, %v, %v", v, ok, err)
+	}
+	got, err := s.GetMulti(ctx, []string{"a", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || string(got["a"]) != "1\r\n" {
+		t.Errorf("GetMulti = %q; want only a", got)
+	}
+
+	// An error reply fails only its key.
+	got, err = s.GetMulti(ctx, []string{"a", "bad"})
+	var merr groupcache.MultiError
+	if !errors.As(err, &merr) || len(merr) != 1 || !strings.Contains(fmt.Sprint(merr["bad"]), "WRONGTYPE") {
+		t.Errorf("GetMulti error = %v; want a MultiError for bad", err)
+	}
+	if len(got) != 1 || string(got["a"]) != "1\r\n" {
+		t.Errorf("GetMulti with an error reply = %q; want a", got)
+	}
+	if _, _, err := s.Get(ctx, "bad"); err == nil || !strings.Contains(err.Error(), "WRONGTYPE") {
+		t.Errorf("Get(bad) = %v; want the WRONGTYPE error", err)
+	}
+
+	bad := New(f.ln.Addr().String(), &Options{Password: "wrong"})
+	if _, _, err := bad.Get(ctx, "a"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Get with a wrong password = %v", err)
+	}
+}
+
+func TestGetterPipelines(t *testing.T) {
+	f := startFakeRedis(t)
+	defer f.ln.Close()
+	for i := 0; i < 10; i++ {
+		f.m[fmt.Sprint(i)] = "v" + fmt.Sprint(i)
+	}
+	f.gate = make(chan bool)
+	s := New(f.ln.Addr().String(), nil)
+	defer s.Close()
+	getter := s.Getter()
+
+	var wg sync.WaitGroup
+	// The last load gets an error reply, which fails only that load.
+	errs := make([]error, 13)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprint(i)
+			if i == 12 {
+				key = "bad"
+			}
+			var v string
+			errs[i] = getter.Get(context.Background(), key, groupcache.StringSink(&v))
+			if errs[i] == nil && v != "v"+fmt.Sprint(i) {
+				errs[i] = fmt.Errorf("got %q", v)
+			}
+		}(i)
+	}
+	// Hold the first pipeline at the server while the other loads
+	// queue behind it, then let them all through.
+	time.Sleep(50 * time.Millisecond)
+	close(f.gate)
+	wg.Wait()
+	for i, err := range errs {
+		if i < 10 && err != nil {
+			t.Errorf("load %d: %v", i, err)
+		}
+		if (i == 10 || i == 11) && !errors.Is(err, groupcache.ErrNotFound) {
+			t.Errorf("load of missing key %d = %v; want ErrNotFound", i, err)
+		}
+		if i == 12 && (err == nil || !strings.Contains(err.Error(), "WRONGTYPE")) {
+			t.Errorf("load of bad = %v; want the WRONGTYPE error", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pipelines > 2 {
+		t.Errorf("sent %d pipelines for 13 concurrent loads; want at most 2", s.pipelines)
+	}
+}
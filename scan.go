@@ -0,0 +1,86 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"sort"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// A ScanItem describes a key held in a group's main cache.
+type ScanItem struct {
+	Key   string
+	Bytes int64 // size of the value
+}
+
+// Scan lists up to limit keys held in this process's main cache, in
+// key order, starting after cursor. An empty cursor starts from the
+// beginning. next is the cursor of the following page, or empty once
+// the scan is complete.
+//
+// Scan is meant for debugging, export and invalidation tooling. Each
+// call inspects the whole cache; keys added or evicted between calls
+// may be missed or listed.
+func (g *Group) Scan(ctx context.Context, cursor string, limit int) (items []ScanItem, next string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if g.isClosed() {
+		return nil, "", ErrGroupClosed
+	}
+	if limit <= 0 {
+		return nil, "", nil
+	}
+	c := &g.mainCache
+	c.mu.RLock()
+	if c.lru != nil {
+		c.lru.Range(func(k lru.Key, v interface{}) bool {
+			if key := k.(string); key > cursor {
+				items = append(items, ScanItem{Key: key, Bytes: int64(v.(*cacheEntry).len())})
+			}
+			return true
+		})
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	if len(items) > limit {
+		items = items[:limit]
+		next = items[limit-1].Key
+	}
+	return items, next, nil
+}
+
+// HotKeys lists up to n keys of the group's hot cache, which holds
+// copies of values owned by peers that this process asked for often,
+// most recently used first.
+func (g *Group) HotKeys(n int) []ScanItem {
+	items := []ScanItem{}
+	c := &g.hotCache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.lru == nil || n <= 0 {
+		return items
+	}
+	c.lru.Range(func(k lru.Key, v interface{}) bool {
+		items = append(items, ScanItem{Key: k.(string), Bytes: int64(v.(*cacheEntry).len())})
+		return len(items) < n
+	})
+	return items
+}
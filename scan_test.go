@@ -0,0 +1,65 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	g := NewGroupOpts("scan", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("v" + key)
+	}), &GroupOptions{Peers: NoPeers{}, Unregistered: true})
+	const n = 25
+	for i := n - 1; i >= 0; i-- {
+		var s string
+		if err := g.Get(dummyCtx, fmt.Sprintf("key-%02d", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var all []ScanItem
+	cursor, pages := "", 0
+	for {
+		items, next, err := g.Scan(dummyCtx, cursor, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, items...)
+		pages++
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if pages != 3 || len(all) != n {
+		t.Fatalf("scanned %d items in %d pages; want %d in 3", len(all), pages, n)
+	}
+	for i, it := range all {
+		if want := fmt.Sprintf("key-%02d", i); it.Key != want || it.Bytes != int64(len("v"+want)) {
+			t.Errorf("item %d = %+v; want key %s", i, it, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(dummyCtx)
+	cancel()
+	if _, _, err := g.Scan(ctx, "", 10); err != context.Canceled {
+		t.Errorf("Scan with canceled context = %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// normalizePeerURL returns the canonical form of a peer base URL, so
+// that spellings of the same address compare equal: the scheme and
+// host are lowercased, default ports and trailing slashes dropped and
+// IP addresses printed in their canonical form.
+func normalizePeerURL(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(s, "/")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host, port := splitHostPort(u.Host)
+	host = strings.ToLower(host)
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" || strings.Contains(host, ":") {
+		host = net.JoinHostPort(host, port)
+		host = strings.TrimSuffix(host, ":")
+	}
+	return scheme + "://" + host + strings.TrimSuffix(u.Path, "/")
+}
+
+// splitHostPort is like net.SplitHostPort but accepts hosts without a
+// port, including bracketed IPv6 addresses.
+func splitHostPort(hostport string) (host, port string) {
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		return h, p
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]"), ""
+}
+
+var (
+	localHostsOnce sync.Once
+	localHosts     map[string]bool
+)
+
+// isLocalHost reports whether host names this machine: a loopback or
+// interface address, localhost, or the machine's hostname.
+func isLocalHost(host string) bool {
+	localHostsOnce.Do(func() {
+		localHosts = map[string]bool{"localhost": true}
+		if name, err := os.Hostname(); err == nil {
+			localHosts[strings.ToLower(name)] = true
+		}
+		addrs, _ := net.InterfaceAddrs()
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok {
+				localHosts[ipn.IP.String()] = true
+			}
+		}
+	})
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || localHosts[ip.String()]
+	}
+	return localHosts[strings.ToLower(host)]
+}
+
+// selfMatcher recognizes the peer URLs that refer to this process.
+type selfMatcher struct {
+	urls   map[string]bool // normalized self and aliases
+	scheme string
+	port   string
+	local  bool // whether self's host is this machine
+}
+
+func newSelfMatcher(self string, aliases []string) *selfMatcher {
+	m := &selfMatcher{urls: make(map[string]bool)}
+	for _, s := range append([]string{self}, aliases...) {
+		m.urls[normalizePeerURL(s)] = true
+	}
+	if u, err := url.Parse(normalizePeerURL(self)); err == nil {
+		host, port := splitHostPort(u.Host)
+		m.scheme, m.port = u.Scheme, port
+		m.local = isLocalHost(host)
+	}
+	return m
+}
+
+// match reports whether peer refers to this process: it is self or an
+// alias once normalized, or, when self names this machine, it names
+// this machine on the same scheme and port.
+func (m *selfMatcher) match(peer string) bool {
+	n := normalizePeerURL(peer)
+	if m.urls[n] {
+		return true
+	}
+	if !m.local {
+		return false
+	}
+	u, err := url.Parse(n)
+	if err != nil {
+		return false
+	}
+	host, port := splitHostPort(u.Host)
+	return u.Scheme == m.scheme && port == m.port && isLocalHost(host)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import "time"
+
+// settings are the options of a group that may change while it
+// serves. They are replaced as a whole, never modified, so that Gets
+// read them without locking.
+type settings struct {
+	cacheBytes     int64 // limit for sum of mainCache and hotCache size
+	ttl, ttlJitter time.Duration
+	loadSem        chan struct{} // a token per Getter call in progress, if bounded
+}
+
+func (g *Group) current() *settings {
+	return g.settings.Load().(*settings)
+}
+
+// update replaces the group's settings by a copy changed by f.
+func (g *Group) update(f func(s *settings)) {
+	g.settingsMu.Lock()
+	defer g.settingsMu.Unlock()
+	s := *g.current()
+	f(&s)
+	g.settings.Store(&s)
+}
+
+// CacheBytes returns the limit on the size of the group's caches.
+func (g *Group) CacheBytes() int64 {
+	return g.current().cacheBytes
+}
+
+// SetCacheBytes changes the limit on the size of the group's caches.
+// Values are evicted right away if the caches hold more; otherwise the
+// cached values are kept.
+func (g *Group) SetCacheBytes(n int64) {
+	g.update(func(s *settings) { s.cacheBytes = n })
+	if n > 0 {
+		g.evictTo(n)
+	}
+}
+
+// SetTTL changes the TTL and TTLJitter options. Only values loaded
+// afterwards get the new TTL.
+func (g *Group) SetTTL(ttl, jitter time.Duration) {
+	g.update(func(s *settings) { s.ttl, s.ttlJitter = ttl, jitter })
+}
+
+// SetMaxConcurrentLoads changes the MaxConcurrentLoads option. Loads
+// in progress finish under the old limit and don't count against the
+// new one, so the Getter may briefly see more calls than either.
+func (g *Group) SetMaxConcurrentLoads(n int) {
+	g.update(func(s *settings) {
+		if s.loadSem != nil && cap(s.loadSem) == n {
+			return
+		}
+		s.loadSem = nil
+		if n > 0 {
+			s.loadSem = make(chan struct{}, n)
+		}
+	})
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator runs a deterministic model of a groupcache cluster.
+//
+// A simulation has N virtual nodes, a virtual clock, and a script of
+// membership and workload events. Nodes follow groupcache's lookup
+// process (main cache, hot cache, owner peer, origin) and cache
+// sizing rules, so design changes such as a different hash function
+// or hot cache policy can be compared by their hit rates and load
+// distribution before being deployed. Given the same Config and
+// script, a simulation always produces the same Report.
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/groupcache/consistenthash"
+	"github.com/golang/groupcache/lru"
+)
+
+// Config describes the simulated cluster.
+type Config struct {
+	// Nodes is the number of nodes present at time zero, named
+	// "node0" through "node<Nodes-1>".
+	Nodes int
+
+	// Replicas is the number of consistent hash replicas per node.
+	// If blank, it defaults to 50.
+	Replicas int
+
+	// HashFn is the consistent hash function.
+	// If blank, it defaults to crc32.ChecksumIEEE.
+	HashFn consistenthash.Hash
+
+	// CacheBytes is each node's limit for the sum of its main and hot
+	// cache sizes.
+	CacheBytes int64
+
+	// HotCacheOdds makes one in HotCacheOdds values fetched from a
+	// peer be mirrored in the hot cache. If blank, it defaults to 10,
+	// as in groupcache. A negative value disables the hot cache.
+	HotCacheOdds int
+
+	// ValueSize returns the size of key's value.
+	// If nil, every value is 1KB.
+	ValueSize func(key string) int
+
+	// Seed seeds the simulation's random choices.
+	Seed int64
+}
+
+// EventKind is the kind of a scripted Event.
+type EventKind int
+
+const (
+	// Get requests Key from Node.
+	Get EventKind = iota
+
+	// Join adds Node, with empty caches, to every node's ring.
+	Join
+
+	// Leave removes Node and its caches from the cluster.
+	Leave
+)
+
+// An Event is something happening at virtual time At.
+type Event struct {
+	At   time.Duration
+	Kind EventKind
+	Node string
+	Key  string
+}
+
+// A Workload generates Get events at a steady rate over a period of
+// virtual time, each to a random live node.
+type Workload struct {
+	Start, End time.Duration
+
+	// Rate is the number of Gets per virtual second.
+	Rate float64
+
+	// Key picks the key of each Get.
+	Key func(r *rand.Rand) string
+}
+
+// NodeReport holds one node's counters.
+type NodeReport struct {
+	Name string
+
+	Gets         int64 // Gets received from clients
+	MainHits     int64 // client or peer Gets answered by the main cache
+	HotHits      int64 // client or peer Gets answered by the hot cache
+	PeerRequests int64 // requests served for other nodes
+	OriginLoads  int64 // loads from the origin
+	Evictions    int64
+}
+
+// A Report summarizes a simulation.
+type Report struct {
+	Duration time.Duration
+	Nodes    []NodeReport // sorted by name; includes departed nodes
+
+	Gets         int64
+	CacheHits    int64 // Gets answered without an origin load
+	OriginLoads  int64
+	PeerRequests int64
+}
+
+// HitRate returns the fraction of Gets answered without an origin load.
+func (r Report) HitRate() float64 {
+	if r.Gets == 0 {
+		return 0
+	}
+	return float64(r.CacheHits) / float64(r.Gets)
+}
+
+// LoadImbalance returns the ratio of the busiest node's served
+// requests (client Gets plus peer requests) to the mean. 1 is a
+// perfectly even distribution.
+func (r Report) LoadImbalance() float64 {
+	var sum, max float64
+	for _, n := range r.Nodes {
+		served := float64(n.Gets + n.PeerRequests)
+		sum += served
+		max = math.Max(max, served)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return max / (sum / float64(len(r.Nodes)))
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "duration %v: %d gets, hit rate %.3f, %d origin loads, %d peer requests, imbalance %.2f\n",
+		r.Duration, r.Gets, r.HitRate(), r.OriginLoads, r.PeerRequests, r.LoadImbalance())
+	for _, n := range r.Nodes {
+		fmt.Fprintf(&b, "  %s: gets=%d main_hits=%d hot_hits=%d peer_requests=%d origin_loads=%d evictions=%d\n",
+			n.Name, n.Gets, n.MainHits, n.HotHits, n.PeerRequests, n.OriginLoads, n.Evictions)
+	}
+	return b.String()
+}
+
+// A Sim is a simulation in progress. Build one with New, script it
+// with Schedule and AddWorkload, then call Run.
+type Sim struct {
+	cfg    Config
+	rnd    *rand.Rand
+	events []Event
+	nodes  map[string]*node
+	ring   *consistenthash.Map
+	report Report
+}
+
+// New returns a simulation of the configured cluster.
+func New(c Config) *Sim {
+	if c.Replicas == 0 {
+		c.Replicas = 50
+	}
+	if c.HotCacheOdds == 0 {
+		c.HotCacheOdds = 10
+	}
+	if c.ValueSize == nil {
+		c.ValueSize = func(string) int { return 1 << 10 }
+	}
+	s := &Sim{
+		cfg:   c,
+		rnd:   rand.New(rand.NewSource(c.Seed)),
+		nodes: make(map[string]*node),
+	}
+	for i := 0; i < c.Nodes; i++ {
+		s.join(fmt.Sprintf("node%d", i))
+	}
+	return s
+}
+
+// Schedule adds scripted events.
+func (s *Sim) Schedule(events ...Event) {
+	s.events = append(s.events, events...)
+}
+
+// AddWorkload schedules the Gets of w. Gets are addressed to a node
+// chosen when they run, among the nodes live at that time.
+func (s *Sim) AddWorkload(w Workload) {
+	if w.Rate <= 0 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / w.Rate)
+	for at := w.Start; at < w.End; at += interval {
+		s.events = append(s.events, Event{At: at, Kind: Get, Key: w.Key(s.rnd)})
+	}
+}
+
+// Run plays every scheduled event in virtual time order and reports
+// the outcome. Events scheduled for the same time run in the order
+// they were scheduled.
+func (s *Sim) Run() Report {
+	sort.SliceStable(s.events, func(i, j int) bool { return s.events[i].At < s.events[j].At })
+	for _, e := range s.events {
+		s.report.Duration = e.At
+		switch e.Kind {
+		case Join:
+			s.join(e.Node)
+		case Leave:
+			s.leave(e.Node)
+		case Get:
+			n := s.nodes[e.Node]
+			if n == nil || !n.live {
+				n = s.randomNode()
+			}
+			if n != nil {
+				s.get(n, e.Key)
+			}
+		}
+	}
+	s.events = nil
+	r := s.report
+	r.Nodes = nil
+	for _, n := range s.nodes {
+		r.Nodes = append(r.Nodes, n.NodeReport)
+	}
+	sort.Slice(r.Nodes, func(i, j int) bool { return r.Nodes[i].Name < r.Nodes[j].Name })
+	return r
+}
+
+func (s *Sim) join(name string) {
+	if n, ok := s.nodes[name]; ok {
+		// A returning node keeps its counters; its caches were
+		// dropped when it left.
+		if !n.live {
+			n.live = true
+			s.rebuildRing()
+		}
+		return
+	}
+	s.nodes[name] = newNode(name)
+	s.rebuildRing()
+}
+
+func (s *Sim) leave(name string) {
+	n, ok := s.nodes[name]
+	if !ok || !n.live {
+		return
+	}
+	n.live = false
+	n.main, n.hot = newCache(), newCache()
+	s.rebuildRing()
+}
+
+func (s *Sim) rebuildRing() {
+	s.ring = consistenthash.New(s.cfg.Replicas, s.cfg.HashFn)
+	s.ring.Add(s.liveNames()...)
+}
+
+func (s *Sim) liveNames() []string {
+	var names []string
+	for name, n := range s.nodes {
+		if n.live {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Sim) randomNode() *node {
+	names := s.liveNames()
+	if len(names) == 0 {
+		return nil
+	}
+	return s.nodes[names[s.rnd.Intn(len(names))]]
+}
+
+// get follows Group.Get on node n.
+func (s *Sim) get(n *node, key string) {
+	s.report.Gets++
+	n.Gets++
+	if n.main.get(key) {
+		n.MainHits++
+		s.report.CacheHits++
+		return
+	}
+	if n.hot.get(key) {
+		n.HotHits++
+		s.report.CacheHits++
+		return
+	}
+	size := int64(len(key) + s.cfg.ValueSize(key))
+	owner := s.nodes[s.ring.Get(key)]
+	if owner == n {
+		n.OriginLoads++
+		s.report.OriginLoads++
+		s.populate(n, key, size, n.main)
+		return
+	}
+
+	s.report.PeerRequests++
+	owner.PeerRequests++
+	switch {
+	case owner.main.get(key):
+		owner.MainHits++
+		s.report.CacheHits++
+	case owner.hot.get(key):
+		owner.HotHits++
+		s.report.CacheHits++
+	default:
+		owner.OriginLoads++
+		s.report.OriginLoads++
+		s.populate(owner, key, size, owner.main)
+	}
+	if s.cfg.HotCacheOdds > 0 && s.rnd.Intn(s.cfg.HotCacheOdds) == 0 {
+		s.populate(n, key, size, n.hot)
+	}
+}
+
+// populate follows Group.populateCache.
+func (s *Sim) populate(n *node, key string, size int64, c *cache) {
+	if s.cfg.CacheBytes <= 0 {
+		return
+	}
+	c.add(key, size)
+	for n.main.nbytes+n.hot.nbytes > s.cfg.CacheBytes {
+		victim := n.main
+		if n.hot.nbytes > n.main.nbytes/8 {
+			victim = n.hot
+		}
+		victim.lru.RemoveOldest()
+		n.Evictions++
+	}
+}
+
+type node struct {
+	NodeReport
+	live      bool
+	main, hot *cache
+}
+
+func newNode(name string) *node {
+	return &node{
+		NodeReport: NodeReport{Name: name},
+		live:       true,
+		main:       newCache(),
+		hot:        newCache(),
+	}
+}
+
+// cache models a groupcache cache: an LRU whose size is counted in
+// bytes of keys and values.
+type cache struct {
+	lru    *lru.Cache
+	nbytes int64
+}
+
+func newCache() *cache {
+	c := &cache{lru: lru.New(0)}
+	c.lru.OnEvicted = func(_ lru.Key, size interface{}) {
+		c.nbytes -= size.(int64)
+	}
+	return c
+}
+
+func (c *cache) get(key string) bool {
+	_, ok := c.lru.Get(key)
+	return ok
+}
+
+func (c *cache) add(key string, size int64) {
+	if _, ok := c.lru.Get(key); ok {
+		return
+	}
+	c.lru.Add(key, size)
+	c.nbytes += size
+}
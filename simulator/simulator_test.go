@@ -0,0 +1,91 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func uniformKeys(n int) func(*rand.Rand) string {
+	return func(r *rand.Rand) string { return fmt.Sprintf("key-%d", r.Intn(n)) }
+}
+
+func run(c Config, script ...Event) Report {
+	s := New(c)
+	s.AddWorkload(Workload{End: 10 * time.Second, Rate: 1000, Key: uniformKeys(500)})
+	s.Schedule(script...)
+	return s.Run()
+}
+
+func TestDeterministic(t *testing.T) {
+	c := Config{Nodes: 4, CacheBytes: 1 << 20, Seed: 42}
+	r1, r2 := run(c), run(c)
+	if r1.String() != r2.String() {
+		t.Errorf("same seed gave different reports:\n%v\n%v", r1, r2)
+	}
+	if r1.Gets != 10000 {
+		t.Errorf("Gets = %d; want 10000", r1.Gets)
+	}
+}
+
+func TestEachKeyLoadedOnceWhenCacheFits(t *testing.T) {
+	r := run(Config{Nodes: 4, CacheBytes: 10 << 20, Seed: 1})
+	if r.OriginLoads != 500 {
+		t.Errorf("OriginLoads = %d; want one per key, 500", r.OriginLoads)
+	}
+	if r.CacheHits+r.OriginLoads != r.Gets {
+		t.Errorf("hits %d + loads %d != gets %d", r.CacheHits, r.OriginLoads, r.Gets)
+	}
+}
+
+func TestSmallCacheHurtsHitRate(t *testing.T) {
+	big := run(Config{Nodes: 4, CacheBytes: 10 << 20, Seed: 1})
+	small := run(Config{Nodes: 4, CacheBytes: 32 << 10, Seed: 1})
+	if small.HitRate() >= big.HitRate() {
+		t.Errorf("small cache hit rate %.3f >= big cache's %.3f", small.HitRate(), big.HitRate())
+	}
+	var evictions int64
+	for _, n := range small.Nodes {
+		evictions += n.Evictions
+	}
+	if evictions == 0 {
+		t.Error("small cache evicted nothing")
+	}
+}
+
+func TestMembershipEvents(t *testing.T) {
+	r := run(Config{Nodes: 3, CacheBytes: 10 << 20, Seed: 1},
+		Event{At: 5 * time.Second, Kind: Leave, Node: "node0"},
+		Event{At: 5 * time.Second, Kind: Join, Node: "node3"},
+	)
+	if len(r.Nodes) != 4 {
+		t.Fatalf("report has %d nodes; want 4", len(r.Nodes))
+	}
+	// Keys node0 owned are loaded again by their new owners.
+	if r.OriginLoads <= 500 {
+		t.Errorf("OriginLoads = %d; want reloads after membership changes", r.OriginLoads)
+	}
+	if r.Nodes[3].Name != "node3" || r.Nodes[3].Gets == 0 {
+		t.Errorf("joined node report = %+v; want it to receive gets", r.Nodes[3])
+	}
+	if imb := r.LoadImbalance(); imb < 1 {
+		t.Errorf("LoadImbalance = %v; want >= 1", imb)
+	}
+}
@@ -20,7 +20,11 @@ limitations under the License.
 // 需要保证第一次请求的返回结果和第二次的一样。
 package singleflight
 
-import "sync"
+import (
+	"sort"
+	"sync"
+	"time"
+)
 
 // call is an in-flight or completed Do call
 // 相当于一个已经完成的请求或者一个正在执行的请求。
@@ -29,6 +33,18 @@ type call struct {
 	wg  sync.WaitGroup	// 阻塞请求，等待goroutine其他完成
 	val interface{}		// 请求的返回结果
 	err error
+
+	// start, dups and chans are guarded by Group.mu.
+	start time.Time // when fn was called
+	dups  int       // callers waiting for the result
+	chans []chan<- Result
+}
+
+// Result holds the results of Do, so they can be passed on a channel.
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool // whether the results were given to several callers
 }
 
 // Group represents a class of work and forms a namespace in which
@@ -50,16 +66,48 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	}
 	// 如果map中存在这个key了，代表有goroutine正在处理该请求，等待处理结束直接返回就行了。
 	if c, ok := g.m[key]; ok {
+		c.dups++
 		g.mu.Unlock()
 		c.wg.Wait()
 		return c.val, c.err
 	}
 	// 该goroutine是第一个处理这个key的请求的。
-	c := new(call)
+	c := &call{start: time.Now()}
 	c.wg.Add(1)
 	g.m[key] = c
 	g.mu.Unlock()
 
+	g.doCall(c, key, fn)
+	return c.val, c.err
+}
+
+// DoChan is like Do but returns a channel that will receive the
+// results when they are ready, so that callers may select on it with
+// a timeout or cancellation. The channel is not closed.
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+	c := &call{start: time.Now(), chans: []chan<- Result{ch}}
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn)
+	return ch
+}
+
+// doCall runs fn for the call c of key, and hands its results to the
+// callers waiting for them.
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
 	c.val, c.err = fn()
 	// 到此为止请求已经处理完成了，接下来可以允许其他相同key的请求返回结果了。
 	c.wg.Done()
@@ -69,7 +117,28 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	// 同样保证m的原子性，需要锁。
 	g.mu.Lock()
 	delete(g.m, key)
+	for _, ch := range c.chans {
+		ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+	}
 	g.mu.Unlock()
+}
 
-	return c.val, c.err
+// A Flight describes a call in progress.
+type Flight struct {
+	Key     string
+	Started time.Time
+	Waiters int // duplicate callers waiting for the result
+}
+
+// InFlight returns the calls in progress, oldest first.
+// 返回正在执行的请求，便于排查卡住的调用。
+func (g *Group) InFlight() []Flight {
+	g.mu.Lock()
+	flights := make([]Flight, 0, len(g.m))
+	for key, c := range g.m {
+		flights = append(flights, Flight{Key: key, Started: c.start, Waiters: c.dups})
+	}
+	g.mu.Unlock()
+	sort.Slice(flights, func(i, j int) bool { return flights[i].Started.Before(flights[j].Started) })
+	return flights
 }
@@ -83,3 +83,64 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+	ch1 := g.DoChan("key", fn)
+	ch2 := g.DoChan("key", fn)
+	select {
+	case <-ch1:
+		t.Fatal("DoChan result before fn returned")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	for _, ch := range []<-chan Result{ch1, ch2} {
+		if r := <-ch; r.Val != "bar" || r.Err != nil || !r.Shared {
+			t.Errorf("DoChan = %+v; want bar, nil, shared", r)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("fn called %d times; want 1", n)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	var g Group
+	release := make(chan bool)
+	started := make(chan bool)
+	go g.Do("slow", func() (interface{}, error) {
+		started <- true
+		<-release
+		return nil, nil
+	})
+	<-started
+	done := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			g.Do("slow", nil)
+			done <- true
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let the duplicates block
+
+	flights := g.InFlight()
+	if len(flights) != 1 || flights[0].Key != "slow" || flights[0].Waiters != 2 {
+		t.Fatalf("InFlight = %+v; want one flight of \"slow\" with 2 waiters", flights)
+	}
+	if age := time.Since(flights[0].Started); age < 50*time.Millisecond {
+		t.Errorf("flight started %v ago; want at least 50ms", age)
+	}
+	close(release)
+	<-done
+	<-done
+	if flights := g.InFlight(); len(flights) != 0 {
+		t.Errorf("InFlight after completion = %+v", flights)
+	}
+}
@@ -18,6 +18,7 @@ package groupcache
 
 import (
 	"errors"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 )
@@ -135,6 +136,11 @@ func (s *byteViewSink) setView(v ByteView) error {
 	return nil
 }
 
+// setExpire records the expiration of the value loaded into the sink.
+func (s *byteViewSink) setExpire(t time.Time) {
+	s.dst.e = t
+}
+
 func (s *byteViewSink) view() (ByteView, error) {
 	return *s.dst, nil
 }
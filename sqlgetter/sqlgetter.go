@@ -0,0 +1,195 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sqlgetter provides a groupcache Getter loading values with a
+// database query, for groups caching in front of a database:
+//
+//	getter, err := sqlgetter.New(db, sqlgetter.Options{
+//		Query:   "SELECT body FROM pages WHERE id = ?",
+//		Timeout: time.Second,
+//	})
+//	pages := groupcache.NewGroup("pages", 64<<20, getter)
+//
+// The query is prepared once and run with the key as its argument. Its
+// first row is turned into the value by the Options' Codec.
+package sqlgetter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// A Codec turns the current row of rows into a value.
+type Codec func(rows *sql.Rows) ([]byte, error)
+
+// FirstColumn is the default Codec. It returns the row's first column,
+// which must be convertible to []byte.
+func FirstColumn(rows *sql.Rows) ([]byte, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	dest := make([]interface{}, len(cols))
+	var b []byte
+	dest[0] = &b
+	for i := 1; i < len(dest); i++ {
+		dest[i] = new(sql.RawBytes)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// JSONObject is a Codec encoding the row as a JSON object keyed by
+// column name. Byte columns are encoded as strings.
+func JSONObject(rows *sql.Rows) ([]byte, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(cols))
+	for i, c := range cols {
+		if b, ok := vals[i].([]byte); ok {
+			vals[i] = string(b)
+		}
+		m[c] = vals[i]
+	}
+	return json.Marshal(m)
+}
+
+// Options configure the Getter made by New.
+type Options struct {
+	// Query is run with the arguments returned by Args to load a
+	// key. Queries returning no rows load groupcache.ErrNotFound.
+	Query string
+
+	// Args returns the query arguments for a key. If nil, the key
+	// is the only argument.
+	Args func(key string) ([]interface{}, error)
+
+	// Codec turns the first row of the query into the value.
+	// If nil, FirstColumn is used.
+	Codec Codec
+
+	// Timeout bounds each query, in addition to the load's context.
+	// If zero, queries are only bounded by the context.
+	Timeout time.Duration
+}
+
+// Stats are the query statistics of a Getter.
+type Stats struct {
+	Queries   groupcache.AtomicInt // queries run
+	NotFound  groupcache.AtomicInt // queries returning no rows
+	Errors    groupcache.AtomicInt // failed queries, including timeouts
+	Timeouts  groupcache.AtomicInt // queries stopped by Timeout
+	QueryTime groupcache.AtomicInt // total nanoseconds spent in queries
+}
+
+// A Getter loads keys with a prepared query.
+type Getter struct {
+	stmt *sql.Stmt
+	opts Options
+
+	// Stats are the Getter's query statistics.
+	Stats Stats
+}
+
+// New prepares o.Query on db and returns a Getter running it. Close
+// the Getter to release the statement.
+func New(db *sql.DB, o Options) (*Getter, error) {
+	stmt, err := db.Prepare(o.Query)
+	if err != nil {
+		return nil, fmt.Errorf("sqlgetter: preparing query: %v", err)
+	}
+	if o.Codec == nil {
+		o.Codec = FirstColumn
+	}
+	if o.Args == nil {
+		o.Args = func(key string) ([]interface{}, error) { return []interface{}{key}, nil }
+	}
+	return &Getter{stmt: stmt, opts: o}, nil
+}
+
+// Get implements groupcache.Getter.
+func (g *Getter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	args, err := g.opts.Args(key)
+	if err != nil {
+		return err
+	}
+	if g.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	b, err := g.query(ctx, args)
+	g.Stats.Queries.Add(1)
+	g.Stats.QueryTime.Add(int64(time.Since(start)))
+	switch {
+	case errors.Is(err, groupcache.ErrNotFound):
+		g.Stats.NotFound.Add(1)
+		return err
+	case err != nil:
+		g.Stats.Errors.Add(1)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && g.opts.Timeout > 0 {
+			g.Stats.Timeouts.Add(1)
+		}
+		return fmt.Errorf("sqlgetter: key %q: %w", key, err)
+	}
+	return dest.SetBytes(b)
+}
+
+func (g *Getter) query(ctx context.Context, args []interface{}) ([]byte, error) {
+	rows, err := g.stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, groupcache.ErrNotFound
+	}
+	b, err := g.opts.Codec(rows)
+	if err != nil {
+		return nil, err
+	}
+	// Codecs may return memory owned by rows, valid until the next
+	// call to Next or Close.
+	b = append([]byte(nil), b...)
+	return b, rows.Close()
+}
+
+// Close releases the Getter's prepared statement.
+func (g *Getter) Close() error {
+	return g.stmt.Close()
+}
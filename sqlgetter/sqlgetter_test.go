@@ -0,0 +1,151 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sqlgetter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// fakeDriver serves a single table, keyed by the first query argument.
+// The key "slow" blocks until the query's context is done.
+type fakeDriver struct {
+	cols []string
+	rows map[string][]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("no transactions") }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	key, _ := args[0].Value.(string)
+	if key == "slow" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	r := &fakeRows{cols: s.d.cols}
+	if row, ok := s.d.rows[key]; ok {
+		r.rows = [][]driver.Value{row}
+	}
+	return r, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("sqlgetter-fake", &fakeDriver{
+		cols: []string{"body", "n"},
+		rows: map[string][]driver.Value{
+			"a": {[]byte("alpha"), int64(1)},
+			"b": {[]byte("beta"), int64(2)},
+		},
+	})
+}
+
+func newTestGetter(t *testing.T, o Options) *Getter {
+	db, err := sql.Open("sqlgetter-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	o.Query = "SELECT body, n FROM t WHERE k = ?"
+	g, err := New(db, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	return g
+}
+
+func get(g *Getter, key string) (string, error) {
+	var s string
+	err := g.Get(context.Background(), key, groupcache.StringSink(&s))
+	return s, err
+}
+
+func TestGetter(t *testing.T) {
+	g := newTestGetter(t, Options{Timeout: 20 * time.Millisecond})
+	for key, want := range map[string]string{"a": "alpha", "b": "beta"} {
+		if got, err := get(g, key); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v; want %q", key, got, err, want)
+		}
+	}
+	if _, err := get(g, "missing"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v; want ErrNotFound", err)
+	}
+	if _, err := get(g, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get(slow) error = %v; want deadline exceeded", err)
+	}
+	s := &g.Stats
+	if s.Queries.Get() != 4 || s.NotFound.Get() != 1 || s.Errors.Get() != 1 || s.Timeouts.Get() != 1 {
+		t.Errorf("Stats = queries %v, not found %v, errors %v, timeouts %v; want 4, 1, 1, 1",
+			&s.Queries, &s.NotFound, &s.Errors, &s.Timeouts)
+	}
+	if s.QueryTime.Get() < int64(20*time.Millisecond) {
+		t.Errorf("QueryTime = %v; want at least the timeout", time.Duration(s.QueryTime.Get()))
+	}
+}
+
+func TestGetterCodec(t *testing.T) {
+	g := newTestGetter(t, Options{
+		Codec: JSONObject,
+		Args: func(key string) ([]interface{}, error) {
+			return []interface{}{key[len("id:"):]}, nil
+		},
+	})
+	want := `{"body":"alpha","n":1}`
+	if got, err := get(g, "id:a"); err != nil || got != want {
+		t.Errorf("Get = %q, %v; want %q", got, err, want)
+	}
+}
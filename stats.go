@@ -0,0 +1,281 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// stats.go reports statistics of groups, for one process or summed
+// across a cluster of peers.
+
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GroupStats is a snapshot of a group's Stats and cache statistics.
+type GroupStats struct {
+	Gets, CacheHits, PeerLoads, PeerErrors, Loads, LoadsDeduped int64
+	LocalLoads, LocalLoadErrs, LoadsRejected, ServerRequests    int64
+	PeerNotModified, NegativeHits, NotAdmitted, ServedBytes     int64
+
+	MainCache CacheStats
+	HotCache  CacheStats
+
+	// Rates are the rates over each of RateWindows.
+	Rates []RateStats
+}
+
+// StatsSnapshot returns a snapshot of the group's statistics.
+func (g *Group) StatsSnapshot() GroupStats {
+	return GroupStats{
+		Gets:            g.Stats.Gets.Get(),
+		CacheHits:       g.Stats.CacheHits.Get(),
+		PeerLoads:       g.Stats.PeerLoads.Get(),
+		PeerErrors:      g.Stats.PeerErrors.Get(),
+		Loads:           g.Stats.Loads.Get(),
+		LoadsDeduped:    g.Stats.LoadsDeduped.Get(),
+		LocalLoads:      g.Stats.LocalLoads.Get(),
+		LocalLoadErrs:   g.Stats.LocalLoadErrs.Get(),
+		LoadsRejected:   g.Stats.LoadsRejected.Get(),
+		ServerRequests:  g.Stats.ServerRequests.Get(),
+		PeerNotModified: g.Stats.PeerNotModified.Get(),
+		NegativeHits:    g.Stats.NegativeHits.Get(),
+		NotAdmitted:     g.Stats.NotAdmitted.Get(),
+		ServedBytes:     g.Stats.ServedBytes.Get(),
+		MainCache:       g.CacheStats(MainCache),
+		HotCache:        g.CacheStats(HotCache),
+		Rates:           g.Rates(),
+	}
+}
+
+// HitRate returns the fraction of Gets answered from a local cache.
+func (s GroupStats) HitRate() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.Gets)
+}
+
+func (s *GroupStats) add(o GroupStats) {
+	s.Gets += o.Gets
+	s.CacheHits += o.CacheHits
+	s.PeerLoads += o.PeerLoads
+	s.PeerErrors += o.PeerErrors
+	s.Loads += o.Loads
+	s.LoadsDeduped += o.LoadsDeduped
+	s.LocalLoads += o.LocalLoads
+	s.LocalLoadErrs += o.LocalLoadErrs
+	s.LoadsRejected += o.LoadsRejected
+	s.ServerRequests += o.ServerRequests
+	s.PeerNotModified += o.PeerNotModified
+	s.NegativeHits += o.NegativeHits
+	s.NotAdmitted += o.NotAdmitted
+	s.ServedBytes += o.ServedBytes
+	// Rates are summed window by window; nodes report the same ones.
+	for i, r := range o.Rates {
+		if i == len(s.Rates) {
+			s.Rates = append(s.Rates, RateStats{Window: r.Window})
+		}
+		s.Rates[i].add(r)
+	}
+	s.MainCache.add(o.MainCache)
+	s.HotCache.add(o.HotCache)
+}
+
+func (s *CacheStats) add(o CacheStats) {
+	s.Bytes += o.Bytes
+	s.Items += o.Items
+	s.Gets += o.Gets
+	s.Hits += o.Hits
+	s.Evictions += o.Evictions
+	s.ArenaBytes += o.ArenaBytes
+}
+
+// NodeStats holds the statistics of the groups registered in one
+// process.
+type NodeStats struct {
+	Self   string
+	Groups map[string]GroupStats
+}
+
+// localStats returns the statistics of the registered groups.
+func localStats(self string) NodeStats {
+	ns := NodeStats{Self: self, Groups: make(map[string]GroupStats)}
+	for _, g := range registeredGroups() {
+		ns.Groups[g.Name()] = g.StatsSnapshot()
+	}
+	return ns
+}
+
+// ClusterStats holds the statistics of every node of a pool.
+type ClusterStats struct {
+	// Groups sums each group's statistics over the nodes that
+	// answered.
+	Groups map[string]GroupStats
+
+	// Nodes holds each answering node's statistics, sorted by Self.
+	Nodes []NodeStats
+
+	// Errors maps the nodes that could not be reached to the error.
+	Errors map[string]string `json:",omitempty"`
+}
+
+// ClusterStats collects the statistics of every peer of the pool,
+// including this process, and sums them by group.
+func (p *HTTPPool) ClusterStats(ctx context.Context) ClusterStats {
+	p.mu.Lock()
+	getters := make(map[string]*httpGetter, len(p.httpGetters))
+	for peer, g := range p.httpGetters {
+		getters[peer] = g
+	}
+	p.mu.Unlock()
+
+	cs := ClusterStats{Groups: make(map[string]GroupStats)}
+	nodes := []NodeStats{localStats(p.self)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for peer, g := range getters {
+		wg.Add(1)
+		go func(peer string, g *httpGetter) {
+			defer wg.Done()
+			ns, err := g.stats(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if cs.Errors == nil {
+					cs.Errors = make(map[string]string)
+				}
+				cs.Errors[peer] = err.Error()
+				return
+			}
+			nodes = append(nodes, ns)
+		}(peer, g)
+	}
+	wg.Wait()
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Self < nodes[j].Self })
+	for _, ns := range nodes {
+		for name, gs := range ns.Groups {
+			sum := cs.Groups[name]
+			sum.add(gs)
+			cs.Groups[name] = sum
+		}
+	}
+	cs.Nodes = nodes
+	return cs
+}
+
+// statsPath is the name, under the pool's BasePath, of the endpoint
+// serving a process's NodeStats to its peers. Since it contains no
+// slash it cannot collide with a group's keys.
+const statsPath = "_stats"
+
+func (p *HTTPPool) serveStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, localStats(p.self))
+}
+
+// stats fetches the peer's NodeStats.
+func (h *httpGetter) stats(ctx context.Context) (NodeStats, error) {
+	var ns NodeStats
+	req, err := http.NewRequest("GET", h.baseURL+statsPath, nil)
+	if err != nil {
+		return ns, err
+	}
+	res, err := h.roundTrip(ctx, req)
+	if err != nil {
+		return ns, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ns, fmt.Errorf("server returned: %v", res.Status)
+	}
+	err = json.NewDecoder(res.Body).Decode(&ns)
+	return ns, err
+}
+
+// InFlightLoad describes a load in progress, either local or from a
+// peer.
+type InFlightLoad struct {
+	Group    string
+	Key      string
+	Started  time.Time
+	Duration string // time since Started
+	Waiters  int    // duplicate callers waiting for the load
+}
+
+// inFlightLoads lists the loads in progress in the registered groups,
+// oldest first.
+func inFlightLoads() []InFlightLoad {
+	now := time.Now()
+	loads := []InFlightLoad{}
+	for _, g := range registeredGroups() {
+		for _, f := range g.InFlight() {
+			loads = append(loads, InFlightLoad{
+				Group:    g.name,
+				Key:      f.Key,
+				Started:  f.Started,
+				Duration: now.Sub(f.Started).String(),
+				Waiters:  f.Waiters,
+			})
+		}
+	}
+	sort.Slice(loads, func(i, j int) bool { return loads[i].Started.Before(loads[j].Started) })
+	return loads
+}
+
+// AdminHandler returns a handler for operators serving, as JSON:
+//
+//	/stats          this process's NodeStats
+//	/stats/cluster  the pool's ClusterStats
+//	/inflight       the loads in progress, oldest first
+//	/peers          the pool's PeerStats
+//	/ring           the share of the key space owned by each peer
+//	/hotkeys        the hot-cache keys of each group; ?n= sets how many
+//	/dashboard      an HTML page showing the above
+//	/drain          whether the pool is draining; POST runs Drain, with
+//	                an optional max_bytes, and returns its DrainReport
+//
+// Mount it under a prefix with http.StripPrefix. The handler is wrapped
+// with the AdminMiddleware option.
+func (p *HTTPPool) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", p.serveStats)
+	mux.HandleFunc("/stats/cluster", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.ClusterStats(r.Context()))
+	})
+	mux.HandleFunc("/inflight", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, inFlightLoads())
+	})
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.PeerStats())
+	})
+	mux.HandleFunc("/ring", p.serveRing)
+	mux.HandleFunc("/hotkeys", serveHotKeys)
+	mux.HandleFunc("/dashboard", serveDashboard)
+	mux.HandleFunc("/drain", p.serveDrain)
+	return chainHandler(mux, p.opts.AdminMiddleware)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
+)
+
+// startPool serves a new pool on a test server, whose URL is the
+// pool's self.
+func startPool(t *testing.T, o *HTTPPoolOptions) (*HTTPPool, *httptest.Server) {
+	var p *HTTPPool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	p = newHTTPPool(ts.URL, o)
+	return p, ts
+}
+
+func TestClusterStats(t *testing.T) {
+	const name = "TestClusterStats-group"
+	g := newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), NoPeers{})
+	for _, key := range []string{"a", "b", "a"} {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both pools report the same process-wide groups, so the cluster
+	// sums are twice the local ones.
+	a, _ := startPool(t, nil)
+	_, tsb := startPool(t, nil)
+	const down = "http://127.0.0.1:1"
+	a.Set(a.self, tsb.URL, down)
+
+	cs := a.ClusterStats(dummyCtx)
+	if len(cs.Nodes) != 2 {
+		t.Fatalf("got stats of %d nodes; want 2", len(cs.Nodes))
+	}
+	if _, ok := cs.Errors[down]; !ok || len(cs.Errors) != 1 {
+		t.Errorf("Errors = %v; want one for %s", cs.Errors, down)
+	}
+	sum := cs.Groups[name]
+	if sum.Gets != 6 || sum.CacheHits != 2 || sum.MainCache.Items != 4 {
+		t.Errorf("summed stats = %+v; want 6 gets, 2 hits, 4 items", sum)
+	}
+	if got, want := sum.HitRate(), 1.0/3; got != want {
+		t.Errorf("HitRate = %v; want %v", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	http.StripPrefix("/admin", a.AdminHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/admin/stats", nil))
+	var ns NodeStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &ns); err != nil {
+		t.Fatalf("decoding /stats: %v", err)
+	}
+	if ns.Self != a.self || ns.Groups[name].Gets != 3 {
+		t.Errorf("/stats = %+v", ns)
+	}
+}
+
+func TestAdminInFlight(t *testing.T) {
+	const name = "TestAdminInFlight-group"
+	started := make(chan bool)
+	release := make(chan bool)
+	g := newGroup(name, 0, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		started <- true
+		<-release
+		return dest.SetString(key)
+	}), NoPeers{})
+	done := make(chan bool)
+	go func() {
+		var s string
+		g.Get(dummyCtx, "stuck", StringSink(&s))
+		done <- true
+	}()
+	<-started
+	defer func() {
+		close(release)
+		<-done
+	}()
+
+	p := newHTTPPool("http://self", nil)
+	rec := httptest.NewRecorder()
+	p.AdminHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/inflight", nil))
+	var loads []InFlightLoad
+	if err := json.Unmarshal(rec.Body.Bytes(), &loads); err != nil {
+		t.Fatalf("decoding /inflight: %v", err)
+	}
+	var found bool
+	for _, l := range loads {
+		if l.Group == name && l.Key == "stuck" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("/inflight = %+v; want the stuck load listed", loads)
+	}
+}
+
+func TestPeerStats(t *testing.T) {
+	const name = "TestPeerStats-group"
+	newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), NoPeers{})
+	_, tsb := startPool(t, nil)
+	a := newHTTPPool("http://self", &HTTPPoolOptions{MaxConnsPerPeer: 1, IdleConnTimeout: time.Minute})
+	a.Set(tsb.URL)
+
+	peer, _ := a.PickPeer("k")
+	for i := 0; i < 3; i++ {
+		if err := peer.Get(dummyCtx, &pb.GetRequest{Group: proto.String(name), Key: proto.String("k")}, &pb.GetResponse{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ps := a.PeerStats()
+	if len(ps) != 1 {
+		t.Fatalf("PeerStats = %+v; want one peer", ps)
+	}
+	s := ps[0]
+	if s.Peer != tsb.URL || s.Requests != 3 || s.InFlight != 0 || s.ConnsNew != 1 || s.ConnsReused != 2 {
+		t.Errorf("PeerStats = %+v; want 3 requests over 1 reused connection", s)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/benchmarks"
+)
+
+// ReplayOptions are the options of Replay.
+type ReplayOptions struct {
+	// Speed scales the pace of the trace: 2 replays it twice as fast
+	// as it was recorded. If zero, records are replayed as fast as
+	// the workers allow.
+	Speed float64
+
+	// Workers is the most requests in flight. If zero, it is 64.
+	Workers int
+}
+
+// Replay calls do for each of recs, in order and at the pace they were
+// recorded at as scaled by the options, and measures the calls. Records
+// falling behind their time are sent right away; replay stops early if
+// ctx is done.
+func Replay(ctx context.Context, recs []Record, do func(ctx context.Context, rec Record) error, o *ReplayOptions) benchmarks.Result {
+	var opts ReplayOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 64
+	}
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errs      int
+		wg        sync.WaitGroup
+	)
+	sem := make(chan struct{}, opts.Workers)
+	start := time.Now()
+	for _, rec := range recs {
+		if opts.Speed > 0 {
+			at := time.Duration(float64(rec.Time.Sub(recs[0].Time)) / opts.Speed)
+			if d := at - time.Since(start); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+				}
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(rec Record) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			t0 := time.Now()
+			err := do(ctx, rec)
+			d := time.Since(t0)
+			mu.Lock()
+			defer mu.Unlock()
+			latencies = append(latencies, d)
+			if err != nil {
+				errs++
+			}
+		}(rec)
+	}
+	wg.Wait()
+	r := benchmarks.Result{Ops: len(latencies), Errors: errs, Duration: time.Since(start)}
+	if len(latencies) == 0 {
+		return r
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50 = benchmarks.Percentile(latencies, 50)
+	r.P90 = benchmarks.Percentile(latencies, 90)
+	r.P99 = benchmarks.Percentile(latencies, 99)
+	r.Max = latencies[len(latencies)-1]
+	return r
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trace records the Gets served by groupcache groups and
+// replays them against a test cluster, for performance regression tests
+// and comparisons of cache policies on real traffic:
+//
+//	rec := trace.NewRecorder(f, nil)
+//	defer rec.Close()
+//	group := groupcache.NewGroupOpts("thumbnails", 64<<20, getter,
+//		&groupcache.GroupOptions{Recorder: rec})
+//
+// Traces hold one JSON object per line. Keys are recorded as hashes,
+// so traces can be shared without the keys; a replay requests the
+// hashes instead, from an origin such as Getter serving values of the
+// recorded sizes.
+package trace
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// A Record is a Get in a trace.
+type Record struct {
+	Time    time.Time
+	Group   string
+	KeyHash string
+	Size    int
+	Source  groupcache.GetSource
+}
+
+// line is the encoding of a Record.
+type line struct {
+	T int64  `json:"t"` // unix nanoseconds
+	G string `json:"g"`
+	K string `json:"k"`
+	N int    `json:"n"`
+	S string `json:"s"`
+}
+
+// HashKey returns the hash recorded for key.
+func HashKey(key string) string {
+	h := fnv.New64a()
+	io.WriteString(h, key)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// RecorderOptions are the options of a Recorder.
+type RecorderOptions struct {
+	// SampleRate, if in (0, 1), records only that fraction of the
+	// keys, chosen by hash, with every Get of a chosen key.
+	SampleRate float64
+
+	// Buffer is the number of records waiting to be written beyond
+	// which new records are dropped rather than slowing down Gets.
+	// If zero, it is 4096.
+	Buffer int
+}
+
+// A Recorder writes the Gets it is told of to a trace. It is a
+// groupcache.GetRecorder; Gets never wait for the writes.
+type Recorder struct {
+	w         *bufio.Writer
+	threshold uint64 // hashes at or above it are not sampled; 0 records all
+	records   chan Record
+	done      chan struct{}
+	dropped   int64
+
+	mu     sync.RWMutex // guards closed against RecordGet
+	closed bool
+	err    error // first write error
+}
+
+// NewRecorder returns a Recorder writing to w until it is closed.
+func NewRecorder(w io.Writer, o *RecorderOptions) *Recorder {
+	var opts RecorderOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 4096
+	}
+	r := &Recorder{
+		w:       bufio.NewWriter(w),
+		records: make(chan Record, opts.Buffer),
+		done:    make(chan struct{}),
+	}
+	if opts.SampleRate > 0 && opts.SampleRate < 1 {
+		r.threshold = uint64(opts.SampleRate * (1 << 63) * 2)
+	}
+	go r.write()
+	return r
+}
+
+// RecordGet implements groupcache.GetRecorder.
+func (r *Recorder) RecordGet(g groupcache.GetRecord) {
+	h := fnv.New64a()
+	io.WriteString(h, g.Key)
+	sum := h.Sum64()
+	// FNV's high bits vary little between similar keys; mix them
+	// before sampling.
+	if r.threshold > 0 && sum*0x9e3779b97f4a7c15 >= r.threshold {
+		return
+	}
+	rec := Record{
+		Time:    g.Time,
+		Group:   g.Group,
+		KeyHash: strconv.FormatUint(sum, 16),
+		Size:    g.Size,
+		Source:  g.Source,
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.records <- rec:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Dropped returns the number of records dropped because the writer
+// fell behind.
+func (r *Recorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+func (r *Recorder) write() {
+	defer close(r.done)
+	enc := json.NewEncoder(r.w)
+	for rec := range r.records {
+		if r.err != nil {
+			continue
+		}
+		r.err = enc.Encode(line{T: rec.Time.UnixNano(), G: rec.Group, K: rec.KeyHash, N: rec.Size, S: rec.Source.String()})
+		if r.err == nil && len(r.records) == 0 {
+			// 空闲时写出缓冲区。
+			r.err = r.w.Flush()
+		}
+	}
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+}
+
+// Close writes the pending records and stops recording. It returns the
+// first error writing the trace.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.records)
+	}
+	r.mu.Unlock()
+	<-r.done
+	return r.err
+}
+
+// A Reader reads the Records of a trace.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewReader returns a Reader of the trace in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{s: bufio.NewScanner(r)}
+}
+
+// Next returns the next Record of the trace, or io.EOF at its end.
+func (r *Reader) Next() (Record, error) {
+	for r.s.Scan() {
+		r.line++
+		if len(r.s.Bytes()) == 0 {
+			continue
+		}
+		var l line
+		if err := json.Unmarshal(r.s.Bytes(), &l); err != nil {
+			return Record{}, fmt.Errorf("trace: line %d: %v", r.line, err)
+		}
+		src, ok := groupcache.ParseGetSource(l.S)
+		if !ok {
+			return Record{}, fmt.Errorf("trace: line %d: unknown source %q", r.line, l.S)
+		}
+		return Record{Time: time.Unix(0, l.T), Group: l.G, KeyHash: l.K, Size: l.N, Source: src}, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// ReadAll returns the Records of the trace in r.
+func ReadAll(r io.Reader) ([]Record, error) {
+	var recs []Record
+	tr := NewReader(r)
+	for {
+		rec, err := tr.Next()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return recs, err
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// Sizes returns the size of the value of each key hash of recs, from
+// its last successful Get. Keys whose Gets all failed are absent.
+func Sizes(recs []Record) map[string]int {
+	sizes := make(map[string]int)
+	for _, rec := range recs {
+		if rec.Source != groupcache.SourceError {
+			sizes[rec.KeyHash] = rec.Size
+		}
+	}
+	return sizes
+}
+
+// Getter returns an origin for replays, whose value of each key hash
+// in sizes has that size. Other keys are not found.
+func Getter(sizes map[string]int) groupcache.Getter {
+	return groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		n, ok := sizes[key]
+		if !ok {
+			return groupcache.ErrNotFound
+		}
+		return dest.SetBytes(make([]byte, n))
+	})
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf, nil)
+	g := groupcache.NewGroupOpts("trace", 1<<20, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		if key == "missing" {
+			return groupcache.ErrNotFound
+		}
+		return dest.SetString(strings.Repeat("x", len(key)))
+	}), &groupcache.GroupOptions{Peers: groupcache.NoPeers{}, Unregistered: true, Recorder: rec})
+
+	var s string
+	for _, key := range []string{"a", "bb", "a", "missing"} {
+		g.Get(context.Background(), key, groupcache.StringSink(&s))
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := ReadAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range recs {
+		got = append(got, fmt.Sprintf("%s %d %v", r.Group, r.Size, r.Source))
+	}
+	if want := "[trace 1 origin trace 2 origin trace 1 main trace 0 error]"; fmt.Sprint(got) != want {
+		t.Errorf("records = %v; want %v", got, want)
+	}
+	if recs[0].KeyHash != HashKey("a") || recs[0].KeyHash != recs[2].KeyHash {
+		t.Errorf("key hashes = %q, %q; want %q", recs[0].KeyHash, recs[2].KeyHash, HashKey("a"))
+	}
+
+	// Replay against a group whose origin serves the recorded sizes.
+	sizes := Sizes(recs)
+	replay := groupcache.NewGroupOpts("replay", 1<<20, Getter(sizes),
+		&groupcache.GroupOptions{Peers: groupcache.NoPeers{}, Unregistered: true})
+	var served int
+	res := Replay(context.Background(), recs, func(ctx context.Context, r Record) error {
+		var b []byte
+		err := replay.Get(ctx, r.KeyHash, groupcache.AllocatingByteSliceSink(&b))
+		served += len(b)
+		return err
+	}, &ReplayOptions{Workers: 1})
+	if res.Ops != 4 || res.Errors != 1 || served != 4 {
+		t.Errorf("replay: %v, %d bytes served; want 4 ops, 1 error, 4 bytes", res, served)
+	}
+	if hits := replay.Stats.CacheHits.Get(); hits != 1 {
+		t.Errorf("replay cache hits = %d; want 1", hits)
+	}
+}
+
+func TestReplayPace(t *testing.T) {
+	start := time.Unix(1e9, 0)
+	recs := []Record{{Time: start}, {Time: start.Add(time.Second)}}
+	res := Replay(context.Background(), recs, func(context.Context, Record) error { return nil }, &ReplayOptions{Speed: 20})
+	if res.Ops != 2 || res.Duration < 50*time.Millisecond {
+		t.Errorf("replay at 20x: %v; want 2 ops over 50ms", res)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res = Replay(ctx, recs, func(context.Context, Record) error { return nil }, nil)
+	if res.Ops != 0 {
+		t.Errorf("replay with a done context made %d ops; want 0", res.Ops)
+	}
+}
+
+func TestRecorderSampling(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf, &RecorderOptions{SampleRate: 0.25})
+	for i := 0; i < 1000; i++ {
+		rec.RecordGet(groupcache.GetRecord{Key: fmt.Sprint(i), Source: groupcache.SourceOrigin})
+	}
+	rec.Close()
+	recs, err := ReadAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(recs) + int(rec.Dropped()); n < 150 || n > 350 {
+		t.Errorf("sampled %d of 1000 keys; want about 250", n)
+	}
+	// Records after Close are ignored.
+	rec.RecordGet(groupcache.GetRecord{Key: "late"})
+}